@@ -0,0 +1,60 @@
+// Package main is the entry point for the audit log verifier.
+// It checks the hash chain of an audit log written by the hub, across its rotated files,
+// and reports the first entry that was tampered with.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"trading/internal/audit"
+)
+
+// Exit codes
+const (
+	exitIntact   = 0
+	exitTampered = 1
+	exitError    = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run verifies the log named by args and returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("auditverify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: auditverify FILE")
+		fmt.Fprintln(stderr, "Verifies the audit log FILE and the rotated files next to it.")
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitIntact
+		}
+		return exitError
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitError
+	}
+
+	path := flags.Arg(0)
+	count, err := audit.Verify(path)
+	var verifyErr *audit.VerifyError
+	switch {
+	case errors.As(err, &verifyErr):
+		fmt.Fprintf(stderr, "TAMPERED: %v (%d entries verified before it)\n", verifyErr, count)
+		return exitTampered
+	case err != nil:
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Fprintf(stdout, "OK: %d entries verified\n", count)
+	return exitIntact
+}
@@ -3,63 +3,233 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"trading/internal/audit"
 	"trading/internal/date"
 	"trading/internal/hub"
+	"trading/internal/replay"
+	"trading/internal/trace"
 )
 
+// shutdownTimeout bounds how long in-flight requests may take to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func main() {
-	// Parse command line flags
-	port := flag.String("port", "8080", "Port to listen on")
-	logLevel := flag.String("log-level", "info", "Log level (debug, info, warn, error)")
-	flag.Parse()
-
-	// Create hub configuration
-	config := hub.Config{
-		Port:     *port,
-		LogLevel: *logLevel,
+	// Load the layered configuration: defaults, config file, HUB_* environment variables, flags
+	config, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
 	}
 
 	// Create a new hub
 	p := hub.New(config)
 
+	// Set up tracing
+	tracer, err := newTracer(config.Tracing)
+	if err != nil {
+		slog.Error("Invalid tracing configuration", "error", err)
+		os.Exit(1)
+	}
+	if tracer != nil {
+		p.SetTracer(tracer)
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := tracer.Shutdown(ctx); err != nil {
+				slog.Error("Error flushing spans", "error", err)
+			}
+		}()
+	}
+
+	// Require credentials when keys are configured
+	authenticator, err := hub.NewAuthenticator(config.Auth, os.LookupEnv)
+	if err != nil {
+		slog.Error("Invalid authentication configuration", "error", err)
+		os.Exit(1)
+	}
+	p.SetAuthenticator(authenticator)
+
+	// Record authenticated requests and endpoint actions to the audit log
+	if config.Audit.Enabled() {
+		auditLog, err := audit.Open(config.Audit.LoggerConfig())
+		if err != nil {
+			slog.Error("Error opening audit log", "error", err)
+			os.Exit(1)
+		}
+		p.SetAuditor(auditLog)
+		defer func() {
+			if err := auditLog.Close(); err != nil {
+				slog.Error("Error closing audit log", "error", err)
+			}
+		}()
+	}
+
 	// Register endpoints
-	dateEndpoint := date.New(date.Config{})
+	dateConfig, err := hub.EndpointConfig(config, "date", date.Config{})
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	dateEndpoint := date.New(dateConfig)
 	p.RegisterEndpoint("date", dateEndpoint)
 
+	// Serve a recorded stream when the replay endpoint is configured
+	if _, ok := config.Endpoints["replay"]; ok {
+		replayConfig, err := hub.EndpointConfig(config, "replay", replay.Config{})
+		if err != nil {
+			slog.Error("Invalid configuration", "error", err)
+			os.Exit(1)
+		}
+		replayEndpoint, err := replay.New(replayConfig)
+		if err != nil {
+			slog.Error("Error loading recording", "error", err)
+			os.Exit(1)
+		}
+		p.RegisterEndpoint("replay", replayEndpoint)
+	}
+
 	// Set up logging
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: getLogLevel(*logLevel),
+		Level: getLogLevel(config.LogLevel),
 	}))
 	slog.SetDefault(logger)
 
 	// Log startup
-	slog.Info("Starting hub service", "port", *port, "log_level", *logLevel)
+	slog.Info("Starting hub service", "port", config.Port, "log_level", config.LogLevel)
 
 	// Handle graceful shutdown
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
+	// Reload the configuration on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
 	// Start the hub in a goroutine
 	errChan := make(chan error)
 	go func() {
 		errChan <- p.Start()
 	}()
 
-	// Wait for shutdown signal or error
-	select {
-	case err := <-errChan:
+	// Wait for shutdown signal or error, reloading on request
+	for {
+		select {
+		case err := <-errChan:
+			if err != nil {
+				slog.Error("Hub error", "error", err)
+				os.Exit(1)
+			}
+			return
+		case <-reload:
+			slog.Info("Reloading configuration")
+			newConfig, err := loadConfig(os.Args[1:])
+			if err != nil {
+				slog.Error("Configuration reload failed, keeping the running configuration", "error", err)
+				continue
+			}
+			if err := p.Reload(newConfig); errors.Is(err, hub.ErrInvalidConfig) {
+				slog.Error("Configuration reload failed, keeping the running configuration", "error", err)
+				continue
+			} else if err != nil {
+				slog.Warn("Configuration reloaded with rejected changes", "error", err)
+			}
+			// Keys are rebuilt on every reload so rotated secrets are picked up. The hub refuses
+			// to switch authentication off, so the current keys are kept then.
+			if authenticator, err := hub.NewAuthenticator(newConfig.Auth, os.LookupEnv); err != nil {
+				slog.Error("Authentication reload failed, keeping the current keys", "error", err)
+			} else if authenticator != nil {
+				p.SetAuthenticator(authenticator)
+			}
+		case <-shutdown:
+			slog.Info("Shutting down hub service")
+
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := p.Shutdown(ctx); err != nil {
+				slog.Error("Error during shutdown", "error", err)
+				os.Exit(1)
+			}
+			if err := <-errChan; err != nil {
+				slog.Error("Hub error", "error", err)
+				os.Exit(1)
+			}
+			slog.Info("Hub service stopped")
+			return
+		}
+	}
+}
+
+// loadConfig builds the hub configuration from defaults, the config file, HUB_* environment
+// variables and finally the command line flags that were explicitly set, then validates it
+func loadConfig(args []string) (hub.Config, error) {
+	defaults := hub.DefaultConfig()
+
+	flags := flag.NewFlagSet("hub", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv("HUB_CONFIG"), "Path to a JSON (.json) or YAML-lite config file")
+	port := flags.String("port", defaults.Port, "Port to listen on")
+	logLevel := flags.String("log-level", defaults.LogLevel, "Log level (debug, info, warn, error)")
+	traceExporter := flags.String("trace-exporter", defaults.Tracing.Exporter, "Span exporter (none, stdout, otlp)")
+	otlpEndpoint := flags.String("otlp-endpoint", defaults.Tracing.OTLPEndpoint, "OTLP/HTTP collector URL used by the otlp exporter")
+	tlsCert := flags.String("tls-cert", defaults.TLS.CertFile, "PEM certificate file; enables TLS")
+	tlsKey := flags.String("tls-key", defaults.TLS.KeyFile, "PEM private key file")
+	if err := flags.Parse(args); err != nil {
+		return hub.Config{}, err
+	}
+
+	config, err := hub.LoadConfig(*configPath, os.LookupEnv)
+	if err != nil {
+		return config, err
+	}
+
+	// Only flags given on the command line override the lower layers
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			config.Port = *port
+		case "log-level":
+			config.LogLevel = *logLevel
+		case "trace-exporter":
+			config.Tracing.Exporter = *traceExporter
+		case "otlp-endpoint":
+			config.Tracing.OTLPEndpoint = *otlpEndpoint
+		case "tls-cert":
+			config.TLS.CertFile = *tlsCert
+		case "tls-key":
+			config.TLS.KeyFile = *tlsKey
+		}
+	})
+
+	return config, config.Validate()
+}
+
+// newTracer creates the tracer selected by the tracing configuration, or nil when tracing is off
+func newTracer(config hub.TracingConfig) (*trace.Tracer, error) {
+	switch config.Exporter {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return trace.NewTracer(trace.NewStdoutExporter(os.Stdout), trace.DefaultConfig()), nil
+	case "otlp":
+		otlp, err := trace.NewOTLPExporter(trace.OTLPConfig{Endpoint: config.OTLPEndpoint, ServiceName: "hub"})
 		if err != nil {
-			slog.Error("Hub error", "error", err)
-			os.Exit(1)
+			return nil, err
 		}
-	case <-shutdown:
-		slog.Info("Shutting down hub service")
+		return trace.NewTracer(otlp, trace.DefaultConfig()), nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", config.Exporter)
 	}
 }
 
@@ -0,0 +1,171 @@
+// Package main is the entry point for hubbench, the load generator of the hub. It opens
+// many stream and REST clients against a hub and reports throughput, event latency and,
+// for a hub started in-process, the memory and goroutines each stream costs.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"trading/internal/hub"
+	"trading/internal/loadgen"
+)
+
+// Exit codes
+const (
+	exitOK     = 0
+	exitFailed = 1 // The run failed or some clients did
+	exitUsage  = 2
+)
+
+// benchEndpoint is the name of the synthetic endpoint of an in-process hub
+const benchEndpoint = "bench"
+
+// result is the JSON output of a run
+type result struct {
+	*loadgen.Report
+	MemoryPerStream     float64 `json:"memory_per_stream,omitempty"`
+	GoroutinesPerStream float64 `json:"goroutines_per_stream,omitempty"`
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run executes a benchmark and returns the exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("hubbench", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: hubbench [flags]")
+		fmt.Fprintln(stderr, "Without -url, a hub is started in-process with a synthetic endpoint.")
+		flags.PrintDefaults()
+	}
+	baseURL := flags.String("url", "", "Base URL of a running hub; empty to start one in-process")
+	endpoint := flags.String("endpoint", benchEndpoint, "Endpoint under load")
+	streams := flags.Int("streams", 1000, "Stream clients")
+	restClients := flags.Int("rest", 0, "REST clients, each calling the endpoint in a loop")
+	duration := flags.Duration("duration", 10*time.Second, "Length of the measurement, once the streams are open")
+	interval := flags.Duration("interval", 100*time.Millisecond, "Time between the events of the in-process endpoint")
+	size := flags.Int("size", 128, "Approximate size of the events of the in-process endpoint, in bytes")
+	apiKey := flags.String("api-key", os.Getenv("HUB_API_KEY"), "API key sent as X-API-Key (HUB_API_KEY)")
+	token := flags.String("token", os.Getenv("HUB_TOKEN"), "Bearer token sent as Authorization (HUB_TOKEN)")
+	output := flags.String("o", "text", "Output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(stderr, "Unknown output format %q\n", *output)
+		return exitUsage
+	}
+
+	config := loadgen.Config{
+		URL:         *baseURL,
+		Endpoint:    *endpoint,
+		Headers:     map[string]string{},
+		Streams:     *streams,
+		RESTClients: *restClients,
+		Duration:    *duration,
+	}
+	if *apiKey != "" {
+		config.Headers["X-API-Key"] = *apiKey
+	}
+	if *token != "" {
+		config.Headers["Authorization"] = "Bearer " + *token
+	}
+
+	// Memory and goroutines are only known for a hub in this process
+	inProcess := config.URL == ""
+	var before, open loadgen.Usage
+	if inProcess {
+		url, shutdown, err := startHub(loadgen.EndpointConfig{Interval: *interval, Size: *size})
+		if err != nil {
+			fmt.Fprintf(stderr, "Error starting hub: %v\n", err)
+			return exitFailed
+		}
+		defer shutdown()
+		config.URL = url
+		before = loadgen.ReadUsage()
+		config.OnOpen = func() { open = loadgen.ReadUsage() }
+	}
+
+	fmt.Fprintf(stderr, "Opening %d streams on %s/%s\n", config.Streams, config.URL, config.Endpoint)
+	report, err := loadgen.Run(ctx, config)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	res := result{Report: report}
+	if inProcess {
+		res.MemoryPerStream, res.GoroutinesPerStream = open.PerStream(before, report.Streams)
+	}
+	if *output == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(res)
+	} else {
+		printReport(stdout, res, inProcess)
+	}
+
+	if report.StreamErrors > 0 || report.RequestErrors > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// startHub serves a hub with the synthetic endpoint on a local port. Quotas are lifted,
+// since every client comes from the same address.
+func startHub(endpointConfig loadgen.EndpointConfig) (string, func(), error) {
+	config := hub.DefaultConfig()
+	config.LogLevel = "error"
+	config.Limits.StreamsPerClient = 0
+	config.Limits.MaxConnections = 0
+	platform := hub.New(config)
+	platform.RegisterEndpoint(benchEndpoint, loadgen.NewEndpoint(endpointConfig))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	served := make(chan error, 1)
+	go func() { served <- platform.Serve(listener) }()
+	shutdown := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		platform.Shutdown(ctx)
+		<-served
+	}
+	return "http://" + listener.Addr().String(), shutdown, nil
+}
+
+// printReport prints the result as aligned lines
+func printReport(w io.Writer, res result, inProcess bool) {
+	fmt.Fprintf(w, "duration    %s\n", res.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "streams     %d open, %d failed\n", res.Streams, res.StreamErrors)
+	fmt.Fprintf(w, "events      %d (%.1f/s)\n", res.Events, res.EventRate())
+	fmt.Fprintf(w, "latency     %s\n", res.EventLatency)
+	if res.Requests > 0 || res.RequestErrors > 0 {
+		fmt.Fprintf(w, "requests    %d (%.1f/s), %d failed\n", res.Requests, res.RequestRate(), res.RequestErrors)
+		fmt.Fprintf(w, "rest        %s\n", res.RequestLatency)
+	}
+	if inProcess && res.Streams > 0 {
+		fmt.Fprintf(w, "memory      %.1f KiB per stream, client included\n", res.MemoryPerStream/1024)
+		fmt.Fprintf(w, "goroutines  %.1f per stream, client included\n", res.GoroutinesPerStream)
+	}
+}
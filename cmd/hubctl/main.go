@@ -0,0 +1,241 @@
+// Package main is the entry point for hubctl, the command-line client of the hub.
+// It lists endpoints, makes REST calls and tails streams, using only the standard library
+// and the hub's client package.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"trading/internal/client"
+)
+
+// Exit codes
+const (
+	exitOK     = 0
+	exitFailed = 1 // The hub answered with an error or could not be reached
+	exitUsage  = 2
+)
+
+// usage describes the commands
+const usage = `Usage: hubctl [flags] COMMAND [ARGS]
+
+Commands:
+  endpoints                      List the registered endpoints
+  get ENDPOINT [KEY=VALUE ...]   Call the REST form of an endpoint
+  tail ENDPOINT [KEY=VALUE ...]  Stream the events of an endpoint until it ends or Ctrl-C
+
+Run "hubctl COMMAND -h" for the flags of a command.
+
+Flags:
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run executes the command in args and returns the exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("hubctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	baseURL := flags.String("url", envOr("HUB_URL", "http://localhost:8080"), "Base URL of the hub (HUB_URL)")
+	apiKey := flags.String("api-key", os.Getenv("HUB_API_KEY"), "API key sent as X-API-Key (HUB_API_KEY)")
+	token := flags.String("token", os.Getenv("HUB_TOKEN"), "Bearer token sent as Authorization (HUB_TOKEN)")
+	timeout := flags.Duration("timeout", 30*time.Second, "Timeout of REST calls")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	headers := map[string]string{}
+	if *apiKey != "" {
+		headers["X-API-Key"] = *apiKey
+	}
+	if *token != "" {
+		headers["Authorization"] = "Bearer " + *token
+	}
+	c, err := client.New(client.Config{BaseURL: *baseURL, Headers: headers, Timeout: *timeout})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	command, args := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "endpoints":
+		return runEndpoints(ctx, c, args, stdout, stderr)
+	case "get":
+		return runGet(ctx, c, args, stdout, stderr)
+	case "tail":
+		return runTail(ctx, c, args, stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "Unknown command %q\n", command)
+		flags.Usage()
+		return exitUsage
+	}
+}
+
+// endpointInfo is an entry of the hub's /admin/endpoints listing
+type endpointInfo struct {
+	Name           string   `json:"name"`
+	REST           string   `json:"rest"`
+	Stream         string   `json:"stream"`
+	Scopes         []string `json:"scopes"`
+	Reconfigurable bool     `json:"reconfigurable"`
+	QuarantinedFor int      `json:"quarantined_for"`
+}
+
+// runEndpoints lists the registered endpoints
+func runEndpoints(ctx context.Context, c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("endpoints", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "table", "Output format: table or json")
+	if code, ok := parse(flags, args, 0); !ok {
+		return code
+	}
+
+	endpoints, err := client.Get[[]endpointInfo](ctx, c, "admin/endpoints", nil)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitFailed
+	}
+
+	switch *output {
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(endpoints)
+	case "table":
+		w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tREST\tSTREAM\tSCOPES\tRECONFIGURABLE\tSTATUS")
+		for _, e := range endpoints {
+			status := "ok"
+			if e.QuarantinedFor > 0 {
+				status = fmt.Sprintf("quarantined for %ds", e.QuarantinedFor)
+			}
+			scopes := strings.Join(e.Scopes, ",")
+			if scopes == "" {
+				scopes = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n", e.Name, e.REST, e.Stream, scopes, e.Reconfigurable, status)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintf(stderr, "Unknown output format %q\n", *output)
+		return exitUsage
+	}
+	return exitOK
+}
+
+// runGet calls the REST form of an endpoint and prints its data
+func runGet(ctx context.Context, c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: hubctl get [flags] ENDPOINT [KEY=VALUE ...]")
+		flags.PrintDefaults()
+	}
+	output := flags.String("o", "pretty", "Output format: pretty or json")
+	if code, ok := parse(flags, args, 1); !ok {
+		return code
+	}
+	if *output != "pretty" && *output != "json" {
+		fmt.Fprintf(stderr, "Unknown output format %q\n", *output)
+		return exitUsage
+	}
+	query, err := parseQuery(flags.Args()[1:])
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	data, err := c.Get(ctx, flags.Arg(0), query)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitFailed
+	}
+	// 204 No Content has no data to print
+	if data != nil {
+		printJSON(stdout, data, *output == "pretty")
+	}
+	return exitOK
+}
+
+// parse parses the flags of a command, which needs at least minArgs arguments. It returns
+// false with the exit code when the command must not run.
+func parse(flags *flag.FlagSet, args []string, minArgs int) (int, bool) {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if flags.NArg() < minArgs {
+		flags.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// parseQuery turns KEY=VALUE arguments into query parameters
+func parseQuery(args []string) (url.Values, error) {
+	query := url.Values{}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected KEY=VALUE, got %q", arg)
+		}
+		query.Add(key, value)
+	}
+	return query, nil
+}
+
+// printJSON prints data indented, or compact on one line
+func printJSON(w io.Writer, data json.RawMessage, pretty bool) {
+	var buf bytes.Buffer
+	var err error
+	if pretty {
+		err = json.Indent(&buf, data, "", "  ")
+	} else {
+		err = json.Compact(&buf, data)
+	}
+	if err != nil {
+		buf.Reset()
+		buf.Write(data)
+	}
+	buf.WriteByte('\n')
+	w.Write(buf.Bytes())
+}
+
+// envOr returns the environment variable key, or fallback if it is not set
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"trading/internal/hub"
+	"trading/internal/hub/hubtest"
+)
+
+// testAPIKey is the key hubctl sends to the test hub
+const testAPIKey = "cli.s3cret"
+
+// quotes are the events of quotesEndpoint
+var quotes = []string{
+	`{"symbol":"EURUSD","bid":1.0842,"time":"2025-03-01T12:00:00Z"}`,
+	`{"symbol":"GBPUSD","bid":1.2671,"time":"2025-03-01T12:00:01Z"}`,
+	`{"symbol":"EURUSD","bid":1.0843,"time":"2025-03-01T12:00:02Z"}`,
+}
+
+// quotesEndpoint answers REST calls with the first quote and streams all of them
+type quotesEndpoint struct{}
+
+// HandleSSE implements the Endpoint interface
+func (quotesEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/stream") {
+		w.Write([]byte(quotes[0]))
+		return
+	}
+	for _, quote := range quotes {
+		w.Write([]byte(quote))
+	}
+}
+
+// newTestHub serves the quotes endpoint behind an API key
+func newTestHub(t *testing.T) *hubtest.Server {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error hashing secret: %v", err)
+	}
+	apiKeys, err := hub.NewAPIKeyAuthenticator(hub.APIKeyConfig{Header: "X-API-Key", Keys: []hub.APIKey{{ID: "cli", Hash: string(hash)}}})
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+	return hubtest.NewServer(t, hubtest.WithEndpoint("quotes", quotesEndpoint{}), hubtest.WithAuthenticator(apiKeys))
+}
+
+// runCommand runs hubctl against server with args and returns the exit code and output
+func runCommand(server *hubtest.Server, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-url", server.URL, "-api-key", testAPIKey}, args...)
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	server := newTestHub(t)
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string // Expected in stdout
+		stderr string // Expected in stderr
+	}{
+		{name: "no command", code: exitUsage, stderr: "Usage: hubctl"},
+		{name: "help", args: []string{"-h"}, code: exitOK, stderr: "Usage: hubctl"},
+		{name: "unknown command", args: []string{"list"}, code: exitUsage, stderr: `Unknown command "list"`},
+		{name: "endpoints table", args: []string{"endpoints"}, code: exitOK, stdout: "NAME"},
+		{name: "endpoints unknown format", args: []string{"endpoints", "-o", "xml"}, code: exitUsage, stderr: `Unknown output format "xml"`},
+		{name: "get pretty", args: []string{"get", "quotes"}, code: exitOK, stdout: "{\n  \"symbol\": \"EURUSD\",\n  \"bid\": 1.0842,"},
+		{name: "get json", args: []string{"get", "-o", "json", "quotes"}, code: exitOK, stdout: quotes[0] + "\n"},
+		{name: "get without endpoint", args: []string{"get"}, code: exitUsage, stderr: "Usage: hubctl get"},
+		{name: "get invalid query", args: []string{"get", "quotes", "symbol"}, code: exitUsage, stderr: `expected KEY=VALUE, got "symbol"`},
+		{name: "get unknown format", args: []string{"get", "-o", "xml", "quotes"}, code: exitUsage, stderr: `Unknown output format "xml"`},
+		{name: "get unknown endpoint", args: []string{"get", "missing"}, code: exitFailed, stderr: "Error:"},
+		{name: "tail unknown format", args: []string{"tail", "-o", "xml", "quotes"}, code: exitUsage, stderr: `Unknown output format "xml"`},
+		{name: "tail invalid filter", args: []string{"tail", "-filter", "=EURUSD", "quotes"}, code: exitUsage, stderr: `filter "=EURUSD" has no path`},
+		{name: "tail unknown endpoint", args: []string{"tail", "missing"}, code: exitFailed, stderr: "Error:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runCommand(server, tt.args...)
+			if code != tt.code {
+				t.Errorf("Expected exit code %d, got %d (stderr %q)", tt.code, code, stderr)
+			}
+			if !strings.Contains(stdout, tt.stdout) {
+				t.Errorf("Expected %q in stdout, got %q", tt.stdout, stdout)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("Expected %q in stderr, got %q", tt.stderr, stderr)
+			}
+		})
+	}
+}
+
+func TestRun_Unauthorized(t *testing.T) {
+	server := newTestHub(t)
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-url", server.URL, "-api-key", "cli.wrong", "get", "quotes"}, &stdout, &stderr)
+	if code != exitFailed || !strings.Contains(stderr.String(), "401") {
+		t.Errorf("Expected exit code %d with a 401 error, got %d %q", exitFailed, code, stderr.String())
+	}
+}
+
+func TestRunEndpoints_JSON(t *testing.T) {
+	server := newTestHub(t)
+	code, stdout, stderr := runCommand(server, "endpoints", "-o", "json")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr)
+	}
+	var endpoints []endpointInfo
+	if err := json.Unmarshal([]byte(stdout), &endpoints); err != nil {
+		t.Fatalf("Error decoding %q: %v", stdout, err)
+	}
+	if len(endpoints) != 1 || endpoints[0].Name != "quotes" || endpoints[0].Stream != "/quotes/stream" {
+		t.Errorf("Unexpected endpoints %+v", endpoints)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+		err  bool
+	}{
+		{args: nil, want: ""},
+		{args: []string{"symbol=EURUSD", "depth=5"}, want: "depth=5&symbol=EURUSD"},
+		{args: []string{"symbol=EURUSD", "symbol=GBPUSD"}, want: "symbol=EURUSD&symbol=GBPUSD"},
+		{args: []string{"filter=a=b"}, want: "filter=a%3Db"},
+		{args: []string{"empty="}, want: "empty="},
+		{args: []string{"symbol"}, err: true},
+		{args: []string{"=EURUSD"}, err: true},
+	}
+	for _, tt := range tests {
+		query, err := parseQuery(tt.args)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseQuery(%q): expected an error", tt.args)
+			}
+			continue
+		}
+		if err != nil || query.Encode() != tt.want {
+			t.Errorf("parseQuery(%q) = %q, %v; expected %q", tt.args, query.Encode(), err, tt.want)
+		}
+	}
+}
+
+func TestPrintJSON(t *testing.T) {
+	tests := []struct {
+		data   string
+		pretty bool
+		want   string
+	}{
+		{data: `{"a": 1, "b": [1, 2]}`, pretty: false, want: "{\"a\":1,\"b\":[1,2]}\n"},
+		{data: `{"a":1}`, pretty: true, want: "{\n  \"a\": 1\n}\n"},
+		{data: `not json`, pretty: true, want: "not json\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		printJSON(&buf, json.RawMessage(tt.data), tt.pretty)
+		if buf.String() != tt.want {
+			t.Errorf("printJSON(%q, %t) = %q, expected %q", tt.data, tt.pretty, buf.String(), tt.want)
+		}
+	}
+}
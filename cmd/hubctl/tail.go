@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"trading/internal/client"
+	"trading/internal/recording"
+)
+
+// filters is a repeatable -filter flag
+type filters []filter
+
+// String implements flag.Value
+func (f *filters) String() string {
+	parts := make([]string, len(*f))
+	for i, filter := range *f {
+		parts[i] = filter.expr
+	}
+	return strings.Join(parts, " ")
+}
+
+// Set implements flag.Value
+func (f *filters) Set(expr string) error {
+	parsed, err := parseFilter(expr)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, parsed)
+	return nil
+}
+
+// filter keeps the events whose value at path exists, equals or differs from value
+type filter struct {
+	expr  string
+	path  string
+	op    string // "", "=" or "!="
+	value string
+}
+
+// parseFilter parses PATH, PATH=VALUE or PATH!=VALUE
+func parseFilter(expr string) (filter, error) {
+	f := filter{expr: expr, path: expr}
+	if path, value, ok := strings.Cut(expr, "!="); ok {
+		f.path, f.op, f.value = path, "!=", value
+	} else if path, value, ok := strings.Cut(expr, "="); ok {
+		f.path, f.op, f.value = path, "=", value
+	}
+	if strings.TrimPrefix(f.path, ".") == "" {
+		return f, fmt.Errorf("filter %q has no path", expr)
+	}
+	return f, nil
+}
+
+// match reports whether the decoded data passes the filter
+func (f filter) match(data any) bool {
+	value, ok := lookup(data, f.path)
+	switch f.op {
+	case "=":
+		return ok && format(value) == f.value
+	case "!=":
+		return !ok || format(value) != f.value
+	default:
+		return ok
+	}
+}
+
+// runTail streams the events of an endpoint until it ends or the context is canceled
+func runTail(ctx context.Context, c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: hubctl tail [flags] ENDPOINT [KEY=VALUE ...]")
+		fmt.Fprintln(stderr, "Paths are dotted, e.g. quote.bid or levels.0.price.")
+		flags.PrintDefaults()
+	}
+	maxCount := flags.Int("n", 0, "Number of events to receive (max_count); 0 for the hub's default")
+	output := flags.String("o", "pretty", "Output format: pretty, json or table")
+	var where filters
+	flags.Var(&where, "filter", "Only print events matching PATH, PATH=VALUE or PATH!=VALUE; repeatable")
+	columns := flags.String("columns", "", "Comma-separated paths of the table columns; default: the fields of the first event")
+	recordDir := flags.String("record", "", "Directory to save the stream in as a recording, which the replay endpoint can serve")
+	latencyPath := flags.String("latency", "", "Path of an event timestamp (RFC 3339, or Unix seconds or milliseconds); reports the delay until receipt")
+	if code, ok := parse(flags, args, 1); !ok {
+		return code
+	}
+	if *output != "pretty" && *output != "json" && *output != "table" {
+		fmt.Fprintf(stderr, "Unknown output format %q\n", *output)
+		return exitUsage
+	}
+	query, err := parseQuery(flags.Args()[1:])
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	if *maxCount > 0 {
+		query.Set("max_count", strconv.Itoa(*maxCount))
+	}
+
+	var record *recording.Writer
+	if *recordDir != "" {
+		record, err = recording.Create(*recordDir, recording.Header{Endpoint: flags.Arg(0), Query: query, Started: time.Now().UTC()})
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return exitFailed
+		}
+		defer record.Close()
+	}
+
+	stream, err := c.Stream(ctx, flags.Arg(0), query)
+	if err != nil {
+		// The hub answers a stream with its first event, so Ctrl-C may come before that
+		if ctx.Err() != nil {
+			return exitOK
+		}
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitFailed
+	}
+	defer stream.Close()
+
+	var table *tableWriter
+	if *output == "table" {
+		table = &tableWriter{w: stdout}
+		if *columns != "" {
+			table.columns = strings.Split(*columns, ",")
+		}
+	}
+	var latencies []time.Duration
+	defer func() {
+		if *latencyPath != "" {
+			fmt.Fprintln(stderr, summarize(latencies))
+		}
+	}()
+
+	for {
+		raw, err := client.NextData[json.RawMessage](stream)
+		received := time.Now()
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, context.Canceled):
+			return exitOK
+		case err != nil:
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return exitFailed
+		}
+
+		if record != nil {
+			if err := record.Write(recording.NewEvent(received.UTC(), raw)); err != nil {
+				fmt.Fprintf(stderr, "Error: %v\n", err)
+				return exitFailed
+			}
+		}
+
+		data, err := decode(raw)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return exitFailed
+		}
+		if *latencyPath != "" {
+			if value, ok := lookup(data, *latencyPath); ok {
+				if sent, ok := parseTimestamp(value); ok {
+					latencies = append(latencies, received.Sub(sent))
+				}
+			}
+		}
+		if !matchAll(where, data) {
+			continue
+		}
+
+		switch *output {
+		case "table":
+			table.write(data)
+		default:
+			printJSON(stdout, raw, *output == "pretty")
+		}
+	}
+}
+
+// matchAll reports whether data passes every filter
+func matchAll(where filters, data any) bool {
+	for _, f := range where {
+		if !f.match(data) {
+			return false
+		}
+	}
+	return true
+}
+
+// decode decodes event data keeping numbers exact
+func decode(raw json.RawMessage) (any, error) {
+	var data any
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("decoding event: %w", err)
+	}
+	return data, nil
+}
+
+// lookup returns the value at a dotted path such as quote.bid or levels.0.price. A leading
+// dot and bracketed indices, as in .levels[0].price, are accepted too.
+func lookup(data any, path string) (any, bool) {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(strings.TrimPrefix(path, "."))
+	if path == "" {
+		return data, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := data.(type) {
+		case map[string]any:
+			value, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			data = value
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			data = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// format renders a decoded value for filters and tables: strings and numbers as they are,
+// objects and arrays as compact JSON
+func format(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+// parseTimestamp reads an RFC 3339 string, or a number of Unix seconds or, above 1e12,
+// Unix milliseconds
+func parseTimestamp(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		if f > 1e12 {
+			return time.UnixMilli(int64(f)), true
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	}
+	return time.Time{}, false
+}
+
+// summarize reports the distribution of latencies
+func summarize(latencies []time.Duration) string {
+	if len(latencies) == 0 {
+		return "latency: no timestamped events"
+	}
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) time.Duration {
+		return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+	}
+	return fmt.Sprintf("latency: n=%d min=%s p50=%s p90=%s p99=%s max=%s",
+		len(sorted), sorted[0], percentile(0.50), percentile(0.90), percentile(0.99), sorted[len(sorted)-1])
+}
+
+// tableWriter prints events as rows of aligned columns. The widths are set by the header
+// and the first row, since later events are printed as they arrive.
+type tableWriter struct {
+	w       io.Writer
+	columns []string
+	widths  []int
+}
+
+// write prints the row of data, preceded by the header on the first call
+func (t *tableWriter) write(data any) {
+	if t.columns == nil {
+		t.columns = defaultColumns(data)
+	}
+	row := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		if value, ok := lookup(data, column); ok {
+			row[i] = format(value)
+		}
+	}
+
+	if t.widths == nil {
+		header := make([]string, len(t.columns))
+		t.widths = make([]int, len(t.columns))
+		for i, column := range t.columns {
+			header[i] = strings.ToUpper(strings.TrimPrefix(column, "."))
+			if header[i] == "" {
+				header[i] = "VALUE"
+			}
+			t.widths[i] = max(len(header[i]), len(row[i]))
+		}
+		t.printRow(header)
+	}
+	t.printRow(row)
+}
+
+// printRow prints cells padded to the column widths
+func (t *tableWriter) printRow(cells []string) {
+	var b strings.Builder
+	for i, cell := range cells {
+		if i == len(cells)-1 {
+			b.WriteString(cell)
+			break
+		}
+		fmt.Fprintf(&b, "%-*s  ", t.widths[i], cell)
+	}
+	b.WriteByte('\n')
+	io.WriteString(t.w, b.String())
+}
+
+// defaultColumns returns the sorted fields of an object, or the value itself otherwise
+func defaultColumns(data any) []string {
+	object, ok := data.(map[string]any)
+	if !ok || len(object) == 0 {
+		return []string{"."}
+	}
+	columns := make([]string, 0, len(object))
+	for key := range object {
+		columns = append(columns, key)
+	}
+	sort.Strings(columns)
+	return columns
+}
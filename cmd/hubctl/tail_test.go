@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"trading/internal/recording"
+)
+
+func TestRunTail(t *testing.T) {
+	server := newTestHub(t)
+
+	tests := []struct {
+		name   string
+		args   []string
+		stdout string
+	}{
+		{
+			name:   "json",
+			args:   []string{"tail", "-o", "json", "quotes"},
+			stdout: strings.Join(quotes, "\n") + "\n",
+		},
+		{
+			name:   "filter",
+			args:   []string{"tail", "-o", "json", "-filter", "symbol=EURUSD", "-filter", "bid!=1.0842", "quotes"},
+			stdout: quotes[2] + "\n",
+		},
+		{
+			name:   "table",
+			args:   []string{"tail", "-o", "table", "-columns", "symbol,bid", "quotes"},
+			stdout: "SYMBOL  BID\nEURUSD  1.0842\nGBPUSD  1.2671\nEURUSD  1.0843\n",
+		},
+		{
+			name:   "table default columns",
+			args:   []string{"tail", "-o", "table", "-filter", "symbol=GBPUSD", "quotes"},
+			stdout: "BID     SYMBOL  TIME\n1.2671  GBPUSD  2025-03-01T12:00:01Z\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runCommand(server, tt.args...)
+			if code != exitOK {
+				t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr)
+			}
+			if stdout != tt.stdout {
+				t.Errorf("Expected output %q, got %q", tt.stdout, stdout)
+			}
+		})
+	}
+}
+
+func TestRunTail_Latency(t *testing.T) {
+	server := newTestHub(t)
+	code, _, stderr := runCommand(server, "tail", "-o", "json", "-latency", "time", "quotes")
+	if code != exitOK || !strings.Contains(stderr, "latency: n=3 ") {
+		t.Errorf("Expected a summary of 3 latencies, got %d %q", code, stderr)
+	}
+}
+
+func TestRunTail_Record(t *testing.T) {
+	server := newTestHub(t)
+	dir := t.TempDir()
+	code, _, stderr := runCommand(server, "tail", "-o", "json", "-record", dir, "quotes", "symbol=EURUSD")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr)
+	}
+
+	// The file is a recording the replay endpoint can serve
+	files, err := filepath.Glob(filepath.Join(dir, "quotes-*.jsonl"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v %v", files, err)
+	}
+	rec, err := recording.Read(files[0])
+	if err != nil {
+		t.Fatalf("Error reading recording: %v", err)
+	}
+	if rec.Header.Endpoint != "quotes" || rec.Header.Query["symbol"][0] != "EURUSD" {
+		t.Errorf("Unexpected header %+v", rec.Header)
+	}
+	if len(rec.Events) != len(quotes) {
+		t.Fatalf("Expected %d events, got %d", len(quotes), len(rec.Events))
+	}
+	for i, event := range rec.Events {
+		if string(event.Payload()) != quotes[i] || event.Time.IsZero() {
+			t.Errorf("Unexpected event %d: %+v", i, event)
+		}
+	}
+
+	// A directory that cannot be created fails the command
+	blocked := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocked, nil, 0o600); err != nil {
+		t.Fatalf("Error writing file: %v", err)
+	}
+	if code, _, _ := runCommand(server, "tail", "-record", filepath.Join(blocked, "dir"), "quotes"); code != exitFailed {
+		t.Errorf("Expected exit code %d, got %d", exitFailed, code)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	data, err := decode(json.RawMessage(`{"symbol":"EURUSD","bid":1.0842,"levels":[{"price":1.1}],"halted":false}`))
+	if err != nil {
+		t.Fatalf("Error decoding: %v", err)
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{expr: "symbol", want: true},
+		{expr: "ask", want: false},
+		{expr: "symbol=EURUSD", want: true},
+		{expr: "symbol=GBPUSD", want: false},
+		{expr: "symbol!=GBPUSD", want: true},
+		{expr: "ask!=1", want: true},
+		{expr: "bid=1.0842", want: true},
+		{expr: "levels.0.price=1.1", want: true},
+		{expr: ".levels[0].price=1.1", want: true},
+		{expr: "halted=false", want: true},
+	}
+	for _, tt := range tests {
+		f, err := parseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("parseFilter(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := f.match(data); got != tt.want {
+			t.Errorf("Filter %q matched %t, expected %t", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", ".", "=x", "!=x"} {
+		if _, err := parseFilter(expr); err == nil {
+			t.Errorf("parseFilter(%q): expected an error", expr)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	data, err := decode(json.RawMessage(`{"quote":{"bid":1.5},"levels":[{"price":1},{"price":2}]}`))
+	if err != nil {
+		t.Fatalf("Error decoding: %v", err)
+	}
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{path: "quote.bid", want: "1.5", ok: true},
+		{path: "levels.1.price", want: "2", ok: true},
+		{path: "levels[1].price", want: "2", ok: true},
+		{path: "levels", want: `[{"price":1},{"price":2}]`, ok: true},
+		{path: ".", want: `{"levels":[{"price":1},{"price":2}],"quote":{"bid":1.5}}`, ok: true},
+		{path: "levels.2.price"},
+		{path: "levels.x"},
+		{path: "quote.bid.value"},
+		{path: "missing"},
+	}
+	for _, tt := range tests {
+		value, ok := lookup(data, tt.path)
+		if ok != tt.ok || (ok && format(value) != tt.want) {
+			t.Errorf("lookup(%q) = %v, %t; expected %s, %t", tt.path, value, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{value: "EURUSD", want: "EURUSD"},
+		{value: json.Number("1.0842"), want: "1.0842"},
+		{value: nil, want: "null"},
+		{value: true, want: "true"},
+		{value: map[string]any{"a": json.Number("1")}, want: `{"a":1}`},
+		{value: []any{"a", json.Number("2")}, want: `["a",2]`},
+	}
+	for _, tt := range tests {
+		if got := format(tt.value); got != tt.want {
+			t.Errorf("format(%v) = %q, expected %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		value any
+		want  time.Time
+		ok    bool
+	}{
+		{value: "2025-03-01T12:00:00.5Z", want: time.Date(2025, 3, 1, 12, 0, 0, 500_000_000, time.UTC), ok: true},
+		{value: json.Number("1740830400"), want: time.Unix(1740830400, 0), ok: true},
+		{value: json.Number("1740830400.25"), want: time.Unix(1740830400, 250_000_000), ok: true},
+		{value: json.Number("1740830400123"), want: time.UnixMilli(1740830400123), ok: true},
+		{value: "yesterday"},
+		{value: true},
+	}
+	for _, tt := range tests {
+		got, ok := parseTimestamp(tt.value)
+		if ok != tt.ok || (ok && !got.Equal(tt.want)) {
+			t.Errorf("parseTimestamp(%v) = %v, %t; expected %v, %t", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	if got := summarize(nil); got != "latency: no timestamped events" {
+		t.Errorf("Unexpected summary %q", got)
+	}
+
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		// Out of order, so that sorting is covered
+		latencies[i] = time.Duration(100-i) * time.Millisecond
+	}
+	want := "latency: n=100 min=1ms p50=50ms p90=90ms p99=99ms max=100ms"
+	if got := summarize(latencies); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if latencies[0] != 100*time.Millisecond {
+		t.Error("Expected the latencies not to be reordered")
+	}
+}
+
+func TestTableWriter(t *testing.T) {
+	var buf bytes.Buffer
+	table := &tableWriter{w: &buf}
+	for _, raw := range []string{`"first"`, `"second value"`} {
+		data, err := decode(json.RawMessage(raw))
+		if err != nil {
+			t.Fatalf("Error decoding: %v", err)
+		}
+		table.write(data)
+	}
+	// A value that is not an object is a single column; widths are set by the first row
+	want := "VALUE\nfirst\nsecond value\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	buf.Reset()
+	table = &tableWriter{w: &buf, columns: []string{"a", "missing", "b"}}
+	data, _ := decode(json.RawMessage(`{"a":"x","b":[1,2]}`))
+	table.write(data)
+	want = "A  MISSING  B\nx           [1,2]\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+}
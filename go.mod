@@ -1,3 +1,5 @@
 module trading
 
 go 1.23.6
+
+require golang.org/x/crypto v0.40.0
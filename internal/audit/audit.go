@@ -0,0 +1,338 @@
+// Package audit records security-relevant events in append-only JSON Lines files.
+// Every entry carries the SHA-256 hash of the entry before it, so that editing, removing
+// or reordering entries breaks the chain and is detected by Verify.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GenesisHash is the previous hash of the first entry of a log
+const GenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Redacted replaces the value of a sensitive detail
+const Redacted = "[REDACTED]"
+
+// maxEntrySize is the largest encoded entry, newline included
+const maxEntrySize = 64 << 10
+
+// rotatedTimeFormat is the timestamp in the name of a rotated file; it sorts chronologically
+const rotatedTimeFormat = "20060102T150405.000000000Z"
+
+// DefaultRedactFields are the detail keys whose values are never written. A key is
+// redacted when it contains one of them, ignoring case.
+var DefaultRedactFields = []string{"password", "secret", "token", "authorization", "api_key", "apikey", "signature", "cookie"}
+
+// Event represents something that happened and must be kept for compliance
+type Event struct {
+	Type     string            `json:"type"`              // Such as "request" or "order"
+	Actor    string            `json:"actor,omitempty"`   // Principal that caused the event
+	Action   string            `json:"action"`            // Such as "POST" or "submit"
+	Resource string            `json:"resource"`          // Such as the endpoint name or an order ID
+	Outcome  string            `json:"outcome"`           // Such as "200" or "rejected"
+	Details  map[string]string `json:"details,omitempty"` // Sensitive keys are redacted
+}
+
+// Entry represents one line of the audit log
+type Entry struct {
+	Seq      uint64    `json:"seq"`
+	Time     time.Time `json:"time"`
+	PrevHash string    `json:"prev_hash"`
+	Event
+	Hash string `json:"hash,omitempty"` // SHA-256 of the entry encoded without this field
+}
+
+// computeHash returns the hash of the entry encoded without its hash
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Recorder is the interface the hub and endpoints write audit events to
+type Recorder interface {
+	Record(ctx context.Context, event Event) error
+}
+
+// discard is a Recorder that drops every event
+type discard struct{}
+
+// Record implements the Recorder interface
+func (discard) Record(context.Context, Event) error { return nil }
+
+// Discard is a Recorder that drops every event, used when auditing is off
+var Discard Recorder = discard{}
+
+// contextKey is the type of the context keys of this package
+type contextKey int
+
+// recorderKey is the context key of the request's Recorder
+const recorderKey contextKey = 0
+
+// ContextWithRecorder returns a copy of ctx carrying recorder
+func ContextWithRecorder(ctx context.Context, recorder Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey, recorder)
+}
+
+// FromContext returns the Recorder carried by ctx, or Discard if there is none
+func FromContext(ctx context.Context) Recorder {
+	if recorder, ok := ctx.Value(recorderKey).(Recorder); ok {
+		return recorder
+	}
+	return Discard
+}
+
+// Config represents the location and rotation of an audit log
+type Config struct {
+	Path         string   // Active file; rotated files are written next to it
+	MaxBytes     int64    // Size at which the file is rotated; 0 never rotates
+	RedactFields []string // Default: DefaultRedactFields
+	Sync         bool     // Flush every entry to disk before Record returns
+}
+
+// Logger appends hash-chained entries to an audit log. It is safe for concurrent use.
+type Logger struct {
+	mu       sync.Mutex
+	config   Config
+	redact   []string
+	file     *os.File
+	size     int64
+	seq      uint64
+	prevHash string
+	now      func() time.Time
+}
+
+// Open opens the audit log at config.Path, creating it if needed, and continues the chain
+// from its last entry, or from the newest rotated file if the active one is missing or empty
+func Open(config Config) (*Logger, error) {
+	if config.Path == "" {
+		return nil, errors.New("audit: path is required")
+	}
+	if config.MaxBytes < 0 {
+		return nil, fmt.Errorf("audit: max bytes %d must not be negative", config.MaxBytes)
+	}
+	redact := config.RedactFields
+	if redact == nil {
+		redact = DefaultRedactFields
+	}
+
+	l := &Logger{config: config, prevHash: GenesisHash, now: time.Now}
+	for _, field := range redact {
+		l.redact = append(l.redact, strings.ToLower(field))
+	}
+
+	files, err := Files(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	// The active file is empty right after a rotation, so the chain continues from the
+	// newest file that has an entry
+	for i := len(files) - 1; i >= 0; i-- {
+		last, err := lastEntry(files[i])
+		if err != nil {
+			return nil, err
+		}
+		if last != nil {
+			l.seq, l.prevHash = last.Seq, last.Hash
+			break
+		}
+	}
+
+	if err := l.openFile(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// openFile opens the active file for appending
+func (l *Logger) openFile() error {
+	file, err := os.OpenFile(l.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit: opening log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("audit: opening log: %w", err)
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// Record implements the Recorder interface. Sensitive details are redacted before the
+// entry is written.
+func (l *Logger) Record(ctx context.Context, event Event) error {
+	event.Details = l.redactDetails(event.Details)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return errors.New("audit: log is closed")
+	}
+
+	entry := Entry{Seq: l.seq + 1, Time: l.now().UTC(), PrevHash: l.prevHash, Event: event}
+	hash, err := entry.computeHash()
+	if err != nil {
+		return fmt.Errorf("audit: encoding entry: %w", err)
+	}
+	entry.Hash = hash
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("audit: encoding entry: %w", err)
+	}
+	line = append(line, '\n')
+	if len(line) > maxEntrySize {
+		return fmt.Errorf("audit: entry of %d bytes is larger than %d bytes", len(line), maxEntrySize)
+	}
+
+	// A failed rotation keeps the active file, so that the entry is still recorded
+	var rotateErr error
+	if l.config.MaxBytes > 0 && l.size > 0 && l.size+int64(len(line)) > l.config.MaxBytes {
+		rotateErr = l.rotate()
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("audit: writing entry: %w", err)
+	}
+	if l.config.Sync {
+		if err := l.file.Sync(); err != nil {
+			return fmt.Errorf("audit: syncing log: %w", err)
+		}
+	}
+	l.seq, l.prevHash = entry.Seq, entry.Hash
+	return rotateErr
+}
+
+// redactDetails returns a copy of details with the sensitive values replaced
+func (l *Logger) redactDetails(details map[string]string) map[string]string {
+	if len(details) == 0 {
+		return nil
+	}
+	redacted := make(map[string]string, len(details))
+	for key, value := range details {
+		redacted[key] = value
+		lower := strings.ToLower(key)
+		for _, field := range l.redact {
+			if strings.Contains(lower, field) {
+				redacted[key] = Redacted
+				break
+			}
+		}
+	}
+	return redacted
+}
+
+// rotate renames the active file with a timestamp and starts a new one. The chain
+// continues across files. The old file is only closed once the new one is open, so that
+// on failure the log keeps appending to the active file and rotates on a later entry.
+func (l *Logger) rotate() error {
+	ext := filepath.Ext(l.config.Path)
+	rotated := strings.TrimSuffix(l.config.Path, ext) + "-" + l.now().UTC().Format(rotatedTimeFormat) + ext
+	if err := os.Rename(l.config.Path, rotated); err != nil {
+		return fmt.Errorf("audit: rotating log: %w", err)
+	}
+
+	previous := l.file
+	if err := l.openFile(); err != nil {
+		// Put the file back, so that the open handle is the active file again
+		if renameErr := os.Rename(rotated, l.config.Path); renameErr != nil {
+			err = errors.Join(err, renameErr)
+		}
+		return fmt.Errorf("audit: rotating log: %w", err)
+	}
+	if err := previous.Close(); err != nil {
+		return fmt.Errorf("audit: closing rotated log: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the log
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := errors.Join(l.file.Sync(), l.file.Close())
+	l.file = nil
+	return err
+}
+
+// Files returns the files of the audit log at path in chain order: the rotated files,
+// oldest first, then the active file if it exists
+func Files(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	rotated, err := filepath.Glob(globEscape(strings.TrimSuffix(path, ext)) + "-*" + globEscape(ext))
+	if err != nil {
+		return nil, fmt.Errorf("audit: listing rotated files: %w", err)
+	}
+	// Glob returns the names sorted, and the timestamp sorts chronologically
+	files := rotated
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	return files, nil
+}
+
+// globEscape escapes the characters filepath.Match treats as special
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// lastEntry returns the last entry of a file, or nil if the file is empty
+func lastEntry(path string) (*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	// An entry is never larger than maxEntrySize, so the tail holds the whole last line
+	offset := max(info.Size()-maxEntrySize-1, 0)
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("audit: reading %s: %w", path, err)
+	}
+
+	tail = bytes.TrimRight(tail, "\n")
+	if len(tail) == 0 {
+		return nil, nil
+	}
+	line := tail[bytes.LastIndexByte(tail, '\n')+1:]
+	var entry Entry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return nil, fmt.Errorf("audit: the last entry of %s is unreadable: %w", path, err)
+	}
+	return &entry, nil
+}
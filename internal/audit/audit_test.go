@@ -0,0 +1,279 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readEntries decodes every entry of a file
+func readEntries(t *testing.T, path string) []Entry {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Error decoding entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// openTestLogger opens a logger in a temporary directory with a fixed clock
+func openTestLogger(t *testing.T, config Config) *Logger {
+	t.Helper()
+	if config.Path == "" {
+		config.Path = filepath.Join(t.TempDir(), "audit.jsonl")
+	}
+	l, err := Open(config)
+	if err != nil {
+		t.Fatalf("Error opening audit log: %v", err)
+	}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestLogger_HashChain(t *testing.T) {
+	l := openTestLogger(t, Config{})
+	ctx := context.Background()
+
+	for _, action := range []string{"submit", "amend", "cancel"} {
+		if err := l.Record(ctx, Event{Type: "order", Actor: "alice", Action: action, Resource: "order-1", Outcome: "accepted"}); err != nil {
+			t.Fatalf("Error recording: %v", err)
+		}
+	}
+
+	entries := readEntries(t, l.config.Path)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	prev := GenesisHash
+	for i, entry := range entries {
+		if entry.Seq != uint64(i+1) || entry.PrevHash != prev {
+			t.Errorf("Entry %d: expected seq %d after %s, got %d after %s", i, i+1, prev, entry.Seq, entry.PrevHash)
+		}
+		if hash, _ := entry.computeHash(); hash != entry.Hash {
+			t.Errorf("Entry %d: hash %s does not match %s", i, entry.Hash, hash)
+		}
+		prev = entry.Hash
+	}
+	if entries[1].Action != "amend" || entries[1].Actor != "alice" {
+		t.Errorf("Unexpected entry %+v", entries[1])
+	}
+}
+
+func TestLogger_Redaction(t *testing.T) {
+	l := openTestLogger(t, Config{})
+
+	err := l.Record(context.Background(), Event{Type: "request", Details: map[string]string{
+		"path":          "/orders",
+		"Authorization": "Bearer abc",
+		"api_key_id":    "desk1",
+		"client_secret": "s3cr3t",
+	}})
+	if err != nil {
+		t.Fatalf("Error recording: %v", err)
+	}
+
+	details := readEntries(t, l.config.Path)[0].Details
+	if details["path"] != "/orders" {
+		t.Errorf("Expected non-sensitive details to be kept, got %v", details)
+	}
+	for _, key := range []string{"Authorization", "api_key_id", "client_secret"} {
+		if details[key] != Redacted {
+			t.Errorf("Expected %s to be redacted, got %q", key, details[key])
+		}
+	}
+	data, _ := os.ReadFile(l.config.Path)
+	if strings.Contains(string(data), "s3cr3t") || strings.Contains(string(data), "abc") {
+		t.Error("Expected no sensitive value in the file")
+	}
+}
+
+func TestLogger_Rotation(t *testing.T) {
+	l := openTestLogger(t, Config{MaxBytes: 600})
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		if err := l.Record(ctx, Event{Type: "order", Action: "submit", Resource: "order", Outcome: "accepted"}); err != nil {
+			t.Fatalf("Error recording: %v", err)
+		}
+	}
+
+	files, err := Files(l.config.Path)
+	if err != nil {
+		t.Fatalf("Error listing files: %v", err)
+	}
+	if len(files) < 3 || files[len(files)-1] != l.config.Path {
+		t.Fatalf("Expected rotated files before the active one, got %v", files)
+	}
+	for _, file := range files {
+		if info, _ := os.Stat(file); info.Size() > 600 {
+			t.Errorf("Expected %s to be at most 600 bytes, got %d", file, info.Size())
+		}
+	}
+	if count, err := Verify(l.config.Path); err != nil || count != 10 {
+		t.Errorf("Expected the chain to continue across files, got %d %v", count, err)
+	}
+}
+
+func TestLogger_RotationFailure(t *testing.T) {
+	l := openTestLogger(t, Config{MaxBytes: 600})
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+	event := Event{Type: "order", Action: "submit", Resource: "order", Outcome: "accepted"}
+
+	// A directory in the way of the rotated name makes the rename fail
+	ext := filepath.Ext(l.config.Path)
+	blocked := strings.TrimSuffix(l.config.Path, ext) + "-" + now.Format(rotatedTimeFormat) + ext
+	if err := os.MkdirAll(filepath.Join(blocked, "taken"), 0o700); err != nil {
+		t.Fatalf("Error creating directory: %v", err)
+	}
+
+	var failures int
+	for i := 0; i < 6; i++ {
+		if err := l.Record(ctx, event); err != nil {
+			if !strings.Contains(err.Error(), "rotating log") {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			failures++
+		}
+	}
+	if failures == 0 {
+		t.Fatal("Expected the rotation to fail")
+	}
+
+	// Every entry was still recorded, and rotation resumes once it can
+	if err := os.RemoveAll(blocked); err != nil {
+		t.Fatalf("Error removing directory: %v", err)
+	}
+	l.now = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+	for i := 0; i < 4; i++ {
+		if err := l.Record(ctx, event); err != nil {
+			t.Fatalf("Error recording after the failure: %v", err)
+		}
+	}
+	files, err := Files(l.config.Path)
+	if err != nil || len(files) < 2 {
+		t.Fatalf("Expected rotated files and the active one, got %v %v", files, err)
+	}
+	if count, err := Verify(l.config.Path); err != nil || count != 10 {
+		t.Errorf("Expected all 10 entries in one chain, got %d %v", count, err)
+	}
+}
+
+func TestLogger_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	ctx := context.Background()
+
+	l := openTestLogger(t, Config{Path: path})
+	l.Record(ctx, Event{Type: "order", Action: "submit"})
+	l.Record(ctx, Event{Type: "order", Action: "cancel"})
+	l.Close()
+
+	l = openTestLogger(t, Config{Path: path})
+	if err := l.Record(ctx, Event{Type: "order", Action: "submit"}); err != nil {
+		t.Fatalf("Error recording: %v", err)
+	}
+	if count, err := Verify(path); err != nil || count != 3 {
+		t.Errorf("Expected the chain to continue after reopening, got %d %v", count, err)
+	}
+}
+
+func TestLogger_ReopenAfterRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	ctx := context.Background()
+
+	l := openTestLogger(t, Config{Path: path, MaxBytes: 600})
+	for i := 0; i < 4; i++ {
+		l.Record(ctx, Event{Type: "order", Action: "submit"})
+	}
+	l.Close()
+
+	// A rotation leaves an empty active file until the next entry is written
+	ext := filepath.Ext(path)
+	rotated := strings.TrimSuffix(path, ext) + "-" + time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC).Format(rotatedTimeFormat) + ext
+	if err := os.Rename(path, rotated); err != nil {
+		t.Fatalf("Error rotating: %v", err)
+	}
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("Error creating the active file: %v", err)
+	}
+
+	l = openTestLogger(t, Config{Path: path, MaxBytes: 600})
+	if err := l.Record(ctx, Event{Type: "order", Action: "cancel"}); err != nil {
+		t.Fatalf("Error recording: %v", err)
+	}
+	if count, err := Verify(path); err != nil || count != 5 {
+		t.Errorf("Expected the chain to continue from the rotated file, got %d %v", count, err)
+	}
+}
+
+func TestLogger_Concurrent(t *testing.T) {
+	l := openTestLogger(t, Config{MaxBytes: 2048})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				l.Record(context.Background(), Event{Type: "request", Action: "GET"})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if count, err := Verify(l.config.Path); err != nil || count != 160 {
+		t.Errorf("Expected 160 chained entries, got %d %v", count, err)
+	}
+}
+
+func TestLogger_Errors(t *testing.T) {
+	if _, err := Open(Config{}); err == nil {
+		t.Error("Expected an error without a path")
+	}
+
+	l := openTestLogger(t, Config{})
+	err := l.Record(context.Background(), Event{Details: map[string]string{"note": strings.Repeat("x", maxEntrySize)}})
+	if err == nil {
+		t.Error("Expected an oversized entry to be rejected")
+	}
+	l.Close()
+	if err := l.Record(context.Background(), Event{}); err == nil {
+		t.Error("Expected an error after closing")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != Discard {
+		t.Error("Expected Discard without a recorder")
+	}
+	l := openTestLogger(t, Config{})
+	if FromContext(ContextWithRecorder(context.Background(), l)) != l {
+		t.Error("Expected the recorder from the context")
+	}
+}
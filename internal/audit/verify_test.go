@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// writeTestLog records five entries and returns the path and the lines of the log
+func writeTestLog(t *testing.T) (string, []string) {
+	t.Helper()
+	l := openTestLogger(t, Config{})
+	for _, outcome := range []string{"200", "200", "403", "200", "201"} {
+		if err := l.Record(context.Background(), Event{Type: "request", Actor: "alice", Action: "POST", Resource: "orders", Outcome: outcome}); err != nil {
+			t.Fatalf("Error recording: %v", err)
+		}
+	}
+	l.Close()
+
+	data, err := os.ReadFile(l.config.Path)
+	if err != nil {
+		t.Fatalf("Error reading log: %v", err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	return l.config.Path, lines[:len(lines)-1]
+}
+
+func TestVerify(t *testing.T) {
+	path, lines := writeTestLog(t)
+	if count, err := Verify(path); err != nil || count != 5 {
+		t.Fatalf("Expected an intact log of 5 entries, got %d %v", count, err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(lines []string) []string
+		line   int
+		reason string
+	}{
+		{"edited field", func(lines []string) []string {
+			lines[2] = strings.Replace(lines[2], `"outcome":"403"`, `"outcome":"200"`, 1)
+			return lines
+		}, 3, "hash"},
+		{"reformatted", func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], `"seq":2,`, `"seq": 2,`, 1)
+			return lines
+		}, 2, "modified"},
+		{"added field", func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], `{"seq"`, `{"note":"x","seq"`, 1)
+			return lines
+		}, 2, "not valid"},
+		{"removed entry", func(lines []string) []string {
+			return append(lines[:2:2], lines[3:]...)
+		}, 3, "sequence"},
+		{"swapped entries", func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		}, 2, "sequence"},
+		{"removed first entry", func(lines []string) []string {
+			return lines[1:]
+		}, 1, "sequence"},
+		{"truncated entry", func(lines []string) []string {
+			lines[4] = lines[4][:20]
+			return lines
+		}, 5, "newline"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := tt.tamper(append([]string(nil), lines...))
+			if err := os.WriteFile(path, []byte(strings.Join(tampered, "")), 0o600); err != nil {
+				t.Fatalf("Error writing log: %v", err)
+			}
+
+			_, err := Verify(path)
+			var verifyErr *VerifyError
+			if !errors.As(err, &verifyErr) {
+				t.Fatalf("Expected a VerifyError, got %v", err)
+			}
+			if verifyErr.Line != tt.line || !strings.Contains(verifyErr.Reason, tt.reason) {
+				t.Errorf("Expected line %d to mention %q, got %v", tt.line, tt.reason, verifyErr)
+			}
+		})
+	}
+}
+
+func TestVerify_Missing(t *testing.T) {
+	if _, err := Verify(t.TempDir() + "/audit.jsonl"); err == nil {
+		t.Error("Expected an error for a missing log")
+	}
+}
@@ -0,0 +1,243 @@
+// Package client calls hub endpoints from Go. REST calls return the data of the hub's
+// envelope and turn JSON:API errors into *APIError; streams parse Server-Sent Events and
+// reconnect with Last-Event-ID and backoff when the connection drops. It only depends on
+// the standard library, so that tools and services can use it without the hub's packages.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxResponseSize bounds the REST responses the client reads
+const maxResponseSize = 16 << 20
+
+// headerIdempotencyKey is the header the hub deduplicates mutating requests by
+const headerIdempotencyKey = "Idempotency-Key"
+
+// Config represents the settings of a Client
+type Config struct {
+	BaseURL    string            // Required, e.g. https://hub.example.com
+	Headers    map[string]string // Sent with every request, e.g. Authorization or X-API-Key
+	HTTPClient *http.Client      // Default: a client without a timeout, so that streams stay open
+	Timeout    time.Duration     // Default: 30s; bounds REST calls without a context deadline
+
+	// Reconnection of streams
+	MinBackoff time.Duration // Default: 1s, or the retry the server sends
+	MaxBackoff time.Duration // Default: 30s
+	MaxRetries int           // Default: 0 (retry until the context ends); consecutive failed attempts
+}
+
+// Client calls the endpoints of one hub. It is safe for concurrent use.
+type Client struct {
+	config  Config
+	baseURL *url.URL
+	http    *http.Client
+}
+
+// New creates a Client for the hub at config.BaseURL
+func New(config Config) (*Client, error) {
+	if config.BaseURL == "" {
+		return nil, errors.New("client: base URL is required")
+	}
+	baseURL, err := url.Parse(strings.TrimSuffix(config.BaseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL: %w", err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("client: base URL %q must start with http:// or https://", config.BaseURL)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{}
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = time.Second
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = max(30*time.Second, config.MinBackoff)
+	}
+
+	return &Client{config: config, baseURL: baseURL, http: config.HTTPClient}, nil
+}
+
+// Error represents one error of a JSON:API error response
+type Error struct {
+	Status string `json:"status"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+// errorResponse represents a JSON:API error response
+type errorResponse struct {
+	Errors []Error `json:"errors"`
+}
+
+// APIError is a JSON:API error response from the hub, or an error status without one
+type APIError struct {
+	StatusCode int
+	Errors     []Error
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("hub returned status %d", e.StatusCode)
+	}
+	details := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		details[i] = err.Title + ": " + err.Detail
+	}
+	return fmt.Sprintf("hub returned status %d: %s", e.StatusCode, strings.Join(details, "; "))
+}
+
+// StatusCode returns the HTTP status of an *APIError in err's chain, or 0 if there is none
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// URL returns the absolute URL of path with the given query
+func (c *Client) URL(path string, query url.Values) string {
+	u := *c.baseURL
+	u.Path += "/" + strings.TrimPrefix(path, "/")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// NewRequest creates a request to path carrying the configured headers
+func (c *Client) NewRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL(path, query), body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	for k, v := range c.config.Headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// Do sends a REST request and returns the data of the response envelope, or nil for
+// 204 No Content
+func (c *Client) Do(req *http.Request) (json.RawMessage, error) {
+	if _, ok := req.Context().Deadline(); !ok {
+		ctx, cancel := context.WithTimeout(req.Context(), c.config.Timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading response of %s %s: %w", req.Method, req.URL.Path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, decodeError(resp.StatusCode, body)
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	data, err := unwrap(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, err)
+	}
+	return data, nil
+}
+
+// Get calls the REST form of an endpoint and returns its data
+func (c *Client) Get(ctx context.Context, path string, query url.Values) (json.RawMessage, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Post sends body encoded as JSON to an endpoint and returns its data. A non-empty
+// idempotencyKey is sent as Idempotency-Key, so that the call can be retried safely.
+func (c *Client) Post(ctx context.Context, path string, body any, idempotencyKey string) (json.RawMessage, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("encoding request body: %w", err)
+	}
+	req, err := c.NewRequest(ctx, http.MethodPost, path, nil, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set(headerIdempotencyKey, idempotencyKey)
+	}
+	return c.Do(req)
+}
+
+// unwrap returns the data of the hub's {"data": ...} envelope
+func unwrap(body []byte) (json.RawMessage, error) {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Data == nil {
+		return nil, fmt.Errorf("expected a data envelope, got %.200q", body)
+	}
+	return envelope.Data, nil
+}
+
+// decodeError turns an error response into an *APIError
+func decodeError(status int, body []byte) *APIError {
+	var response errorResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return &APIError{StatusCode: status}
+	}
+	return &APIError{StatusCode: status, Errors: response.Errors}
+}
+
+// Decode decodes the data returned by Get, Do or Post into T
+func Decode[T any](data json.RawMessage) (T, error) {
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("decoding %T: %w", v, err)
+	}
+	return v, nil
+}
+
+// Get calls the REST form of an endpoint and decodes its data into T
+func Get[T any](ctx context.Context, c *Client, path string, query url.Values) (T, error) {
+	data, err := c.Get(ctx, path, query)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return Decode[T](data)
+}
+
+// retryAfter returns the delay of a Retry-After header in seconds, or 0
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
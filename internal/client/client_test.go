@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"trading/internal/clock"
+	"trading/internal/date"
+	"trading/internal/hub"
+	"trading/internal/hub/hubtest"
+)
+
+// echoEndpoint answers with the request body, or a 400 without one
+type echoEndpoint struct {
+	calls atomic.Int32
+}
+
+// HandleSSE implements the hub.Endpoint interface
+func (e *echoEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	e.calls.Add(1)
+	body, _ := io.ReadAll(r.Body)
+	if len(body) == 0 {
+		hub.WriteError(w, http.StatusBadRequest, "Bad Request", "a body is required")
+		return
+	}
+	w.Write(body)
+}
+
+// keyAuthenticator accepts requests with the API key "secret"
+type keyAuthenticator struct{}
+
+// Authenticate implements hub.Authenticator
+func (keyAuthenticator) Authenticate(r *http.Request) (*hub.Principal, error) {
+	if r.Header.Get("X-API-Key") != "secret" {
+		return nil, hub.ErrNoCredentials
+	}
+	return &hub.Principal{ID: "service"}, nil
+}
+
+// Challenge implements hub.Authenticator
+func (keyAuthenticator) Challenge() string {
+	return "ApiKey"
+}
+
+// newClient creates a Client for server with fast reconnection
+func newClient(t *testing.T, baseURL string, headers map[string]string) *Client {
+	t.Helper()
+	c, err := New(Config{BaseURL: baseURL, Headers: headers, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	return c
+}
+
+func TestNew(t *testing.T) {
+	for _, baseURL := range []string{"", "localhost:8080", "ftp://hub"} {
+		if _, err := New(Config{BaseURL: baseURL}); err == nil {
+			t.Errorf("Expected base URL %q to be rejected", baseURL)
+		}
+	}
+
+	c, err := New(Config{BaseURL: "https://hub.example.com/api/"})
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	if got := c.URL("/date", url.Values{"max_count": {"2"}}); got != "https://hub.example.com/api/date?max_count=2" {
+		t.Errorf("Unexpected URL %s", got)
+	}
+}
+
+func TestClient_Get(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	server := hubtest.NewServer(t,
+		hubtest.WithEndpoint("date", date.New(date.Config{Clock: fake})),
+	)
+	c := newClient(t, server.URL, nil)
+
+	go func() {
+		fake.BlockUntil(1)
+		fake.Advance(time.Second)
+	}()
+	got, err := Get[date.DateResponse](context.Background(), c, "date", nil)
+	if err != nil {
+		t.Fatalf("Error getting the date: %v", err)
+	}
+	if got.UTC != "2024-03-01T12:00:01Z" {
+		t.Errorf("Expected 2024-03-01T12:00:01Z, got %q", got.UTC)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	server := hubtest.NewServer(t, hubtest.WithEndpoint("echo", &echoEndpoint{}))
+	c := newClient(t, server.URL, nil)
+
+	_, err := c.Get(context.Background(), "echo", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || len(apiErr.Errors) != 1 || apiErr.Errors[0].Detail != "a body is required" {
+		t.Errorf("Unexpected error %+v", apiErr)
+	}
+	if err.Error() != "hub returned status 400: Bad Request: a body is required" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+
+	if _, err := c.Get(context.Background(), "missing", nil); StatusCode(err) != http.StatusNotFound {
+		t.Errorf("Expected a 404, got %v", err)
+	}
+}
+
+// silentEndpoint writes nothing
+type silentEndpoint struct{}
+
+// HandleSSE implements the hub.Endpoint interface
+func (silentEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {}
+
+func TestClient_NoContent(t *testing.T) {
+	server := hubtest.NewServer(t,
+		hubtest.WithEndpoint("ack", silentEndpoint{}, hub.WithNoContent()),
+		hubtest.WithEndpoint("lookup", silentEndpoint{}),
+	)
+	c := newClient(t, server.URL, nil)
+
+	if data, err := c.Get(context.Background(), "ack", nil); err != nil || data != nil {
+		t.Errorf("Expected no data for 204, got %s, %v", data, err)
+	}
+	if _, err := c.Get(context.Background(), "lookup", nil); StatusCode(err) != http.StatusNotFound {
+		t.Errorf("Expected a 404 without data, got %v", err)
+	}
+}
+
+func TestClient_Post(t *testing.T) {
+	endpoint := &echoEndpoint{}
+	server := hubtest.NewServer(t, hubtest.WithEndpoint("echo", endpoint))
+	c := newClient(t, server.URL, nil)
+
+	type order struct {
+		Qty int `json:"qty"`
+	}
+	for range 2 {
+		data, err := c.Post(context.Background(), "echo", order{Qty: 10}, "order-1")
+		if err != nil {
+			t.Fatalf("Error posting: %v", err)
+		}
+		if got, err := Decode[order](data); err != nil || got.Qty != 10 {
+			t.Errorf("Expected the order back, got %+v, %v", got, err)
+		}
+	}
+	if endpoint.calls.Load() != 1 {
+		t.Errorf("Expected the retry to be replayed, got %d calls", endpoint.calls.Load())
+	}
+}
+
+func TestClient_Header(t *testing.T) {
+	server := hubtest.NewServer(t,
+		hubtest.WithEndpoint("echo", &echoEndpoint{}),
+		hubtest.WithAuthenticator(keyAuthenticator{}),
+	)
+
+	if _, err := newClient(t, server.URL, nil).Post(context.Background(), "echo", 1, ""); StatusCode(err) != http.StatusUnauthorized {
+		t.Errorf("Expected a 401 without the key, got %v", err)
+	}
+	c := newClient(t, server.URL, map[string]string{"X-API-Key": "secret"})
+	if _, err := c.Post(context.Background(), "echo", 1, ""); err != nil {
+		t.Errorf("Expected the key to be sent, got %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maxEventLine bounds the length of one line of a stream
+const maxEventLine = 1 << 20
+
+// ErrStreamClosed is returned by Next after Close
+var ErrStreamClosed = errors.New("client: stream closed")
+
+// Event represents one Server-Sent Event
+type Event struct {
+	ID   string          // The last event ID seen on the stream, which the server may leave unset
+	Name string          // "" for data events, "error" for hub errors
+	Data json.RawMessage // The hub's {"data": ...} envelope, or an ErrorResponse
+}
+
+// Err returns the *APIError carried by an "error" event, or nil for other events
+func (e Event) Err() error {
+	if e.Name != "error" {
+		return nil
+	}
+	apiErr := decodeError(0, e.Data)
+	if len(apiErr.Errors) > 0 {
+		apiErr.StatusCode, _ = strconv.Atoi(apiErr.Errors[0].Status)
+	}
+	return apiErr
+}
+
+// Stream reads the events of an SSE stream. When the connection drops it reconnects with
+// backoff, sending the last event ID so that the server can resume. A stream the server
+// ends cleanly is not reopened. A Stream is not safe for concurrent use, except for Close.
+type Stream struct {
+	client      *Client
+	ctx         context.Context
+	cancel      context.CancelFunc
+	closed      atomic.Bool
+	path        string
+	query       url.Values
+	body        io.ReadCloser
+	scanner     *bufio.Scanner
+	lastEventID string
+	retry       time.Duration // Set by the server's retry field
+	failures    int           // Consecutive failed connections
+	wait        time.Duration // Minimum delay before the next attempt, from Retry-After
+}
+
+// Stream opens the stream of an endpoint, e.g. "date" for /date/stream. The first
+// connection is not retried, so that a wrong path or missing credentials fail at once.
+func (c *Client) Stream(ctx context.Context, endpoint string, query url.Values) (*Stream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Stream{
+		client: c,
+		ctx:    ctx,
+		cancel: cancel,
+		path:   strings.TrimSuffix(endpoint, "/") + "/stream",
+		query:  query,
+	}
+	if err := s.connect(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return s, nil
+}
+
+// LastEventID returns the ID sent on reconnection
+func (s *Stream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Close ends the stream. A Next blocked in another goroutine returns ErrStreamClosed.
+func (s *Stream) Close() error {
+	s.closed.Store(true)
+	s.cancel()
+	return nil
+}
+
+// Next returns the next event. It returns io.EOF when the server ended the stream,
+// ErrStreamClosed after Close, and the context's error when it ends. Error events are
+// returned like other events; see Event.Err.
+func (s *Stream) Next() (Event, error) {
+	for {
+		if s.body == nil {
+			if err := s.reconnect(); err != nil {
+				return Event{}, err
+			}
+		}
+
+		event, err := s.read()
+		if err == nil {
+			s.failures = 0
+			return event, nil
+		}
+
+		s.body.Close()
+		s.body = nil
+		switch {
+		case s.closed.Load():
+			return Event{}, ErrStreamClosed
+		case s.ctx.Err() != nil:
+			return Event{}, s.ctx.Err()
+		case errors.Is(err, io.EOF):
+			return Event{}, io.EOF
+		}
+		// Anything else is a dropped connection, which reconnect retries
+	}
+}
+
+// connect opens a connection, resuming after the last event ID
+func (s *Stream) connect() error {
+	req, err := s.client.NewRequest(s.ctx, http.MethodGet, s.path, s.query, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if s.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", s.lastEventID)
+	}
+
+	resp, err := s.client.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("opening stream %s: %w", req.URL.Path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxEventLine))
+		s.wait = retryAfter(resp.Header)
+		return decodeError(resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		resp.Body.Close()
+		return fmt.Errorf("opening stream %s: expected text/event-stream, got %q", req.URL.Path, ct)
+	}
+
+	s.body = resp.Body
+	s.scanner = bufio.NewScanner(resp.Body)
+	s.scanner.Buffer(make([]byte, 4096), maxEventLine)
+	s.scanner.Split(scanLines)
+	return nil
+}
+
+// reconnect waits for the backoff and connects again, until a connection succeeds, an
+// error is not worth retrying, MaxRetries is reached or the context ends
+func (s *Stream) reconnect() error {
+	for {
+		s.failures++
+		if s.client.config.MaxRetries > 0 && s.failures > s.client.config.MaxRetries {
+			return fmt.Errorf("client: stream %s: giving up after %d attempts", s.path, s.client.config.MaxRetries)
+		}
+
+		timer := time.NewTimer(s.backoff())
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			if s.closed.Load() {
+				return ErrStreamClosed
+			}
+			return s.ctx.Err()
+		case <-timer.C:
+		}
+
+		err := s.connect()
+		if err == nil {
+			return nil
+		}
+		if !retryable(err) {
+			return err
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt: exponential from the server's retry
+// or MinBackoff, capped at MaxBackoff, with jitter so that clients do not reconnect at once
+func (s *Stream) backoff() time.Duration {
+	config := s.client.config
+	base := config.MinBackoff
+	if s.retry > 0 {
+		base = s.retry
+	}
+
+	delay := base
+	for i := 1; i < s.failures && delay < config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, config.MaxBackoff)
+	delay = delay/2 + rand.N(delay/2+1)
+
+	delay = max(delay, s.wait)
+	s.wait = 0
+	return delay
+}
+
+// retryable reports whether a failed connection is worth retrying: network errors and
+// server errors are, other client errors such as 401 or 404 are not
+func retryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// read parses lines until an event is complete. It returns io.EOF if the server ended the
+// stream and another error if the connection failed.
+func (s *Stream) read() (Event, error) {
+	var event Event
+	var data [][]byte
+	for s.scanner.Scan() {
+		line := s.scanner.Bytes()
+		if len(line) == 0 {
+			// An event without data only updates the last event ID
+			if data != nil {
+				event.ID = s.lastEventID
+				event.Data = bytes.Join(data, []byte("\n"))
+				return event, nil
+			}
+			event = Event{}
+			continue
+		}
+		if line[0] == ':' {
+			continue // Comment, e.g. a keep-alive
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			event.Name = string(value)
+		case "data":
+			data = append(data, bytes.Clone(value))
+		case "id":
+			if !bytes.ContainsRune(value, 0) {
+				s.lastEventID = string(value)
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(string(value)); err == nil && ms >= 0 {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// scanLines splits a stream into lines ending with \r\n, \n or \r, as SSE allows
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// A \r at the end of the buffer may be followed by \n
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		// The stream ended in the middle of a line, which drops the event being read
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// NextData reads the next event and decodes the data of its envelope into T. An error
+// event is returned as its *APIError.
+func NextData[T any](s *Stream) (T, error) {
+	var zero T
+	event, err := s.Next()
+	if err != nil {
+		return zero, err
+	}
+	if err := event.Err(); err != nil {
+		return zero, err
+	}
+	data, err := unwrap(event.Data)
+	if err != nil {
+		return zero, fmt.Errorf("stream %s: %w", s.path, err)
+	}
+	return Decode[T](data)
+}
@@ -0,0 +1,257 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"trading/internal/clock"
+	"trading/internal/date"
+	"trading/internal/hub/hubtest"
+)
+
+// sseServer serves the stream written by handle for each connection, numbered from 1
+func sseServer(t *testing.T, handle func(w http.ResponseWriter, r *http.Request, conn int)) *httptest.Server {
+	var conns atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed/stream" || r.Header.Get("Accept") != "text/event-stream" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		handle(w, r, int(conns.Add(1)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// drop sends what was written so far and breaks the connection without ending the stream
+func drop(w http.ResponseWriter) {
+	w.(http.Flusher).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+func TestStream_Hub(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	server := hubtest.NewServer(t, hubtest.WithEndpoint("date", date.New(date.Config{Clock: fake})))
+	c := newClient(t, server.URL, nil)
+	tick := func() {
+		fake.BlockUntil(1)
+		fake.Advance(time.Second)
+	}
+
+	// The hub sends the headers with the first event
+	go tick()
+	stream, err := c.Stream(context.Background(), "date", map[string][]string{"max_count": {"2"}})
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+	defer stream.Close()
+
+	for i, want := range []string{"2024-03-01T12:00:01Z", "2024-03-01T12:00:02Z"} {
+		if i > 0 {
+			tick()
+		}
+		got, err := NextData[date.DateResponse](stream)
+		if err != nil || got.UTC != want {
+			t.Errorf("Event %d: expected %s, got %+v, %v", i, want, got, err)
+		}
+	}
+	if _, err := stream.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF at the end of the stream, got %v", err)
+	}
+}
+
+func TestStream_Parse(t *testing.T) {
+	server := sseServer(t, func(w http.ResponseWriter, r *http.Request, conn int) {
+		io.WriteString(w, ": keep-alive\r\n"+
+			"retry: 5\r\n"+
+			"id: 7\r\n"+
+			"event: quote\r\n"+
+			"data: {\"data\":\r\n"+
+			"data:1}\r\r"+
+			"id: 8\n\n"+
+			"data: {\"data\":2}\n\n")
+	})
+	stream, err := newClient(t, server.URL, nil).Stream(context.Background(), "feed", nil)
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+
+	event, err := stream.Next()
+	if err != nil {
+		t.Fatalf("Error reading event: %v", err)
+	}
+	if event.ID != "7" || event.Name != "quote" || string(event.Data) != "{\"data\":\n1}" {
+		t.Errorf("Unexpected event %+v with data %q", event, event.Data)
+	}
+	if stream.retry != 5*time.Millisecond {
+		t.Errorf("Expected a retry of 5ms, got %v", stream.retry)
+	}
+
+	// An event without data only moves the last event ID
+	if got, err := NextData[int](stream); err != nil || got != 2 {
+		t.Errorf("Expected 2, got %v, %v", got, err)
+	}
+	if stream.LastEventID() != "8" {
+		t.Errorf("Expected last event ID 8, got %q", stream.LastEventID())
+	}
+	if _, err := stream.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestStream_ErrorEvent(t *testing.T) {
+	server := sseServer(t, func(w http.ResponseWriter, r *http.Request, conn int) {
+		io.WriteString(w, "event: error\ndata: {\"errors\":[{\"status\":\"401\",\"title\":\"Unauthorized\",\"detail\":\"the credentials expired\"}]}\n\n")
+	})
+	stream, err := newClient(t, server.URL, nil).Stream(context.Background(), "feed", nil)
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+
+	_, err = NextData[int](stream)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Errors[0].Detail != "the credentials expired" {
+		t.Errorf("Expected the error event as an *APIError, got %v", err)
+	}
+}
+
+func TestStream_Reconnect(t *testing.T) {
+	var mu sync.Mutex
+	var lastEventIDs []string
+	server := sseServer(t, func(w http.ResponseWriter, r *http.Request, conn int) {
+		mu.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		mu.Unlock()
+		switch conn {
+		case 1:
+			fmt.Fprint(w, "id: 1\ndata: {\"data\":1}\n\n")
+			drop(w)
+		case 2:
+			// Server errors are retried
+			http.Error(w, "restarting", http.StatusServiceUnavailable)
+		case 3:
+			fmt.Fprint(w, "id: 2\ndata: {\"data\":2}\n\n")
+			drop(w)
+		default:
+			fmt.Fprint(w, "id: 3\ndata: {\"data\":3}\n\n")
+		}
+	})
+	stream, err := newClient(t, server.URL, nil).Stream(context.Background(), "feed", nil)
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+
+	for want := 1; want <= 3; want++ {
+		if got, err := NextData[int](stream); err != nil || got != want {
+			t.Fatalf("Expected %d, got %v, %v", want, got, err)
+		}
+	}
+	if _, err := stream.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF once the server ends the stream, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(lastEventIDs, ","); got != ",1,1,2" {
+		t.Errorf("Expected the last event ID on every reconnection, got %q", got)
+	}
+}
+
+func TestStream_ReconnectFails(t *testing.T) {
+	// failAfterFirst drops the first connection and answers the others with status
+	failAfterFirst := func(status int) *httptest.Server {
+		return sseServer(t, func(w http.ResponseWriter, r *http.Request, conn int) {
+			if conn == 1 {
+				fmt.Fprint(w, "data: {\"data\":1}\n\n")
+				drop(w)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"errors":[{"status":"%d","title":"%s","detail":"reconnection refused"}]}`, status, http.StatusText(status))
+		})
+	}
+
+	// Client errors are not retried
+	stream, err := newClient(t, failAfterFirst(http.StatusUnauthorized).URL, nil).Stream(context.Background(), "feed", nil)
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+	stream.Next()
+	if _, err := stream.Next(); StatusCode(err) != http.StatusUnauthorized {
+		t.Errorf("Expected the 401 of the reconnection, got %v", err)
+	}
+
+	// Server errors are retried up to MaxRetries
+	c, _ := New(Config{BaseURL: failAfterFirst(http.StatusBadGateway).URL, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxRetries: 3})
+	stream, err = c.Stream(context.Background(), "feed", nil)
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+	stream.Next()
+	if _, err := stream.Next(); err == nil || !strings.Contains(err.Error(), "giving up after 3 attempts") {
+		t.Errorf("Expected the stream to give up, got %v", err)
+	}
+}
+
+func TestStream_FirstConnection(t *testing.T) {
+	server := hubtest.NewServer(t)
+	if _, err := newClient(t, server.URL, nil).Stream(context.Background(), "missing", nil); StatusCode(err) != http.StatusNotFound {
+		t.Errorf("Expected the 404 of the first connection, got %v", err)
+	}
+}
+
+func TestStream_Close(t *testing.T) {
+	server := sseServer(t, func(w http.ResponseWriter, r *http.Request, conn int) {
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	stream, err := newClient(t, server.URL, nil).Stream(context.Background(), "feed", nil)
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := stream.Next()
+		done <- err
+	}()
+	stream.Close()
+	select {
+	case err := <-done:
+		if err != ErrStreamClosed {
+			t.Errorf("Expected ErrStreamClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close to end Next")
+	}
+}
+
+func TestStream_Backoff(t *testing.T) {
+	c, _ := New(Config{BaseURL: "http://hub", MinBackoff: time.Second, MaxBackoff: 8 * time.Second})
+	s := &Stream{client: c}
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second} {
+		s.failures = i + 1
+		if got := s.backoff(); got < want/2 || got > want {
+			t.Errorf("Failure %d: expected a delay between %v and %v, got %v", s.failures, want/2, want, got)
+		}
+	}
+
+	// Retry-After is honored once
+	s.failures, s.wait = 1, 20*time.Second
+	if got := s.backoff(); got != 20*time.Second {
+		t.Errorf("Expected the Retry-After delay, got %v", got)
+	}
+	if got := s.backoff(); got > time.Second {
+		t.Errorf("Expected Retry-After to apply once, got %v", got)
+	}
+}
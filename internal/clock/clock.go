@@ -0,0 +1,80 @@
+// Package clock abstracts the passage of time so that time-based code can be tested
+// deterministically. Real uses the time package; Fake only moves when it is advanced.
+package clock
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the time and creates tickers and timers. As with the time package, the
+// interval of NewTicker and Ticker.Reset must be positive, or they panic.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	NewTimer(d time.Duration) Timer
+	After(d time.Duration) <-chan time.Time
+}
+
+// Ticker delivers ticks at intervals, like time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// Timer delivers a single event, like time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Real is the Clock of the time package
+var Real Clock = realClock{}
+
+// realClock implements Clock with the time package
+type realClock struct{}
+
+// Now implements the Clock interface
+func (realClock) Now() time.Time { return time.Now() }
+
+// NewTicker implements the Clock interface
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+// NewTimer implements the Clock interface
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+// After implements the Clock interface
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// realTicker adapts a time.Ticker to the Ticker interface
+type realTicker struct{ *time.Ticker }
+
+// C implements the Ticker interface
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// realTimer adapts a time.Timer to the Timer interface
+type realTimer struct{ *time.Timer }
+
+// C implements the Timer interface
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// contextKey is the type of the context keys of this package
+type contextKey int
+
+// clockKey is the context key of the request's Clock
+const clockKey contextKey = 0
+
+// ContextWithClock returns a copy of ctx carrying c
+func ContextWithClock(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, clockKey, c)
+}
+
+// FromContext returns the Clock carried by ctx, or Real if there is none
+func FromContext(ctx context.Context) Clock {
+	if c, ok := ctx.Value(clockKey).(Clock); ok {
+		return c
+	}
+	return Real
+}
@@ -0,0 +1,46 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != Real {
+		t.Error("Expected the real clock without one in the context")
+	}
+
+	fake := NewFake(time.Unix(0, 0))
+	if FromContext(ContextWithClock(context.Background(), fake)) != fake {
+		t.Error("Expected the clock from the context")
+	}
+}
+
+func TestReal(t *testing.T) {
+	before := time.Now()
+	if now := Real.Now(); now.Before(before) {
+		t.Errorf("Expected the current time, got %v", now)
+	}
+
+	ticker := Real.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	select {
+	case <-ticker.C():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the ticker to tick")
+	}
+
+	timer := Real.NewTimer(time.Hour)
+	if !timer.Stop() {
+		t.Error("Expected Stop to report an active timer")
+	}
+	if timer.Reset(time.Millisecond) {
+		t.Error("Expected Reset to report a stopped timer")
+	}
+	select {
+	case <-timer.C():
+	case <-Real.After(5 * time.Second):
+		t.Fatal("Expected the timer to fire")
+	}
+}
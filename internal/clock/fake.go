@@ -0,0 +1,207 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when Advance or Set is called. Tickers and timers fire
+// during the call, in time order, and never block: like the time package, a tick is
+// dropped if the previous one was not received yet. It is safe for concurrent use.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	changed chan struct{} // Closed and replaced whenever a waiter is added
+}
+
+// fakeWaiter is a pending ticker or timer
+type fakeWaiter struct {
+	clock  *Fake
+	c      chan time.Time
+	next   time.Time
+	period time.Duration // 0 for timers
+	active bool
+}
+
+// NewFake creates a Fake clock set to start
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start, changed: make(chan struct{})}
+}
+
+// Now implements the Clock interface
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTicker implements the Clock interface
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return fakeTicker{f.add(d, d)}
+}
+
+// NewTimer implements the Clock interface
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return fakeTimer{f.add(d, 0)}
+}
+
+// After implements the Clock interface
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// add registers a waiter firing after d, then every period if period is not 0
+func (f *Fake) add(d, period time.Duration) *fakeWaiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeWaiter{clock: f, c: make(chan time.Time, 1), next: f.now.Add(d), period: period, active: true}
+	f.waiters = append(f.waiters, w)
+	f.notify()
+	// A timer for a time already reached fires at once
+	f.fire(f.now)
+	return w
+}
+
+// notify wakes up the goroutines waiting in BlockUntil; f.mu must be held
+func (f *Fake) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// Advance moves the clock forward by d, firing the tickers and timers that are due
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fire(f.now.Add(d))
+}
+
+// Set moves the clock to t, firing the tickers and timers that are due. The clock never
+// goes backwards; an earlier t only updates Now.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t.Before(f.now) {
+		f.now = t
+		return
+	}
+	f.fire(t)
+}
+
+// fire moves the clock to until, firing every due waiter in time order; f.mu must be held
+func (f *Fake) fire(until time.Time) {
+	for {
+		var due *fakeWaiter
+		for _, w := range f.waiters {
+			if w.active && !w.next.After(until) && (due == nil || w.next.Before(due.next)) {
+				due = w
+			}
+		}
+		if due == nil {
+			break
+		}
+
+		f.now = due.next
+		select {
+		case due.c <- due.next:
+		default:
+		}
+		if due.period > 0 {
+			due.next = due.next.Add(due.period)
+		} else {
+			f.remove(due)
+		}
+	}
+	f.now = until
+}
+
+// remove deactivates w and forgets it; f.mu must be held
+func (f *Fake) remove(w *fakeWaiter) bool {
+	wasActive := w.active
+	w.active = false
+	for i, other := range f.waiters {
+		if other == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			break
+		}
+	}
+	return wasActive
+}
+
+// Waiters returns the number of active tickers and timers
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil waits until at least n tickers and timers are active, so that a test can
+// advance the clock only once the code under test is waiting on it
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		count, changed := len(f.waiters), f.changed
+		f.mu.Unlock()
+		if count >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+// C returns the channel the waiter fires on
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+// stop deactivates the waiter and reports whether it was active
+func (w *fakeWaiter) stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.remove(w)
+}
+
+// reset reactivates the waiter to fire after d and reports whether it was active
+func (w *fakeWaiter) reset(d time.Duration) bool {
+	f := w.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	wasActive := f.remove(w)
+	if w.period > 0 {
+		w.period = d
+	}
+	w.next = f.now.Add(d)
+	w.active = true
+	f.waiters = append(f.waiters, w)
+	f.notify()
+	f.fire(f.now)
+	return wasActive
+}
+
+// fakeTicker is the Ticker of a Fake clock
+type fakeTicker struct{ *fakeWaiter }
+
+// Stop implements the Ticker interface
+func (t fakeTicker) Stop() { t.stop() }
+
+// Reset implements the Ticker interface
+func (t fakeTicker) Reset(d time.Duration) {
+	// Checked before the ticker is touched, so that it keeps running if the panic is recovered
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+	t.reset(d)
+}
+
+// fakeTimer is the Timer of a Fake clock
+type fakeTimer struct{ *fakeWaiter }
+
+// Stop implements the Timer interface
+func (t fakeTimer) Stop() bool { return t.stop() }
+
+// Reset implements the Timer interface
+func (t fakeTimer) Reset(d time.Duration) bool { return t.reset(d) }
@@ -0,0 +1,176 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// start is the initial time of the fake clocks
+var start = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// receive returns the pending value of c, or the zero time if there is none
+func receive(c <-chan time.Time) time.Time {
+	select {
+	case t := <-c:
+		return t
+	default:
+		return time.Time{}
+	}
+}
+
+func TestFake_Ticker(t *testing.T) {
+	fake := NewFake(start)
+	ticker := fake.NewTicker(time.Second)
+
+	fake.Advance(999 * time.Millisecond)
+	if got := receive(ticker.C()); !got.IsZero() {
+		t.Fatalf("Expected no tick before the interval, got %v", got)
+	}
+	fake.Advance(time.Millisecond)
+	if got := receive(ticker.C()); !got.Equal(start.Add(time.Second)) {
+		t.Errorf("Expected a tick at 1s, got %v", got)
+	}
+
+	// Ticks are not queued: only the first one of a long advance is kept
+	fake.Advance(3 * time.Second)
+	if got := receive(ticker.C()); !got.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("Expected a tick at 2s, got %v", got)
+	}
+	if got := receive(ticker.C()); !got.IsZero() {
+		t.Errorf("Expected the other ticks to be dropped, got %v", got)
+	}
+	if !fake.Now().Equal(start.Add(4 * time.Second)) {
+		t.Errorf("Expected the clock at 4s, got %v", fake.Now())
+	}
+
+	// Reset restarts the interval from now
+	fake.Advance(500 * time.Millisecond)
+	ticker.Reset(2 * time.Second)
+	fake.Advance(time.Second)
+	if got := receive(ticker.C()); !got.IsZero() {
+		t.Errorf("Expected no tick before the new interval, got %v", got)
+	}
+	fake.Advance(time.Second)
+	if got := receive(ticker.C()); !got.Equal(start.Add(6500 * time.Millisecond)) {
+		t.Errorf("Expected a tick at 6.5s, got %v", got)
+	}
+
+	ticker.Stop()
+	fake.Advance(time.Hour)
+	if got := receive(ticker.C()); !got.IsZero() {
+		t.Errorf("Expected no tick after Stop, got %v", got)
+	}
+	if fake.Waiters() != 0 {
+		t.Errorf("Expected no waiters, got %d", fake.Waiters())
+	}
+}
+
+func TestTicker_NonPositiveInterval(t *testing.T) {
+	// The fake fails like the time package, so that tests catch what production would
+	for name, c := range map[string]Clock{"real": Real, "fake": NewFake(start)} {
+		for _, d := range []time.Duration{0, -time.Second} {
+			if !panics(func() { c.NewTicker(d) }) {
+				t.Errorf("%s: expected NewTicker(%s) to panic", name, d)
+			}
+			ticker := c.NewTicker(time.Hour)
+			if !panics(func() { ticker.Reset(d) }) {
+				t.Errorf("%s: expected Reset(%s) to panic", name, d)
+			}
+			ticker.Stop()
+		}
+	}
+
+	// A rejected Reset leaves the ticker running
+	fake := NewFake(start)
+	ticker := fake.NewTicker(time.Second)
+	panics(func() { ticker.Reset(0) })
+	fake.Advance(time.Second)
+	if got := receive(ticker.C()); !got.Equal(start.Add(time.Second)) {
+		t.Errorf("Expected a tick at 1s, got %v", got)
+	}
+}
+
+// panics reports whether f panics
+func panics(f func()) (panicked bool) {
+	defer func() { panicked = recover() != nil }()
+	f()
+	return false
+}
+
+func TestFake_Timer(t *testing.T) {
+	fake := NewFake(start)
+	timer := fake.NewTimer(time.Minute)
+	after := fake.After(30 * time.Second)
+
+	fake.Advance(time.Hour)
+	if got := receive(after); !got.Equal(start.Add(30 * time.Second)) {
+		t.Errorf("Expected After to fire at 30s, got %v", got)
+	}
+	if got := receive(timer.C()); !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected the timer to fire at 1m, got %v", got)
+	}
+	if timer.Stop() {
+		t.Error("Expected Stop to report a fired timer")
+	}
+
+	if timer.Reset(time.Second) {
+		t.Error("Expected Reset to report an inactive timer")
+	}
+	if !timer.Stop() {
+		t.Error("Expected Stop to report an active timer")
+	}
+	fake.Advance(time.Hour)
+	if got := receive(timer.C()); !got.IsZero() {
+		t.Errorf("Expected a stopped timer not to fire, got %v", got)
+	}
+
+	// A timer that is already due fires at once
+	if got := receive(fake.After(0)); !got.Equal(fake.Now()) {
+		t.Errorf("Expected After(0) to fire at once, got %v", got)
+	}
+}
+
+func TestFake_Order(t *testing.T) {
+	fake := NewFake(start)
+	slow := fake.NewTimer(2 * time.Second)
+	fast := fake.NewTicker(time.Second)
+
+	// Each waiter receives the time it was due, not the time the clock was advanced to
+	fake.Advance(2 * time.Second)
+	if got := receive(fast.C()); !got.Equal(start.Add(time.Second)) {
+		t.Errorf("Expected the ticker to fire at 1s, got %v", got)
+	}
+	if got := receive(slow.C()); !got.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("Expected the timer to fire at 2s, got %v", got)
+	}
+}
+
+func TestFake_Set(t *testing.T) {
+	fake := NewFake(start)
+	timer := fake.NewTimer(time.Minute)
+
+	fake.Set(start.Add(-time.Hour))
+	if !fake.Now().Equal(start.Add(-time.Hour)) {
+		t.Errorf("Expected the clock to be set back, got %v", fake.Now())
+	}
+	fake.Set(start.Add(time.Minute))
+	if got := receive(timer.C()); !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected the timer to fire at 1m, got %v", got)
+	}
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	fake := NewFake(start)
+
+	ready := make(chan Ticker)
+	go func() {
+		ready <- fake.NewTicker(time.Second)
+	}()
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+
+	ticker := <-ready
+	if got := receive(ticker.C()); !got.Equal(start.Add(time.Second)) {
+		t.Errorf("Expected a tick at 1s, got %v", got)
+	}
+}
@@ -8,7 +8,11 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 	"time"
+
+	"trading/internal/clock"
+	"trading/internal/hub"
 )
 
 // DateResponse represents the response from the date endpoint
@@ -17,23 +21,71 @@ type DateResponse struct {
 	UTC string `json:"UTC"` // 16 bytes
 }
 
+// defaultInterval is the time between two events when no interval is configured
+const defaultInterval = 1 * time.Second
+
 // Config represents the configuration for the date endpoint
 type Config struct {
-	// Add any date-specific configuration options here
+	Interval time.Duration `json:"interval"` // Default: 1s
+	Clock    clock.Clock   `json:"-"`        // Default: the hub's clock; set by tests
 }
 
 // Endpoint implements the hub.Endpoint interface for the date endpoint
 type Endpoint struct {
 	config Config
+	mu     sync.RWMutex
 }
 
 // New creates a new Endpoint with the given configuration
 func New(config Config) *Endpoint {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+
 	return &Endpoint{
 		config: config,
 	}
 }
 
+// Reconfigure implements hub.Reconfigurable. Fields missing from the section return to
+// their defaults. Open streams switch to the new interval after their next event.
+func (d *Endpoint) Reconfigure(section hub.ConfigSection) error {
+	var config Config
+	if err := section.Decode(&config); err != nil {
+		return err
+	}
+	if config.Interval < 0 {
+		return fmt.Errorf("interval: %s must not be negative", config.Interval)
+	}
+	if config.Interval == 0 {
+		config.Interval = defaultInterval
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// The clock is not part of the configuration file
+	config.Clock = d.config.Clock
+	d.config = config
+	return nil
+}
+
+// interval returns the currently configured time between two events
+func (d *Endpoint) interval() time.Duration {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.config.Interval
+}
+
+// clockFor returns the configured clock, or the one the hub passes with the request
+func (d *Endpoint) clockFor(r *http.Request) clock.Clock {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.config.Clock != nil {
+		return d.config.Clock
+	}
+	return clock.FromContext(r.Context())
+}
+
 // HandleSSE handles both REST and SSE requests for the date endpoint
 // The hub will handle the differences between REST and SSE
 func (d *Endpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
@@ -57,22 +109,20 @@ func (d *Endpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	// Send events to client
-	ticker := time.NewTicker(1 * time.Second)
+	clk := d.clockFor(r)
+	interval := d.interval()
+	ticker := clk.NewTicker(interval)
 	defer ticker.Stop()
 
+	// Stop as soon as the last event is sent, rather than one interval later
 	count := 0
-	for {
+	for count < maxCount {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			// Check if we've reached the max count
-			if count >= maxCount {
-				return
-			}
-
+		case <-ticker.C():
 			// Get the current time
-			now := time.Now()
+			now := clk.Now()
 
 			// Create the response
 			response := DateResponse{
@@ -102,6 +152,12 @@ func (d *Endpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
 
 			// Increment the count
 			count++
+
+			// Pick up an interval changed by Reconfigure
+			if current := d.interval(); current != interval {
+				interval = current
+				ticker.Reset(interval)
+			}
 		}
 	}
 }
@@ -1,107 +1,194 @@
 package date
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"trading/internal/clock"
+	"trading/internal/hub"
+	"trading/internal/hub/hubtest"
 )
 
-func TestDateEndpoint_HandleSSE_REST(t *testing.T) {
-	// Create a new date endpoint with default config
-	endpoint := New(Config{})
+// start is the time of the fake clocks, half a second past so that ticks format cleanly
+var start = time.Date(2024, 3, 1, 12, 0, 0, 500_000_000, time.UTC)
 
-	// Create a request for REST with max_count=1
-	req, err := http.NewRequest("GET", "/date", nil)
-	if err != nil {
-		t.Fatalf("Error creating request: %v", err)
-	}
-	q := req.URL.Query()
-	q.Set("max_count", "1")
-	req.URL.RawQuery = q.Encode()
+// streamWriter hands every write to the test as soon as the endpoint makes it
+type streamWriter struct {
+	header http.Header
+	writes chan string
+}
 
-	// Create a response recorder
-	rr := httptest.NewRecorder()
+// newStreamWriter creates a streamWriter
+func newStreamWriter() *streamWriter {
+	return &streamWriter{header: make(http.Header), writes: make(chan string)}
+}
 
-	// Handle the request
-	endpoint.HandleSSE(rr, req)
+// Header implements http.ResponseWriter
+func (w *streamWriter) Header() http.Header { return w.header }
 
-	// Decode the response
-	var dateResponse DateResponse
-	if err := json.NewDecoder(rr.Body).Decode(&dateResponse); err != nil {
-		t.Fatalf("Error decoding response: %v", err)
-	}
+// WriteHeader implements http.ResponseWriter
+func (w *streamWriter) WriteHeader(int) {}
 
-	// Check that the UTC field is not empty
-	if dateResponse.UTC == "" {
-		t.Error("Expected UTC to be non-empty")
-	}
+// Write implements http.ResponseWriter
+func (w *streamWriter) Write(b []byte) (int, error) {
+	w.writes <- string(b)
+	return len(b), nil
+}
 
-	// Parse the UTC time
-	utc, err := time.Parse(time.RFC3339, dateResponse.UTC)
-	if err != nil {
-		t.Fatalf("Error parsing UTC time: %v", err)
-	}
+func TestDateEndpoint_HandleSSE_REST(t *testing.T) {
+	fake := clock.NewFake(start)
+	endpoint := New(Config{Clock: fake})
+
+	rr := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		endpoint.HandleSSE(rr, httptest.NewRequest(http.MethodGet, "/date?max_count=1", nil))
+		close(done)
+	}()
 
-	// Check that the UTC time is in UTC
-	if utc.Location().String() != "UTC" {
-		t.Errorf("Expected UTC time to be in UTC, got %v", utc.Location().String())
+	// The only event is sent on the first tick, and the handler returns right after it
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+	<-done
+
+	if got, want := rr.Body.String(), `{"UTC":"2024-03-01T12:00:01Z"}`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if fake.Waiters() != 0 {
+		t.Error("Expected the ticker to be stopped")
 	}
 }
 
 func TestDateEndpoint_HandleSSE_Stream(t *testing.T) {
-	// Create a new date endpoint with default config
-	endpoint := New(Config{})
+	fake := clock.NewFake(start)
+	endpoint := New(Config{Interval: 5 * time.Second, Clock: fake})
+
+	w := newStreamWriter()
+	done := make(chan struct{})
+	go func() {
+		endpoint.HandleSSE(w, httptest.NewRequest(http.MethodGet, "/date/stream?max_count=3", nil))
+		close(done)
+	}()
+	fake.BlockUntil(1)
 
-	// Create a request for SSE with max_count=1
-	req, err := http.NewRequest("GET", "/date/stream", nil)
-	if err != nil {
-		t.Fatalf("Error creating request: %v", err)
+	// Nothing is sent before the first interval has passed
+	fake.Advance(4 * time.Second)
+	select {
+	case got := <-w.writes:
+		t.Fatalf("Expected no event before the interval, got %s", got)
+	default:
 	}
-	q := req.URL.Query()
-	q.Set("max_count", "1")
-	req.URL.RawQuery = q.Encode()
 
-	// Create a response recorder
-	rr := httptest.NewRecorder()
+	// Each event carries the time of its tick, and the handler returns after the last one
+	fake.Advance(time.Second)
+	for i, want := range []string{
+		`{"UTC":"2024-03-01T12:00:05Z"}`,
+		`{"UTC":"2024-03-01T12:00:10Z"}`,
+		`{"UTC":"2024-03-01T12:00:15Z"}`,
+	} {
+		if i > 0 {
+			fake.Advance(5 * time.Second)
+		}
+		if got := <-w.writes; got != want {
+			t.Errorf("Event %d: expected %s, got %s", i, want, got)
+		}
+	}
+	<-done
+}
 
-	// Create a channel to signal when the test is done
-	done := make(chan bool)
+func TestDateEndpoint_HandleSSE_Canceled(t *testing.T) {
+	fake := clock.NewFake(start)
+	endpoint := New(Config{Clock: fake})
 
-	// Start the handler in a goroutine
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
 	go func() {
-		endpoint.HandleSSE(rr, req)
-		done <- true
+		endpoint.HandleSSE(newStreamWriter(), httptest.NewRequest(http.MethodGet, "/date/stream", nil).WithContext(ctx))
+		close(done)
 	}()
+	fake.BlockUntil(1)
 
-	// Wait for the handler to finish or timeout
-	select {
-	case <-done:
-		// Handler finished
-	case <-time.After(5 * time.Second):
-		t.Fatal("Timeout waiting for SSE handler to finish")
+	cancel()
+	<-done
+	if fake.Waiters() != 0 {
+		t.Error("Expected the ticker to be stopped")
 	}
+}
 
-	// Decode the response
-	var dateResponse DateResponse
-	if err := json.NewDecoder(rr.Body).Decode(&dateResponse); err != nil {
-		t.Fatalf("Error decoding response: %v", err)
+func TestDateEndpoint_Reconfigure(t *testing.T) {
+	fake := clock.NewFake(start)
+	endpoint := New(Config{Clock: fake})
+	if endpoint.interval() != time.Second {
+		t.Fatalf("Expected default interval of 1s, got %v", endpoint.interval())
 	}
 
-	// Check that the UTC field is not empty
-	if dateResponse.UTC == "" {
-		t.Error("Expected UTC to be non-empty")
+	if err := endpoint.Reconfigure(hub.ConfigSection{"interval": "250ms"}); err != nil {
+		t.Fatalf("Error reconfiguring: %v", err)
+	}
+	if endpoint.interval() != 250*time.Millisecond {
+		t.Errorf("Expected interval of 250ms, got %v", endpoint.interval())
+	}
+	if endpoint.config.Clock != fake {
+		t.Error("Expected reconfiguring to keep the clock")
 	}
 
-	// Parse the UTC time
-	utc, err := time.Parse(time.RFC3339, dateResponse.UTC)
-	if err != nil {
-		t.Fatalf("Error parsing UTC time: %v", err)
+	if err := endpoint.Reconfigure(hub.ConfigSection{"interval": "-1s"}); err == nil {
+		t.Error("Expected a negative interval to be rejected")
+	}
+	if err := endpoint.Reconfigure(hub.ConfigSection{"unknown": "1"}); err == nil {
+		t.Error("Expected an unknown field to be rejected")
+	}
+	if endpoint.interval() != 250*time.Millisecond {
+		t.Errorf("Expected rejected changes to keep the interval, got %v", endpoint.interval())
 	}
 
-	// Check that the UTC time is in UTC
-	if utc.Location().String() != "UTC" {
-		t.Errorf("Expected UTC time to be in UTC, got %v", utc.Location().String())
+	// Removing the field, or setting it to zero, restores the default, since the ticker
+	// needs a positive interval
+	for _, section := range []hub.ConfigSection{nil, {"interval": "0s"}} {
+		if err := endpoint.Reconfigure(section); err != nil {
+			t.Fatalf("Error reconfiguring: %v", err)
+		}
+		if endpoint.interval() != time.Second {
+			t.Errorf("Expected default interval of 1s, got %v", endpoint.interval())
+		}
+	}
+}
+
+func TestDateEndpoint_Hub(t *testing.T) {
+	// The endpoint has no clock of its own, so it uses the hub's
+	fake := clock.NewFake(start)
+	server := hubtest.NewServer(t,
+		hubtest.WithEndpoint("date", New(Config{})),
+		hubtest.WithSetup(func(h *hub.Hub) { h.SetClock(fake) }),
+	)
+	tick := func() {
+		fake.BlockUntil(1)
+		fake.Advance(time.Second)
+	}
+
+	// REST returns one date in the data envelope
+	go tick()
+	response := hubtest.DecodeData[DateResponse](t, server.Get("/date"))
+	if response.UTC != "2024-03-01T12:00:01Z" {
+		t.Errorf("Expected 2024-03-01T12:00:01Z, got %q", response.UTC)
+	}
+
+	// A stream sends one date per tick and ends after max_count events. The hub sends the
+	// headers with the first event, so the first tick must not wait for Stream to return.
+	go tick()
+	stream := server.Stream("/date/stream?max_count=3")
+	for i, want := range []string{"2024-03-01T12:00:02Z", "2024-03-01T12:00:03Z", "2024-03-01T12:00:04Z"} {
+		if i > 0 {
+			tick()
+		}
+		if got := hubtest.NextData[DateResponse](t, stream); got.UTC != want {
+			t.Errorf("Event %d: expected %s, got %s", i, want, got.UTC)
+		}
+	}
+	if event, ok := stream.Next(); ok {
+		t.Errorf("Expected the stream to end, got %s", event)
 	}
 }
@@ -0,0 +1,57 @@
+package hub
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+)
+
+// EndpointInfo describes a registered endpoint in the /admin/endpoints listing
+type EndpointInfo struct {
+	Name           string   `json:"name"`
+	REST           string   `json:"rest"`
+	Stream         string   `json:"stream"`
+	Scopes         []string `json:"scopes"`
+	Reconfigurable bool     `json:"reconfigurable"`
+	QuarantinedFor int      `json:"quarantined_for,omitempty"` // Seconds left, see RecoveryConfig
+}
+
+// Endpoints lists the registered endpoints by name
+func (p *Hub) Endpoints() []EndpointInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	infos := make([]EndpointInfo, 0, len(p.endpoints))
+	for name, endpoint := range p.endpoints {
+		if isReservedName(name) {
+			continue
+		}
+		_, reconfigurable := endpoint.(Reconfigurable)
+		infos = append(infos, EndpointInfo{
+			Name:           name,
+			REST:           "/" + name,
+			Stream:         "/" + name + "/stream",
+			Scopes:         append([]string{}, p.options[name].scopes...),
+			Reconfigurable: reconfigurable,
+			QuarantinedFor: int(math.Ceil(p.panics.remaining(name).Seconds())),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// handleAdminEndpoints lists the registered endpoints
+func (p *Hub) handleAdminEndpoints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "the endpoints can only be listed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(DataResponse{Data: p.Endpoints()}); err != nil {
+		slog.Error("Error encoding endpoints", "error", err)
+	}
+}
@@ -0,0 +1,86 @@
+package hub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAdminEndpoints(t *testing.T) {
+	config := DefaultConfig()
+	config.Recovery.QuarantineAfter = 1
+	platform := New(config)
+	platform.RegisterEndpoint("orders", NewMockEndpoint([]byte(`"ok"`)), WithScopes("orders:write"))
+	platform.RegisterEndpoint("date", &reconfigurableEndpoint{})
+	platform.RegisterEndpoint("broken", panickingEndpoint{})
+	platform.SetAuthenticator(roleAuthenticator{})
+	handler := platform.Handler()
+	captureLogs(t)
+
+	// Quarantine the broken endpoint
+	handler.ServeHTTP(httptest.NewRecorder(), adminRequest(http.MethodGet, "/broken"))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, adminRequest(http.MethodGet, "/admin/endpoints"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	var response struct {
+		Data []EndpointInfo `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+
+	want := []EndpointInfo{
+		{Name: "broken", REST: "/broken", Stream: "/broken/stream", Scopes: []string{}, QuarantinedFor: 300},
+		{Name: "date", REST: "/date", Stream: "/date/stream", Scopes: []string{}, Reconfigurable: true},
+		{Name: "orders", REST: "/orders", Stream: "/orders/stream", Scopes: []string{"orders:write"}},
+	}
+	if !reflect.DeepEqual(response.Data, want) {
+		t.Errorf("Expected %+v, got %+v", want, response.Data)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, adminRequest(http.MethodPost, "/admin/endpoints"))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
+
+// adminRequest returns a request authenticated by roleAuthenticator
+func adminRequest(method, target string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("X-Test-Roles", "operator")
+	return req
+}
+
+func TestAdmin_RequiresAuthenticator(t *testing.T) {
+	logs := captureLogs(t)
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("date", NewMockEndpoint([]byte(`"ok"`)))
+	handler := platform.Handler()
+
+	// Without an authenticator, the admin routes fail closed while endpoints stay anonymous
+	for _, path := range []string{"/admin/config", "/admin/endpoints"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d for %s, got %d", http.StatusForbidden, path, rr.Code)
+		}
+		if response := decodeErrors(t, rr); len(response.Errors) != 1 || response.Errors[0].Status != "403" {
+			t.Errorf("Unexpected error response %+v", response)
+		}
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/date", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected anonymous endpoints to be served, got %d", rr.Code)
+	}
+	if !strings.Contains(logs.String(), "authentication is not configured") {
+		t.Errorf("Expected the refusal to be logged, got %s", logs)
+	}
+}
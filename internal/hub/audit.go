@@ -0,0 +1,113 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"trading/internal/audit"
+)
+
+// AuditConfig represents the audit log. Without a file nothing is audited.
+type AuditConfig struct {
+	File         string   `json:"file"`          // Active JSON Lines file; rotated files are written next to it
+	MaxSizeMB    int      `json:"max_size_mb"`   // Default: 100; size at which the file is rotated, 0 to never rotate
+	RedactFields []string `json:"redact_fields"` // Default: password, secret, token, authorization, api_key, apikey, signature, cookie
+	Sync         bool     `json:"sync"`          // Flush every entry to disk before the request continues
+}
+
+// Enabled reports whether an audit log is configured
+func (c AuditConfig) Enabled() bool {
+	return c.File != ""
+}
+
+// LoggerConfig returns the settings of the audit.Logger writing this log
+func (c AuditConfig) LoggerConfig() audit.Config {
+	return audit.Config{
+		Path:         c.File,
+		MaxBytes:     int64(c.MaxSizeMB) << 20,
+		RedactFields: c.RedactFields,
+		Sync:         c.Sync,
+	}
+}
+
+// validate checks the audit settings
+func (c AuditConfig) validate() []error {
+	if c.MaxSizeMB < 0 {
+		return []error{fmt.Errorf("audit.max_size_mb: %d must not be negative", c.MaxSizeMB)}
+	}
+	return nil
+}
+
+// SetAuditor records every authenticated request to recorder and hands it to endpoints
+// through audit.FromContext. Passing nil disables auditing.
+func (p *Hub) SetAuditor(recorder audit.Recorder) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.auditor = recorder
+}
+
+// getAuditor returns the current audit recorder, which may be nil
+func (p *Hub) getAuditor() audit.Recorder {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.auditor
+}
+
+// principalRecorder fills in the actor of the events endpoints record
+type principalRecorder struct {
+	audit.Recorder
+	actor string
+}
+
+// Record implements the audit.Recorder interface
+func (r principalRecorder) Record(ctx context.Context, event audit.Event) error {
+	if event.Actor == "" {
+		event.Actor = r.actor
+	}
+	return r.Recorder.Record(ctx, event)
+}
+
+// audited records the outcome of every authenticated request and gives the endpoint a
+// recorder for its own actions. A request that cannot be audited is still served.
+func (p *Hub) audited(endpointName, transport string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := p.getAuditor()
+		if recorder == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, ok := PrincipalFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r.WithContext(audit.ContextWithRecorder(r.Context(), recorder)))
+			return
+		}
+		r = r.WithContext(audit.ContextWithRecorder(r.Context(), principalRecorder{Recorder: recorder, actor: principal.ID}))
+
+		started := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		event := audit.Event{
+			Type:     "request",
+			Actor:    principal.ID,
+			Action:   r.Method,
+			Resource: endpointName,
+			Outcome:  strconv.Itoa(sw.statusCode()),
+			Details: map[string]string{
+				"transport":   transport,
+				"path":        r.URL.Path,
+				"scheme":      principal.Scheme,
+				"remote_addr": r.RemoteAddr,
+				"duration_ms": strconv.FormatInt(time.Since(started).Milliseconds(), 10),
+			},
+		}
+		if err := recorder.Record(r.Context(), event); err != nil {
+			slog.Error("Error writing audit log", "endpoint", endpointName, "principal", principal.ID, "error", err)
+		}
+	})
+}
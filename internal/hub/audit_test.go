@@ -0,0 +1,148 @@
+package hub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"trading/internal/audit"
+)
+
+// memoryRecorder keeps the audit events it receives
+type memoryRecorder struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+// Record implements the audit.Recorder interface
+func (m *memoryRecorder) Record(ctx context.Context, event audit.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+	return nil
+}
+
+// recorded returns a copy of the events
+func (m *memoryRecorder) recorded() []audit.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]audit.Event(nil), m.events...)
+}
+
+// cancelEndpoint records an order cancellation through the request's audit recorder
+type cancelEndpoint struct{}
+
+// HandleSSE implements the Endpoint interface
+func (cancelEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	err := audit.FromContext(r.Context()).Record(r.Context(), audit.Event{
+		Type: "order", Action: "cancel", Resource: "order-42", Outcome: "canceled",
+	})
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error", "the cancellation could not be audited")
+		return
+	}
+	w.Write([]byte(`"canceled"`))
+}
+
+func TestAudit_Requests(t *testing.T) {
+	platform := policyTestHub(PolicyConfig{Rules: []PolicyRule{{Role: "trader", Endpoints: []string{"orders"}}}})
+	recorder := &memoryRecorder{}
+	platform.SetAuditor(recorder)
+	handler := platform.Handler()
+	captureLogs(t)
+
+	send := func(path, roles string) {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if roles != "" {
+			req.Header.Set("X-Test-Roles", roles)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	send("/orders", "trader")
+	send("/prices", "trader")
+	send("/orders", "")
+	send("/healthz", "trader")
+
+	events := recorder.recorded()
+	if len(events) != 2 {
+		t.Fatalf("Expected the 2 authenticated requests to be audited, got %+v", events)
+	}
+	if e := events[0]; e.Type != "request" || e.Actor != "user-trader" || e.Action != "POST" || e.Resource != "orders" || e.Outcome != "200" {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	if events[0].Details["transport"] != transportREST || events[0].Details["path"] != "/orders" {
+		t.Errorf("Unexpected details %v", events[0].Details)
+	}
+	if e := events[1]; e.Resource != "prices" || e.Outcome != "403" {
+		t.Errorf("Expected the denied request to be audited, got %+v", e)
+	}
+}
+
+func TestAudit_EndpointActions(t *testing.T) {
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("cancel", cancelEndpoint{})
+	platform.SetAuthenticator(roleAuthenticator{})
+	recorder := &memoryRecorder{}
+	platform.SetAuditor(recorder)
+
+	req := httptest.NewRequest(http.MethodPost, "/cancel", nil)
+	req.Header.Set("X-Test-Roles", "trader")
+	rr := httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	events := recorder.recorded()
+	if len(events) != 2 {
+		t.Fatalf("Expected the action and the request, got %+v", events)
+	}
+	if e := events[0]; e.Type != "order" || e.Action != "cancel" || e.Actor != "user-trader" {
+		t.Errorf("Expected the action with the caller as actor, got %+v", e)
+	}
+	if events[1].Type != "request" {
+		t.Errorf("Expected the request last, got %+v", events[1])
+	}
+}
+
+func TestAudit_Logger(t *testing.T) {
+	config := DefaultConfig()
+	config.Audit.File = filepath.Join(t.TempDir(), "audit.jsonl")
+	logger, err := audit.Open(config.Audit.LoggerConfig())
+	if err != nil {
+		t.Fatalf("Error opening audit log: %v", err)
+	}
+	defer logger.Close()
+
+	platform := New(config)
+	platform.RegisterEndpoint("test", NewMockEndpoint([]byte(`"ok"`)))
+	platform.SetAuthenticator(roleAuthenticator{})
+	platform.SetAuditor(logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-Test-Roles", "trader")
+	req.Header.Set("Authorization", "Bearer secret-token")
+	platform.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	if count, err := audit.Verify(config.Audit.File); err != nil || count != 1 {
+		t.Errorf("Expected one verified entry, got %d %v", count, err)
+	}
+}
+
+func TestReload_Audit(t *testing.T) {
+	platform := New(DefaultConfig())
+
+	next := DefaultConfig()
+	next.Audit.File = "/var/log/hub/audit.jsonl"
+	err := platform.Reload(next)
+	if err == nil || !strings.Contains(err.Error(), "audit") {
+		t.Fatalf("Expected the audit change to be rejected, got %v", err)
+	}
+	if platform.getConfig().Audit.File != "" {
+		t.Error("Expected the running audit settings to be kept")
+	}
+}
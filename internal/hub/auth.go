@@ -0,0 +1,250 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Authentication errors returned by authenticators
+var (
+	// ErrNoCredentials means the request carries none of the credentials an authenticator handles
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials means the credentials are malformed, unknown, expired or do not verify
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrForbidden means the credentials are valid but the caller may not use them
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Principal is an authenticated caller
+type Principal struct {
+	ID     string   `json:"id"`
+	Scheme string   `json:"scheme"` // The authenticator that accepted the credentials, e.g. "api_key"
+	Roles  []string `json:"roles,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	// ExpiresAt is when the credentials stop being valid; zero for credentials without expiry.
+	// SSE streams are ended at this time.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// HasScopes reports whether the principal was granted every scope in required
+func (p *Principal) HasScopes(required ...string) bool {
+	for _, scope := range required {
+		if !contains(p.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// Authenticator identifies the caller of a request
+type Authenticator interface {
+	// Authenticate returns the principal for the request's credentials. It returns an error
+	// wrapping ErrNoCredentials when the request carries none of the credentials it handles,
+	// ErrInvalidCredentials when they do not verify and ErrForbidden when they are not allowed.
+	Authenticate(r *http.Request) (*Principal, error)
+	// Challenge returns the scheme name advertised in WWW-Authenticate
+	Challenge() string
+}
+
+// AuthConfig represents the built-in authentication schemes. Authentication is off
+// unless at least one key is configured.
+type AuthConfig struct {
+	APIKeys APIKeyConfig `json:"api_keys"`
+	HMAC    HMACConfig   `json:"hmac"`
+	JWT     JWTConfig    `json:"jwt"`
+}
+
+// Enabled reports whether any built-in scheme has keys
+func (c AuthConfig) Enabled() bool {
+	return len(c.APIKeys.Keys) > 0 || len(c.HMAC.Keys) > 0 || c.JWT.Enabled()
+}
+
+// validate checks the authentication settings without reading secrets
+func (c AuthConfig) validate() []error {
+	errs := append(c.APIKeys.validate(), c.HMAC.validate()...)
+	return append(errs, c.JWT.validate()...)
+}
+
+// NewAuthenticator builds the authenticators enabled by config, chained in the order
+// API key, HMAC, JWT.
+// It returns nil when no scheme is configured. HMAC secrets are read through lookupEnv.
+func NewAuthenticator(config AuthConfig, lookupEnv func(string) (string, bool)) (Authenticator, error) {
+	var chain authenticatorChain
+	if len(config.APIKeys.Keys) > 0 {
+		apiKeys, err := NewAPIKeyAuthenticator(config.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, apiKeys)
+	}
+	if len(config.HMAC.Keys) > 0 {
+		signed, err := NewHMACAuthenticator(config.HMAC, lookupEnv)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, signed)
+	}
+	if config.JWT.Enabled() {
+		bearer, err := NewJWTAuthenticator(config.JWT)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, bearer)
+	}
+
+	switch len(chain) {
+	case 0:
+		return nil, nil
+	case 1:
+		return chain[0], nil
+	default:
+		return chain, nil
+	}
+}
+
+// authenticatorChain tries several authenticators in order
+type authenticatorChain []Authenticator
+
+// ChainAuthenticators combines authenticators: the first one finding credentials in the request decides
+func ChainAuthenticators(authenticators ...Authenticator) Authenticator {
+	return authenticatorChain(authenticators)
+}
+
+// Authenticate implements Authenticator
+func (c authenticatorChain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range c {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// Challenge implements Authenticator
+func (c authenticatorChain) Challenge() string {
+	challenges := make([]string, 0, len(c))
+	for _, authenticator := range c {
+		challenges = append(challenges, authenticator.Challenge())
+	}
+	return strings.Join(challenges, ", ")
+}
+
+// principalKey is the context key for the authenticated principal
+type principalKey struct{}
+
+// PrincipalFromContext returns the authenticated principal of the request, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// ContextWithPrincipal returns a context carrying principal, e.g. for endpoint tests
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// SetAuthenticator makes every endpoint require authentication. Probes and metrics stay
+// anonymous; admin routes require authentication too. Passing nil disables authentication.
+// Nonces of signed requests are kept by the hub across authenticators, so an authenticator
+// rebuilt on reload still rejects requests signed before it.
+func (p *Hub) SetAuthenticator(authenticator Authenticator) {
+	shareNonces(authenticator, p.nonces)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.authenticator = authenticator
+}
+
+// getAuthenticator returns the current authenticator, which may be nil
+func (p *Hub) getAuthenticator() Authenticator {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.authenticator
+}
+
+// protect wraps a handler with authentication, the endpoint's scope check, the audit log and
+// the authorization policy
+func (p *Hub) protect(endpointName, transport string, scopes []string, next http.Handler) http.Handler {
+	return p.authenticate(endpointName, scopes, p.audited(endpointName, transport, p.authorize(endpointName, transport, next)))
+}
+
+// authenticate rejects requests without valid credentials, or without the scopes the endpoint
+// requires, before they reach the endpoint, so an SSE stream is never opened for such a caller
+func (p *Hub) authenticate(endpointName string, scopes []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticator := p.getAuthenticator()
+		if authenticator == nil {
+			if endpointName == adminEndpoint {
+				// Fail closed: the admin routes expose the configuration and are never anonymous
+				slog.Warn("Admin route requested but authentication is not configured", "path", r.URL.Path)
+				WriteError(w, http.StatusForbidden, "Forbidden", "the admin routes require authentication, which is not configured")
+				return
+			}
+			if len(scopes) > 0 {
+				// Fail closed: scopes cannot be checked without an authenticator
+				slog.Error("Endpoint requires scopes but authentication is not configured", "endpoint", endpointName)
+				WriteError(w, http.StatusForbidden, "Forbidden", "this endpoint requires authentication, which is not configured")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Clients that keep failing are turned away before their credentials are checked
+		client := clientKey(r)
+		if ok, wait := p.limiter.allowAuthAttempt(client); !ok {
+			p.rejectLimited(w, endpointName, client, "auth_failures", wait, "too many failed authentication attempts")
+			return
+		}
+
+		principal, err := authenticator.Authenticate(r)
+		if err != nil || principal == nil {
+			if err == nil {
+				err = ErrInvalidCredentials
+			}
+			if !errors.Is(err, ErrNoCredentials) {
+				p.limiter.chargeAuthFailure(client)
+			}
+			writeAuthError(w, authenticator, endpointName, err)
+			return
+		}
+
+		if !principal.HasScopes(scopes...) {
+			slog.Warn("Insufficient scope", "endpoint", endpointName, "principal", principal.ID, "required", scopes)
+			WriteError(w, http.StatusForbidden, "Forbidden", "this endpoint requires the scopes: "+strings.Join(scopes, " "))
+			return
+		}
+
+		slog.Debug("Authenticated request", "endpoint", endpointName, "principal", principal.ID, "scheme", principal.Scheme)
+		next.ServeHTTP(w, r.WithContext(ContextWithPrincipal(r.Context(), principal)))
+	})
+}
+
+// writeAuthError maps an authentication error to a 401 or 403 JSON:API error
+func writeAuthError(w http.ResponseWriter, authenticator Authenticator, endpointName string, err error) {
+	// Only the error chain built by authenticators is logged, never the credentials themselves
+	slog.Warn("Authentication failed", "endpoint", endpointName, "reason", err.Error())
+
+	if errors.Is(err, ErrForbidden) {
+		WriteError(w, http.StatusForbidden, "Forbidden", "the credentials are not allowed to access this resource")
+		return
+	}
+
+	w.Header().Set("WWW-Authenticate", authenticator.Challenge())
+	detail := "valid credentials are required"
+	if errors.Is(err, ErrNoCredentials) {
+		detail = "authentication is required"
+	}
+	WriteError(w, http.StatusUnauthorized, "Unauthorized", detail)
+}
+
+// authError wraps one of the sentinel errors with a reason that is safe to log
+func authError(sentinel error, format string, args ...any) error {
+	return fmt.Errorf("%w: %s", sentinel, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,214 @@
+package hub
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// apiKeyCacheTTL is how long a verified API key skips the bcrypt comparison
+const apiKeyCacheTTL = time.Minute
+
+// apiKeyCacheSize bounds the number of verified keys kept in memory
+const apiKeyCacheSize = 1024
+
+// APIKeyConfig represents the API key settings. Keys have the form "<id>.<secret>";
+// only a bcrypt hash of the secret is stored.
+type APIKeyConfig struct {
+	Header     string   `json:"header"`      // Default: "X-API-Key"
+	QueryParam string   `json:"query_param"` // Default: "" (disabled), e.g. "api_key" for EventSource clients
+	Keys       []APIKey `json:"keys"`
+}
+
+// APIKey represents one API key
+type APIKey struct {
+	ID        string   `json:"id"`
+	Hash      string   `json:"hash"`      // bcrypt hash of the secret part
+	Principal string   `json:"principal"` // Default: the key ID
+	Roles     []string `json:"roles"`
+	Scopes    []string `json:"scopes"`
+	Disabled  bool     `json:"disabled"`
+}
+
+// validate checks the API key settings
+func (c APIKeyConfig) validate() []error {
+	var errs []error
+	if len(c.Keys) > 0 && c.Header == "" && c.QueryParam == "" {
+		errs = append(errs, errors.New("auth.api_keys: header or query_param must be set"))
+	}
+	seen := make(map[string]bool)
+	for i, key := range c.Keys {
+		if key.ID == "" || strings.ContainsAny(key.ID, ". ") {
+			errs = append(errs, fmt.Errorf("auth.api_keys.keys[%d].id: %q must be non-empty without dots or spaces", i, key.ID))
+		}
+		if seen[key.ID] {
+			errs = append(errs, fmt.Errorf("auth.api_keys.keys[%d].id: %q is used more than once", i, key.ID))
+		}
+		seen[key.ID] = true
+		if _, err := bcrypt.Cost([]byte(key.Hash)); err != nil {
+			errs = append(errs, fmt.Errorf("auth.api_keys.keys[%d].hash: not a bcrypt hash", i))
+		}
+	}
+	return errs
+}
+
+// APIKeyAuthenticator authenticates requests carrying an API key
+type APIKeyAuthenticator struct {
+	header     string
+	queryParam string
+	keys       map[string]APIKey
+	dummyHash  []byte // Compared against for unknown IDs so they take as long as known ones
+
+	mu       sync.Mutex
+	verified map[[sha256.Size]byte]time.Time // Digest of recently verified keys and their expiry
+}
+
+// NewAPIKeyAuthenticator creates an authenticator for the configured keys
+func NewAPIKeyAuthenticator(config APIKeyConfig) (*APIKeyAuthenticator, error) {
+	if err := errors.Join(config.validate()...); err != nil {
+		return nil, err
+	}
+	// The dummy hash uses the highest configured cost so unknown IDs are not faster to reject
+	cost := bcrypt.MinCost
+	for _, key := range config.Keys {
+		if c, _ := bcrypt.Cost([]byte(key.Hash)); c > cost {
+			cost = c
+		}
+	}
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("unknown key"), cost)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]APIKey, len(config.Keys))
+	for _, key := range config.Keys {
+		if key.Principal == "" {
+			key.Principal = key.ID
+		}
+		keys[key.ID] = key
+	}
+	return &APIKeyAuthenticator{
+		header:     config.Header,
+		queryParam: config.QueryParam,
+		keys:       keys,
+		dummyHash:  dummyHash,
+		verified:   make(map[[sha256.Size]byte]time.Time),
+	}, nil
+}
+
+// Challenge implements Authenticator
+func (a *APIKeyAuthenticator) Challenge() string {
+	return `APIKey header="` + a.header + `"`
+}
+
+// Authenticate implements Authenticator
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	presented := a.credential(r)
+	if presented == "" {
+		return nil, ErrNoCredentials
+	}
+
+	id, secret, ok := strings.Cut(presented, ".")
+	if !ok || id == "" || secret == "" {
+		return nil, authError(ErrInvalidCredentials, "malformed API key")
+	}
+
+	key, known := a.keys[id]
+	if !a.verify(presented, key, known, secret) {
+		return nil, authError(ErrInvalidCredentials, "API key %q does not verify", id)
+	}
+	if key.Disabled {
+		return nil, authError(ErrForbidden, "API key %q is disabled", id)
+	}
+
+	return &Principal{ID: key.Principal, Scheme: "api_key", Roles: key.Roles, Scopes: key.Scopes}, nil
+}
+
+// credential returns the presented key and removes it from the query string so it
+// does not reach endpoints or logs
+func (a *APIKeyAuthenticator) credential(r *http.Request) string {
+	if a.header != "" {
+		if key := r.Header.Get(a.header); key != "" {
+			return key
+		}
+	}
+	if a.queryParam == "" {
+		return ""
+	}
+	query := r.URL.Query()
+	key := query.Get(a.queryParam)
+	if key != "" {
+		query.Del(a.queryParam)
+		r.URL.RawQuery = query.Encode()
+	}
+	return key
+}
+
+// verify compares the secret with the stored hash, using the cache of recently verified keys
+func (a *APIKeyAuthenticator) verify(presented string, key APIKey, known bool, secret string) bool {
+	if !known {
+		bcrypt.CompareHashAndPassword(a.dummyHash, []byte(secret))
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(presented))
+	now := time.Now()
+
+	a.mu.Lock()
+	expiry, cached := a.verified[digest]
+	a.mu.Unlock()
+	if cached && now.Before(expiry) {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(key.Hash), []byte(secret)) != nil {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.verified) >= apiKeyCacheSize {
+		for d, e := range a.verified {
+			if now.After(e) {
+				delete(a.verified, d)
+			}
+		}
+		if len(a.verified) >= apiKeyCacheSize {
+			clear(a.verified)
+		}
+	}
+	a.verified[digest] = now.Add(apiKeyCacheTTL)
+	return true
+}
+
+// HashAPIKeySecret returns the bcrypt hash to store for the secret part of an API key
+func HashAPIKeySecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// GenerateAPIKey creates a random key for id. The key is handed to the client once;
+// the hash goes into the configuration.
+func GenerateAPIKey(id string) (key, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	hash, err = HashAPIKeySecret(encoded)
+	if err != nil {
+		return "", "", err
+	}
+	return id + "." + encoded, hash, nil
+}
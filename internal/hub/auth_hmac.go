@@ -0,0 +1,271 @@
+package hub
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers of an HMAC-signed request
+const (
+	HeaderKeyID     = "X-Hub-Key-Id"
+	HeaderTimestamp = "X-Hub-Timestamp" // Unix seconds
+	HeaderNonce     = "X-Hub-Nonce"
+	HeaderSignature = "X-Hub-Signature" // Hex encoded HMAC-SHA256 of the canonical request
+)
+
+// maxSignedBodySize is the largest request body covered by a signature
+const maxSignedBodySize = 1 << 20
+
+// maxNonceLength bounds the nonce to keep the replay cache small
+const maxNonceLength = 128
+
+// minHMACSecretLength is the shortest accepted shared secret, in bytes
+const minHMACSecretLength = 32
+
+// HMACConfig represents the request signing settings
+type HMACConfig struct {
+	MaxSkew time.Duration `json:"max_skew"` // Default: 5m; allowed clock difference, also the nonce lifetime
+	Keys    []HMACKey     `json:"keys"`
+}
+
+// HMACKey represents one shared signing secret. The secret itself is read from the
+// environment variable named by SecretEnv and never appears in the configuration.
+type HMACKey struct {
+	ID        string   `json:"id"`
+	SecretEnv string   `json:"secret_env"`
+	Principal string   `json:"principal"` // Default: the key ID
+	Roles     []string `json:"roles"`
+	Scopes    []string `json:"scopes"`
+}
+
+// validate checks the signing settings without reading the secrets
+func (c HMACConfig) validate() []error {
+	var errs []error
+	if len(c.Keys) > 0 && c.MaxSkew <= 0 {
+		errs = append(errs, fmt.Errorf("auth.hmac.max_skew: %s must be positive", c.MaxSkew))
+	}
+	seen := make(map[string]bool)
+	for i, key := range c.Keys {
+		if key.ID == "" {
+			errs = append(errs, fmt.Errorf("auth.hmac.keys[%d].id: must be set", i))
+		}
+		if seen[key.ID] {
+			errs = append(errs, fmt.Errorf("auth.hmac.keys[%d].id: %q is used more than once", i, key.ID))
+		}
+		seen[key.ID] = true
+		if key.SecretEnv == "" {
+			errs = append(errs, fmt.Errorf("auth.hmac.keys[%d].secret_env: must name an environment variable", i))
+		}
+	}
+	return errs
+}
+
+// hmacKey is a signing key with its secret loaded
+type hmacKey struct {
+	HMACKey
+	secret []byte
+}
+
+// HMACAuthenticator authenticates requests signed with a shared secret. The signature covers
+// the method, path, query, timestamp, nonce and body; a nonce is accepted once per key.
+type HMACAuthenticator struct {
+	keys    map[string]hmacKey
+	maxSkew time.Duration
+	nonces  *nonceCache
+	now     func() time.Time
+}
+
+// NewHMACAuthenticator creates an authenticator for the configured keys, reading each
+// secret through lookupEnv (usually os.LookupEnv)
+func NewHMACAuthenticator(config HMACConfig, lookupEnv func(string) (string, bool)) (*HMACAuthenticator, error) {
+	errs := config.validate()
+	keys := make(map[string]hmacKey, len(config.Keys))
+	for i, key := range config.Keys {
+		secret, ok := lookupEnv(key.SecretEnv)
+		if !ok || len(secret) < minHMACSecretLength {
+			errs = append(errs, fmt.Errorf("auth.hmac.keys[%d]: environment variable %s must hold a secret of at least %d bytes", i, key.SecretEnv, minHMACSecretLength))
+			continue
+		}
+		if key.Principal == "" {
+			key.Principal = key.ID
+		}
+		keys[key.ID] = hmacKey{HMACKey: key, secret: []byte(secret)}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return &HMACAuthenticator{
+		keys:    keys,
+		maxSkew: config.MaxSkew,
+		nonces:  newNonceCache(),
+		now:     time.Now,
+	}, nil
+}
+
+// Challenge implements Authenticator
+func (a *HMACAuthenticator) Challenge() string {
+	return "HMAC-SHA256"
+}
+
+// Authenticate implements Authenticator
+func (a *HMACAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	keyID := r.Header.Get(HeaderKeyID)
+	if keyID == "" {
+		return nil, ErrNoCredentials
+	}
+
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	signature, err := hex.DecodeString(r.Header.Get(HeaderSignature))
+	if err != nil || len(signature) == 0 || nonce == "" || len(nonce) > maxNonceLength {
+		return nil, authError(ErrInvalidCredentials, "malformed signature headers")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, authError(ErrInvalidCredentials, "malformed timestamp")
+	}
+	signedAt := time.Unix(seconds, 0)
+	now := a.now()
+	if skew := now.Sub(signedAt).Abs(); skew > a.maxSkew {
+		return nil, authError(ErrInvalidCredentials, "timestamp is %s away from server time", skew.Round(time.Second))
+	}
+
+	key, ok := a.keys[keyID]
+	if !ok {
+		return nil, authError(ErrInvalidCredentials, "unknown signing key %q", keyID)
+	}
+
+	canonical, err := canonicalRequest(r, timestamp, nonce)
+	if err != nil {
+		return nil, authError(ErrInvalidCredentials, "%v", err)
+	}
+	mac := hmac.New(sha256.New, key.secret)
+	mac.Write(canonical)
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return nil, authError(ErrInvalidCredentials, "signature does not verify for key %q", keyID)
+	}
+
+	// Only a verified request consumes its nonce. Requests older than the skew are
+	// rejected above, so the nonce only has to be remembered until then.
+	if !a.nonces.add(keyID+":"+nonce, signedAt.Add(a.maxSkew), now) {
+		return nil, authError(ErrInvalidCredentials, "nonce was already used with key %q", keyID)
+	}
+
+	return &Principal{ID: key.Principal, Scheme: "hmac", Roles: key.Roles, Scopes: key.Scopes}, nil
+}
+
+// canonicalRequest builds the string that is signed: method, path, sorted query, timestamp,
+// nonce and the hex SHA-256 of the body, separated by newlines. The body is restored for the endpoint.
+func canonicalRequest(r *http.Request, timestamp, nonce string) ([]byte, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+		if err != nil {
+			return nil, fmt.Errorf("reading body: %w", err)
+		}
+		if len(body) > maxSignedBodySize {
+			return nil, fmt.Errorf("body is larger than %d bytes", maxSignedBodySize)
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	bodyHash := sha256.Sum256(body)
+
+	return []byte(strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.Query().Encode(),
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")), nil
+}
+
+// SignRequest adds the signature headers for keyID to r. The body, if any, is read and restored.
+func SignRequest(r *http.Request, keyID string, secret []byte) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return signRequest(r, keyID, secret, time.Now(), hex.EncodeToString(nonce))
+}
+
+// signRequest signs r with an explicit time and nonce
+func signRequest(r *http.Request, keyID string, secret []byte, now time.Time, nonce string) error {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	canonical, err := canonicalRequest(r, timestamp, nonce)
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(canonical)
+
+	r.Header.Set(HeaderKeyID, keyID)
+	r.Header.Set(HeaderTimestamp, timestamp)
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// shareNonces makes every HMACAuthenticator in authenticator remember nonces in nonces,
+// so that nonces used before it was built stay used
+func shareNonces(authenticator Authenticator, nonces *nonceCache) {
+	switch a := authenticator.(type) {
+	case *HMACAuthenticator:
+		if a.nonces != nonces {
+			a.nonces = nonces
+		}
+	case authenticatorChain:
+		for _, each := range a {
+			shareNonces(each, nonces)
+		}
+	}
+}
+
+// nonceCache remembers used nonces until they expire
+type nonceCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	pruned  time.Time
+}
+
+// newNonceCache creates an empty cache
+func newNonceCache() *nonceCache {
+	return &nonceCache{entries: make(map[string]time.Time)}
+}
+
+// add records nonce until expiry and reports whether it was unused
+func (c *nonceCache) add(nonce string, expiry, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop expired nonces at most once a second
+	if now.Sub(c.pruned) >= time.Second {
+		for n, e := range c.entries {
+			if now.After(e) {
+				delete(c.entries, n)
+			}
+		}
+		c.pruned = now
+	}
+
+	if e, ok := c.entries[nonce]; ok && !now.After(e) {
+		return false
+	}
+	c.entries[nonce] = expiry
+	return true
+}
@@ -0,0 +1,423 @@
+package hub
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxJWKSFileSize is the largest JWKS file that will be read
+const maxJWKSFileSize = 1 << 20
+
+// maxTokenSize bounds the bearer tokens that are parsed
+const maxTokenSize = 8 << 10
+
+// minRSAKeyBits is the smallest accepted RSA modulus
+const minRSAKeyBits = 2048
+
+// JWTConfig represents the bearer token settings. Tokens are validated with the keys of
+// a local JWKS file; HS256, RS256 and ES256 are supported.
+type JWTConfig struct {
+	JWKSFile string        `json:"jwks_file"` // Enables bearer tokens when set
+	Issuer   string        `json:"issuer"`    // Required "iss" claim, if set
+	Audience string        `json:"audience"`  // Required entry of the "aud" claim, if set
+	Leeway   time.Duration `json:"leeway"`    // Default: 30s; allowed clock difference for exp and nbf
+}
+
+// Enabled reports whether bearer tokens are accepted
+func (c JWTConfig) Enabled() bool {
+	return c.JWKSFile != ""
+}
+
+// validate checks the bearer token settings without reading the key file
+func (c JWTConfig) validate() []error {
+	var errs []error
+	if c.Leeway < 0 {
+		errs = append(errs, fmt.Errorf("auth.jwt.leeway: %s must not be negative", c.Leeway))
+	}
+	if !c.Enabled() && (c.Issuer != "" || c.Audience != "") {
+		errs = append(errs, errors.New("auth.jwt: issuer and audience require jwks_file"))
+	}
+	return errs
+}
+
+// jwtKey is a verification key from the JWKS file
+type jwtKey struct {
+	id  string
+	alg string // The only algorithm the key may verify
+	key any    // []byte, *rsa.PublicKey or *ecdsa.PublicKey
+}
+
+// JWTAuthenticator authenticates requests carrying a bearer token
+type JWTAuthenticator struct {
+	keys     []jwtKey
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// NewJWTAuthenticator creates an authenticator using the keys of the configured JWKS file
+func NewJWTAuthenticator(config JWTConfig) (*JWTAuthenticator, error) {
+	if err := errors.Join(config.validate()...); err != nil {
+		return nil, err
+	}
+	keys, err := loadJWKS(config.JWKSFile)
+	if err != nil {
+		return nil, err
+	}
+	return &JWTAuthenticator{
+		keys:     keys,
+		issuer:   config.Issuer,
+		audience: config.Audience,
+		leeway:   config.Leeway,
+		now:      time.Now,
+	}, nil
+}
+
+// Challenge implements Authenticator
+func (a *JWTAuthenticator) Challenge() string {
+	return `Bearer realm="hub"`
+}
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwtClaims are the registered and hub-specific claims of a token
+type jwtClaims struct {
+	Subject   string      `json:"sub"`
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt *float64    `json:"exp"`
+	NotBefore *float64    `json:"nbf"`
+	Scope     string      `json:"scope"` // Space separated, as in OAuth 2.0
+	Scp       []string    `json:"scp"`   // List form used by some issuers
+	Roles     []string    `json:"roles"`
+}
+
+// jwtAudience accepts the "aud" claim as a single string or a list
+type jwtAudience []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (a *jwtAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = jwtAudience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("aud must be a string or a list of strings")
+	}
+	*a = list
+	return nil
+}
+
+// Authenticate implements Authenticator
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+	token = strings.TrimSpace(token)
+	if len(token) > maxTokenSize {
+		return nil, authError(ErrInvalidCredentials, "token is larger than %d bytes", maxTokenSize)
+	}
+
+	claims, err := a.verify(token)
+	if err != nil {
+		return nil, authError(ErrInvalidCredentials, "%v", err)
+	}
+
+	scopes := claims.Scp
+	if claims.Scope != "" {
+		scopes = append(scopes, strings.Fields(claims.Scope)...)
+	}
+	// The token is accepted until exp plus the leeway, so its streams last as long
+	return &Principal{
+		ID:        claims.Subject,
+		Scheme:    "jwt",
+		Roles:     claims.Roles,
+		Scopes:    scopes,
+		ExpiresAt: numericDate(*claims.ExpiresAt).Add(a.leeway),
+	}, nil
+}
+
+// verify checks the signature and the time, issuer and audience claims of a token
+func (a *JWTAuthenticator) verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a signed JWT")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	key, err := a.key(header)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("signature is not base64url encoded")
+	}
+	if err := verifySignature(key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	// Claims are only looked at once the signature is known to be good
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+
+	now := a.now()
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("token has no exp claim")
+	}
+	if expires := numericDate(*claims.ExpiresAt); !now.Before(expires.Add(a.leeway)) {
+		return nil, fmt.Errorf("token expired at %s", expires.UTC().Format(time.RFC3339))
+	}
+	if claims.NotBefore != nil {
+		if notBefore := numericDate(*claims.NotBefore); now.Add(a.leeway).Before(notBefore) {
+			return nil, fmt.Errorf("token is not valid before %s", notBefore.UTC().Format(time.RFC3339))
+		}
+	}
+	if a.issuer != "" && claims.Issuer != a.issuer {
+		return nil, fmt.Errorf("issuer %q is not trusted", claims.Issuer)
+	}
+	if a.audience != "" && !contains(claims.Audience, a.audience) {
+		return nil, fmt.Errorf("token is not intended for audience %q", a.audience)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no sub claim")
+	}
+	return &claims, nil
+}
+
+// key selects the verification key for a token header. The algorithm is taken from the
+// key rather than trusted from the token, so an RSA public key can never be used as an HMAC secret.
+func (a *JWTAuthenticator) key(header jwtHeader) (jwtKey, error) {
+	var candidates []jwtKey
+	for _, key := range a.keys {
+		if key.alg == header.Alg && (header.Kid == "" || key.id == header.Kid) {
+			candidates = append(candidates, key)
+		}
+	}
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case len(candidates) == 0 && header.Kid != "":
+		return jwtKey{}, fmt.Errorf("no %s key with kid %q", header.Alg, header.Kid)
+	case len(candidates) == 0:
+		return jwtKey{}, fmt.Errorf("no key for algorithm %q", header.Alg)
+	default:
+		return jwtKey{}, fmt.Errorf("token has no kid and %d %s keys match", len(candidates), header.Alg)
+	}
+}
+
+// verifySignature checks a JWS signature over signingInput
+func verifySignature(key jwtKey, signingInput, signature []byte) error {
+	digest := sha256.Sum256(signingInput)
+
+	switch key.alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.key.([]byte))
+		mac.Write(signingInput)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errors.New("signature does not verify")
+		}
+	case "RS256":
+		if err := rsa.VerifyPKCS1v15(key.key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("signature does not verify")
+		}
+	case "ES256":
+		// JWS encodes ECDSA signatures as the fixed-size concatenation of r and s
+		if len(signature) != 64 {
+			return errors.New("signature does not verify")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key.key.(*ecdsa.PublicKey), digest[:], r, s) {
+			return errors.New("signature does not verify")
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %q", key.alg)
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token
+func decodeSegment(segment string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("not base64url encoded")
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("not valid JSON: %w", err)
+	}
+	return nil
+}
+
+// numericDate converts a JWT NumericDate to a time
+func numericDate(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+// contains reports whether list holds value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// jwk is a JSON Web Key as found in a JWKS file
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`   // oct
+	N   string `json:"n"`   // RSA
+	E   string `json:"e"`   // RSA
+	Crv string `json:"crv"` // EC
+	X   string `json:"x"`   // EC
+	Y   string `json:"y"`   // EC
+}
+
+// loadJWKS reads the signing keys of a JWKS file. Keys not meant for signatures are skipped.
+func loadJWKS(path string) ([]jwtKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWKS file: %w", err)
+	}
+	if info.Size() > maxJWKSFileSize {
+		return nil, fmt.Errorf("JWKS file %s is larger than %d bytes", path, maxJWKSFileSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWKS file: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("JWKS file %s: %w", path, err)
+	}
+
+	var keys []jwtKey
+	var errs []error
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(k)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("JWKS file %s: keys[%d]: %w", path, i, err))
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS file %s has no signing keys", path)
+	}
+	return keys, nil
+}
+
+// parseJWK converts a JWK to a verification key
+func parseJWK(k jwk) (jwtKey, error) {
+	decode := func(name, value string) ([]byte, error) {
+		b, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("%s is not base64url encoded", name)
+		}
+		return b, nil
+	}
+
+	var key jwtKey
+	switch k.Kty {
+	case "oct":
+		secret, err := decode("k", k.K)
+		if err != nil {
+			return key, err
+		}
+		if len(secret) < minHMACSecretLength {
+			return key, fmt.Errorf("oct key must be at least %d bytes", minHMACSecretLength)
+		}
+		key = jwtKey{alg: "HS256", key: secret}
+
+	case "RSA":
+		n, err := decode("n", k.N)
+		if err != nil {
+			return key, err
+		}
+		e, err := decode("e", k.E)
+		if err != nil {
+			return key, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return key, errors.New("RSA exponent is out of range")
+		}
+		public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+		if public.N.BitLen() < minRSAKeyBits {
+			return key, fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+		}
+		key = jwtKey{alg: "RS256", key: public}
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return key, fmt.Errorf("curve %q is not supported, use P-256", k.Crv)
+		}
+		x, err := decode("x", k.X)
+		if err != nil {
+			return key, err
+		}
+		y, err := decode("y", k.Y)
+		if err != nil {
+			return key, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return key, errors.New("P-256 coordinates must be 32 bytes")
+		}
+		// Reject points that are not on the curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return key, errors.New("point is not on the P-256 curve")
+		}
+		public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		key = jwtKey{alg: "ES256", key: public}
+
+	default:
+		return key, fmt.Errorf("key type %q is not supported", k.Kty)
+	}
+
+	if k.Alg != "" && k.Alg != key.alg {
+		return key, fmt.Errorf("alg %q does not match the %s key", k.Alg, k.Kty)
+	}
+	key.id = k.Kid
+	return key, nil
+}
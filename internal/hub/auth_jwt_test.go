@@ -0,0 +1,337 @@
+package hub
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// jwtTestKeys holds one key of each supported algorithm
+type jwtTestKeys struct {
+	hmac []byte
+	rsa  *rsa.PrivateKey
+	ec   *ecdsa.PrivateKey
+}
+
+// newJWTTestKeys generates keys and writes the matching JWKS file, returning its path
+func newJWTTestKeys(t *testing.T) (*jwtTestKeys, string) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating EC key: %v", err)
+	}
+	keys := &jwtTestKeys{hmac: []byte(testHMACSecret), rsa: rsaKey, ec: ecKey}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := map[string]any{"keys": []map[string]string{
+		{"kty": "oct", "kid": "hs", "k": b64(keys.hmac)},
+		{"kty": "RSA", "kid": "rs", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "es", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "RSA", "kid": "enc", "use": "enc"},
+	}}
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatalf("Error encoding JWKS: %v", err)
+	}
+	return keys, writeFile(t, t.TempDir(), "jwks.json", data)
+}
+
+// sign creates a compact JWS token with the given algorithm, kid and claims
+func (k *jwtTestKeys) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, k.hmac)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case "RS256":
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("Error signing token: %v", err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err != nil {
+			t.Fatalf("Error signing token: %v", err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims returns claims accepted by the test authenticator at now
+func validClaims(now time.Time) map[string]any {
+	return map[string]any{
+		"sub":   "alice",
+		"iss":   "https://sso.example.com",
+		"aud":   []string{"hub", "other"},
+		"exp":   now.Add(time.Hour).Unix(),
+		"nbf":   now.Add(-time.Minute).Unix(),
+		"scope": "prices:read orders:write",
+		"roles": []string{"trader"},
+	}
+}
+
+// newTestJWTAuthenticator returns an authenticator for the test JWKS with a fixed clock
+func newTestJWTAuthenticator(t *testing.T, jwksFile string, now time.Time) *JWTAuthenticator {
+	t.Helper()
+	authenticator, err := NewJWTAuthenticator(JWTConfig{
+		JWKSFile: jwksFile,
+		Issuer:   "https://sso.example.com",
+		Audience: "hub",
+		Leeway:   30 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+	authenticator.now = func() time.Time { return now }
+	return authenticator
+}
+
+// bearerRequest returns a request carrying token
+func bearerRequest(target, token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWTAuthenticator_Algorithms(t *testing.T) {
+	keys, jwksFile := newJWTTestKeys(t)
+	now := time.Unix(1_700_000_000, 0)
+	authenticator := newTestJWTAuthenticator(t, jwksFile, now)
+
+	for _, tt := range []struct{ alg, kid string }{{"HS256", "hs"}, {"RS256", "rs"}, {"ES256", "es"}, {"ES256", ""}} {
+		t.Run(tt.alg+"/"+tt.kid, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(bearerRequest("/whoami", keys.sign(t, tt.alg, tt.kid, validClaims(now))))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if principal.ID != "alice" || principal.Scheme != "jwt" {
+				t.Errorf("Unexpected principal %+v", principal)
+			}
+			if !principal.HasScopes("prices:read", "orders:write") || principal.HasScopes("admin") {
+				t.Errorf("Unexpected scopes %v", principal.Scopes)
+			}
+			// The credentials stay valid for the leeway after exp
+			if want := now.Add(time.Hour + 30*time.Second); !principal.ExpiresAt.Equal(want) {
+				t.Errorf("Expected expiry %v, got %v", want, principal.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticator_Rejects(t *testing.T) {
+	keys, jwksFile := newJWTTestKeys(t)
+	now := time.Unix(1_700_000_000, 0)
+	authenticator := newTestJWTAuthenticator(t, jwksFile, now)
+
+	with := func(key string, value any) map[string]any {
+		claims := validClaims(now)
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	// An HS256 token signed with the RSA public key bytes must not verify against the RSA key
+	confused := &jwtTestKeys{hmac: keys.rsa.PublicKey.N.Bytes()}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", keys.sign(t, "RS256", "rs", with("exp", now.Add(-time.Minute).Unix()))},
+		{"no exp", keys.sign(t, "RS256", "rs", with("exp", nil))},
+		{"not yet valid", keys.sign(t, "RS256", "rs", with("nbf", now.Add(time.Minute).Unix()))},
+		{"wrong issuer", keys.sign(t, "RS256", "rs", with("iss", "https://evil.example.com"))},
+		{"wrong audience", keys.sign(t, "RS256", "rs", with("aud", "billing"))},
+		{"no subject", keys.sign(t, "RS256", "rs", with("sub", nil))},
+		{"unknown kid", keys.sign(t, "RS256", "nope", validClaims(now))},
+		{"algorithm confusion", confused.sign(t, "HS256", "rs", validClaims(now))},
+		{"alg none", strings.Join([]string{
+			base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)),
+			base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"alice"}`)),
+			"",
+		}, ".")},
+		{"tampered", keys.sign(t, "ES256", "es", validClaims(now)) + "x"},
+		{"garbage", "not-a-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := authenticator.Authenticate(bearerRequest("/whoami", tt.token)); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+			}
+		})
+	}
+
+	// Within the leeway a just-expired token is still accepted, until the end of the leeway
+	principal, err := authenticator.Authenticate(bearerRequest("/whoami", keys.sign(t, "RS256", "rs", with("exp", now.Add(-10*time.Second).Unix()))))
+	if err != nil {
+		t.Errorf("Expected a token within the leeway to be accepted, got %v", err)
+	} else if want := now.Add(20 * time.Second); !principal.ExpiresAt.Equal(want) {
+		t.Errorf("Expected the credentials to expire at %v, got %v", want, principal.ExpiresAt)
+	}
+
+	// Other authorization schemes are left to other authenticators
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set("Authorization", "Basic YWxpY2U6c2VjcmV0")
+	if _, err := authenticator.Authenticate(req); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Expected ErrNoCredentials, got %v", err)
+	}
+}
+
+func TestLoadJWKS_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"short-secret": `{"keys":[{"kty":"oct","k":"c2hvcnQ"}]}`,
+		"small-rsa":    `{"keys":[{"kty":"RSA","n":"AQAB","e":"AQAB"}]}`,
+		"bad-curve":    `{"keys":[{"kty":"EC","crv":"P-384","x":"AQAB","y":"AQAB"}]}`,
+		"off-curve":    `{"keys":[{"kty":"EC","crv":"P-256","x":"` + strings.Repeat("A", 43) + `","y":"` + strings.Repeat("A", 42) + `E"}]}`,
+		"alg-mismatch": `{"keys":[{"kty":"oct","alg":"RS256","k":"` + base64.RawURLEncoding.EncodeToString([]byte(testHMACSecret)) + `"}]}`,
+		"empty":        `{"keys":[]}`,
+	} {
+		if _, err := loadJWKS(writeFile(t, dir, name+".json", []byte(content))); err == nil {
+			t.Errorf("%s: expected the JWKS file to be rejected", name)
+		}
+	}
+}
+
+func TestScopes_PerEndpoint(t *testing.T) {
+	keys, jwksFile := newJWTTestKeys(t)
+	now := time.Now()
+	authenticator := newTestJWTAuthenticator(t, jwksFile, now)
+
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("prices", principalEndpoint{}, WithScopes("prices:read"))
+	platform.RegisterEndpoint("admin-orders", principalEndpoint{}, WithScopes("orders:write", "orders:admin"))
+	platform.SetAuthenticator(authenticator)
+	handler := platform.Handler()
+
+	token := keys.sign(t, "ES256", "es", validClaims(now))
+	for path, want := range map[string]int{
+		"/prices":                    http.StatusOK,
+		"/prices/stream?max_count=1": http.StatusOK,
+		"/admin-orders":              http.StatusForbidden,
+		"/admin-orders/stream":       http.StatusForbidden,
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, bearerRequest(path, token))
+		if rr.Code != want {
+			t.Errorf("%s: expected status code %d, got %d", path, want, rr.Code)
+		}
+		if want == http.StatusForbidden && rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: expected a JSON:API error before the stream opens", path)
+		}
+	}
+
+	// Without an authenticator, endpoints requiring scopes fail closed
+	platform.SetAuthenticator(nil)
+	rr := httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/prices", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d without an authenticator, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+// tickingEndpoint emits an event every few milliseconds until the request ends
+type tickingEndpoint struct{}
+
+// HandleSSE implements the Endpoint interface
+func (tickingEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			w.Write([]byte(`"tick"`))
+		}
+	}
+}
+
+func TestSSE_EndsWhenTokenExpires(t *testing.T) {
+	keys, jwksFile := newJWTTestKeys(t)
+	authenticator, err := NewJWTAuthenticator(JWTConfig{JWKSFile: jwksFile})
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("ticks", tickingEndpoint{})
+	platform.SetAuthenticator(authenticator)
+	server := httptest.NewServer(platform.Handler())
+	defer server.Close()
+
+	// exp has second precision, so the token expires within one to two seconds
+	claims := map[string]any{"sub": "alice", "exp": time.Now().Add(2 * time.Second).Unix()}
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/ticks/stream", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+keys.sign(t, "HS256", "hs", claims))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error making request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	done := make(chan []string)
+	go func() {
+		var lines []string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		done <- lines
+	}()
+
+	select {
+	case lines := <-done:
+		var sawTick bool
+		for i, line := range lines {
+			sawTick = sawTick || strings.Contains(line, "tick")
+			if line == "event: error" {
+				if !sawTick {
+					t.Error("Expected events before the token expired")
+				}
+				if i+1 >= len(lines) || !strings.Contains(lines[i+1], `"status":"401"`) {
+					t.Errorf("Expected a 401 error event, got %v", lines[i:])
+				}
+				return
+			}
+		}
+		t.Errorf("Expected an error event, got %v", lines)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the stream to end when the token expired")
+	}
+}
@@ -0,0 +1,423 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testHMACSecret is a 32 byte signing secret used by the tests
+const testHMACSecret = "0123456789abcdef0123456789abcdef"
+
+// principalEndpoint writes the ID of the authenticated principal it sees
+type principalEndpoint struct{}
+
+// HandleSSE implements the Endpoint interface
+func (principalEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	principal, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		w.Write([]byte(`"anonymous"`))
+		return
+	}
+	data, _ := json.Marshal(principal.ID)
+	w.Write(data)
+}
+
+// testAPIKey returns an API key and its config entry, hashed with the minimum cost to keep tests fast
+func testAPIKey(t *testing.T, id string) (string, APIKey) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret-"+id), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error hashing secret: %v", err)
+	}
+	return id + ".s3cret-" + id, APIKey{ID: id, Hash: string(hash), Principal: "svc-" + id}
+}
+
+// authTestHub returns a hub handler with the whoami endpoint behind authenticator
+func authTestHub(authenticator Authenticator) http.Handler {
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("whoami", principalEndpoint{})
+	platform.SetAuthenticator(authenticator)
+	return platform.Handler()
+}
+
+// decodeErrors decodes a JSON:API error response
+func decodeErrors(t *testing.T, rr *httptest.ResponseRecorder) ErrorResponse {
+	t.Helper()
+	var response ErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding error response: %v", err)
+	}
+	return response
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	key, entry := testAPIKey(t, "desk1")
+	disabledKey, disabled := testAPIKey(t, "old")
+	disabled.Disabled = true
+
+	authenticator, err := NewAPIKeyAuthenticator(APIKeyConfig{
+		Header:     "X-API-Key",
+		QueryParam: "api_key",
+		Keys:       []APIKey{entry, disabled},
+	})
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+	handler := authTestHub(authenticator)
+
+	tests := []struct {
+		name       string
+		target     string
+		header     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"header", "/whoami", key, http.StatusOK, "svc-desk1"},
+		{"query", "/whoami?api_key=" + key, "", http.StatusOK, "svc-desk1"},
+		{"missing", "/whoami", "", http.StatusUnauthorized, ""},
+		{"wrong secret", "/whoami", "desk1.guess", http.StatusUnauthorized, ""},
+		{"unknown id", "/whoami", "nobody.guess", http.StatusUnauthorized, ""},
+		{"malformed", "/whoami", "no-separator", http.StatusUnauthorized, ""},
+		{"disabled", "/whoami", disabledKey, http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("X-API-Key", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				var response struct {
+					Data string `json:"data"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatalf("Error decoding response: %v", err)
+				}
+				if response.Data != tt.wantBody {
+					t.Errorf("Expected principal %q, got %q", tt.wantBody, response.Data)
+				}
+				return
+			}
+
+			response := decodeErrors(t, rr)
+			if len(response.Errors) != 1 || response.Errors[0].Status != strconv.Itoa(tt.wantStatus) {
+				t.Errorf("Unexpected error response %+v", response)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && !strings.Contains(rr.Header().Get("WWW-Authenticate"), "APIKey") {
+				t.Errorf("Expected an APIKey challenge, got %q", rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthenticator_StripsQueryKey(t *testing.T) {
+	key, entry := testAPIKey(t, "browser")
+	authenticator, err := NewAPIKeyAuthenticator(APIKeyConfig{QueryParam: "api_key", Keys: []APIKey{entry}})
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/whoami/stream?max_count=2&api_key="+key, nil)
+	if _, err := authenticator.Authenticate(req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if req.URL.RawQuery != "max_count=2" {
+		t.Errorf("Expected the key to be removed from the query, got %q", req.URL.RawQuery)
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, hash, err := GenerateAPIKey("ops")
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	if strings.Contains(hash, key) || !strings.HasPrefix(key, "ops.") {
+		t.Fatalf("Unexpected key %q", key)
+	}
+
+	authenticator, err := NewAPIKeyAuthenticator(APIKeyConfig{Header: "X-API-Key", Keys: []APIKey{{ID: "ops", Hash: hash}}})
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set("X-API-Key", key)
+	principal, err := authenticator.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if principal.ID != "ops" || principal.Scheme != "api_key" {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+}
+
+// newTestHMACAuthenticator returns an HMAC authenticator with a fixed clock
+func newTestHMACAuthenticator(t *testing.T, now time.Time) *HMACAuthenticator {
+	t.Helper()
+	authenticator, err := NewHMACAuthenticator(HMACConfig{
+		MaxSkew: 5 * time.Minute,
+		Keys:    []HMACKey{{ID: "algo", SecretEnv: "ALGO_SECRET", Scopes: []string{"read"}}},
+	}, envMap(map[string]string{"ALGO_SECRET": testHMACSecret}))
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+	authenticator.now = func() time.Time { return now }
+	return authenticator
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	authenticator := newTestHMACAuthenticator(t, now)
+
+	signed := func(target, body, nonce string, at time.Time, secret string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		if err := signRequest(req, "algo", []byte(secret), at, nonce); err != nil {
+			t.Fatalf("Error signing request: %v", err)
+		}
+		return req
+	}
+
+	// A correctly signed request is accepted and its body stays readable
+	req := signed("/orders?b=2&a=1", `{"qty":1}`, "n1", now, testHMACSecret)
+	principal, err := authenticator.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if principal.ID != "algo" || principal.Scheme != "hmac" || len(principal.Scopes) != 1 {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+	if body, err := io.ReadAll(req.Body); err != nil || string(body) != `{"qty":1}` {
+		t.Errorf("Expected the body to be restored, got %q (%v)", body, err)
+	}
+
+	// Replaying the same nonce fails
+	if _, err := authenticator.Authenticate(signed("/orders?b=2&a=1", `{"qty":1}`, "n1", now, testHMACSecret)); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected a replayed nonce to be rejected, got %v", err)
+	}
+
+	// A tampered body fails
+	req = signed("/orders", `{"qty":1}`, "n2", now, testHMACSecret)
+	req.Body = http.NoBody
+	if _, err := authenticator.Authenticate(req); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected a tampered body to be rejected, got %v", err)
+	}
+
+	// A stale timestamp fails
+	if _, err := authenticator.Authenticate(signed("/orders", "", "n3", now.Add(-10*time.Minute), testHMACSecret)); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected a stale timestamp to be rejected, got %v", err)
+	}
+
+	// A wrong secret fails
+	if _, err := authenticator.Authenticate(signed("/orders", "", "n4", now, strings.Repeat("x", 32))); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected a wrong secret to be rejected, got %v", err)
+	}
+
+	// Without signature headers the authenticator does not apply
+	if _, err := authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/orders", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Expected ErrNoCredentials, got %v", err)
+	}
+}
+
+func TestHMACAuthenticator_ReplayAcrossReload(t *testing.T) {
+	captureLogs(t)
+	config := DefaultConfig()
+	config.Auth.HMAC.Keys = []HMACKey{{ID: "algo", SecretEnv: "ALGO_SECRET"}}
+	_, entry := testAPIKey(t, "desk1")
+	config.Auth.APIKeys.Keys = []APIKey{entry}
+	lookupEnv := envMap(map[string]string{"ALGO_SECRET": testHMACSecret})
+	platform := New(config)
+	platform.RegisterEndpoint("items", writesEndpoint{payloads: []string{`"ok"`}})
+	handler := platform.Handler()
+
+	// The service builds a new chain of authenticators on every reload, as cmd/hub does
+	reload := func() {
+		t.Helper()
+		authenticator, err := NewAuthenticator(config.Auth, lookupEnv)
+		if err != nil {
+			t.Fatalf("Error creating authenticator: %v", err)
+		}
+		platform.SetAuthenticator(authenticator)
+	}
+	reload()
+
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	if err := SignRequest(req, "algo", []byte(testHMACSecret)); err != nil {
+		t.Fatalf("Error signing request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// The captured request is still within max_skew, but its nonce was used
+	reload()
+	replay := httptest.NewRequest(http.MethodGet, "/items", nil)
+	replay.Header = req.Header.Clone()
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, replay)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a replay after the reload to get 401, got %d", rr.Code)
+	}
+}
+
+func TestNewHMACAuthenticator_RequiresSecret(t *testing.T) {
+	_, err := NewHMACAuthenticator(HMACConfig{
+		MaxSkew: time.Minute,
+		Keys:    []HMACKey{{ID: "algo", SecretEnv: "MISSING"}, {ID: "short", SecretEnv: "SHORT"}},
+	}, envMap(map[string]string{"SHORT": "tiny"}))
+	if err == nil {
+		t.Fatal("Expected missing and short secrets to be rejected")
+	}
+	for _, want := range []string{"MISSING", "SHORT"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "tiny") {
+		t.Errorf("Expected the secret not to appear in the error, got %v", err)
+	}
+}
+
+func TestAuthentication_ChainAndStream(t *testing.T) {
+	key, entry := testAPIKey(t, "desk1")
+	apiKeys, err := NewAPIKeyAuthenticator(APIKeyConfig{Header: "X-API-Key", Keys: []APIKey{entry}})
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+	now := time.Now()
+	handler := authTestHub(ChainAuthenticators(apiKeys, newTestHMACAuthenticator(t, now)))
+
+	// An unauthenticated stream is rejected before any event is sent
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/whoami/stream?max_count=1", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected a JSON error instead of a stream, got Content-Type %q", ct)
+	}
+	if challenge := rr.Header().Get("WWW-Authenticate"); !strings.Contains(challenge, "APIKey") || !strings.Contains(challenge, "HMAC-SHA256") {
+		t.Errorf("Expected both schemes to be advertised, got %q", challenge)
+	}
+	if response := decodeErrors(t, rr); len(response.Errors) != 1 || response.Errors[0].Title != "Unauthorized" {
+		t.Errorf("Unexpected error response %+v", response)
+	}
+
+	// Either scheme opens the stream
+	req := httptest.NewRequest(http.MethodGet, "/whoami/stream?max_count=1", nil)
+	req.Header.Set("X-API-Key", key)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"data":"svc-desk1"`) {
+		t.Errorf("Expected the API key stream to open, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/whoami/stream?max_count=1", nil)
+	if err := signRequest(req, "algo", []byte(testHMACSecret), now, "stream-1"); err != nil {
+		t.Fatalf("Error signing request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"data":"algo"`) {
+		t.Errorf("Expected the signed stream to open, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAuthentication_BuiltInRoutes(t *testing.T) {
+	_, entry := testAPIKey(t, "desk1")
+	authenticator, err := NewAPIKeyAuthenticator(APIKeyConfig{Header: "X-API-Key", Keys: []APIKey{entry}})
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+	handler := authTestHub(authenticator)
+
+	for path, want := range map[string]int{
+		"/livez":        http.StatusOK,
+		"/metrics":      http.StatusOK,
+		"/admin/config": http.StatusUnauthorized,
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != want {
+			t.Errorf("%s: expected status code %d, got %d", path, want, rr.Code)
+		}
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	authenticator, err := NewAuthenticator(DefaultConfig().Auth, nil)
+	if err != nil || authenticator != nil {
+		t.Errorf("Expected no authenticator without keys, got %v (%v)", authenticator, err)
+	}
+
+	_, entry := testAPIKey(t, "desk1")
+	config := DefaultConfig().Auth
+	config.APIKeys.Keys = []APIKey{entry}
+	config.HMAC.Keys = []HMACKey{{ID: "algo", SecretEnv: "ALGO_SECRET"}}
+	authenticator, err = NewAuthenticator(config, envMap(map[string]string{"ALGO_SECRET": testHMACSecret}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := authenticator.(authenticatorChain); !ok {
+		t.Errorf("Expected a chain of both schemes, got %T", authenticator)
+	}
+}
+
+func TestAdminConfig_RedactsKeyHashes(t *testing.T) {
+	key, entry := testAPIKey(t, "desk1")
+	config := DefaultConfig()
+	config.Auth.APIKeys.Keys = []APIKey{entry}
+	platform := New(config)
+	authenticator, err := NewAuthenticator(config.Auth, nil)
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+	platform.SetAuthenticator(authenticator)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+	req.Header.Set("X-API-Key", key)
+	rr := httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), entry.Hash) || !strings.Contains(rr.Body.String(), redactedHash) {
+		t.Errorf("Expected key hashes to be redacted, got %s", rr.Body.String())
+	}
+	if platform.getConfig().Auth.APIKeys.Keys[0].Hash != entry.Hash {
+		t.Error("Expected redaction not to modify the running configuration")
+	}
+}
+
+func TestAuthConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	config.Auth.APIKeys.Keys = []APIKey{{ID: "a.b", Hash: "plain"}}
+	config.Auth.HMAC.MaxSkew = 0
+	config.Auth.HMAC.Keys = []HMACKey{{ID: "algo"}}
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"api_keys.keys[0].id", "api_keys.keys[0].hash", "hmac.max_skew", "hmac.keys[0].secret_env"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+}
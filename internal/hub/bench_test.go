@@ -0,0 +1,214 @@
+package hub
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"trading/internal/loadgen"
+)
+
+// floodEndpoint writes max_count events as fast as the hub takes them
+type floodEndpoint struct{}
+
+// HandleSSE implements the Endpoint interface
+func (floodEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	n, _ := strconv.Atoi(r.URL.Query().Get("max_count"))
+	event := []byte(`{"symbol":"EURUSD","bid":1.08421,"ask":1.08424,"seq":1}`)
+	for i := 0; i < n && r.Context().Err() == nil; i++ {
+		w.Write(event)
+	}
+}
+
+// serveBench serves a hub on a local port through Serve, as Start does, and returns its URL
+func serveBench(b *testing.B) string {
+	b.Helper()
+	discardLogs(b)
+	config := DefaultConfig()
+	config.LogLevel = "error"
+	config.Limits.StreamsPerClient = 0
+	config.Limits.MaxConnections = 0
+	platform := New(config)
+	platform.RegisterEndpoint("bench", loadgen.NewEndpoint(loadgen.EndpointConfig{Interval: 100 * time.Millisecond}))
+	platform.RegisterEndpoint("flood", floodEndpoint{})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatalf("Error listening: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- platform.Serve(listener) }()
+	b.Cleanup(func() {
+		platform.Shutdown(context.Background())
+		<-served
+	})
+	return "http://" + listener.Addr().String()
+}
+
+func BenchmarkREST(b *testing.B) {
+	url := serveBench(b) + "/bench"
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 64}}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			resp, err := client.Get(url)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				b.Errorf("Unexpected status %d", resp.StatusCode)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkStream measures the cost of one event through the streaming path
+func BenchmarkStream(b *testing.B) {
+	url := fmt.Sprintf("%s/flood/stream?max_count=%d", serveBench(b), b.N)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			events++
+		}
+	}
+	if events != b.N {
+		b.Fatalf("Expected %d events, got %d: %v", b.N, events, scanner.Err())
+	}
+}
+
+// BenchmarkStreams holds many streams open and reports their latency and cost. Memory and
+// goroutines include the client side, which runs in the same process.
+func BenchmarkStreams(b *testing.B) {
+	for _, streams := range []int{100, 1000} {
+		b.Run(strconv.Itoa(streams), func(b *testing.B) {
+			url := serveBench(b)
+			for i := 0; i < b.N; i++ {
+				before := loadgen.ReadUsage()
+				var open loadgen.Usage
+				report, err := loadgen.Run(context.Background(), loadgen.Config{
+					URL:      url,
+					Endpoint: "bench",
+					Streams:  streams,
+					Duration: time.Second,
+					OnOpen:   func() { open = loadgen.ReadUsage() },
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if report.StreamErrors > 0 {
+					b.Fatalf("%d streams failed", report.StreamErrors)
+				}
+				memory, goroutines := open.PerStream(before, report.Streams)
+				b.ReportMetric(report.EventRate(), "events/s")
+				b.ReportMetric(float64(report.EventLatency.P50.Microseconds()), "p50-µs")
+				b.ReportMetric(float64(report.EventLatency.P99.Microseconds()), "p99-µs")
+				b.ReportMetric(memory, "B/stream")
+				b.ReportMetric(goroutines, "goroutines/stream")
+			}
+		})
+	}
+}
+
+// discardLogs drops the request logs for the rest of the benchmark
+func discardLogs(b *testing.B) {
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError})))
+	b.Cleanup(func() { slog.SetDefault(previous) })
+}
+
+// discardWriter is a streaming http.ResponseWriter that drops what is written
+type discardWriter struct {
+	header http.Header
+}
+
+// Header implements http.ResponseWriter
+func (w *discardWriter) Header() http.Header { return w.header }
+
+// WriteHeader implements http.ResponseWriter
+func (w *discardWriter) WriteHeader(int) {}
+
+// Write implements http.ResponseWriter
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+// Flush implements http.Flusher
+func (w *discardWriter) Flush() {}
+
+// payloadEndpoint writes its payload max_count times
+type payloadEndpoint struct {
+	payload []byte
+}
+
+// HandleSSE implements the Endpoint interface
+func (e payloadEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	n, _ := strconv.Atoi(r.URL.Query().Get("max_count"))
+	for i := 0; i < n && r.Context().Err() == nil; i++ {
+		w.Write(e.payload)
+	}
+}
+
+// benchPayloads are typical events: a quote, an order book and a plain text status
+var benchPayloads = map[string][]byte{
+	"quote": []byte(`{"symbol":"EURUSD","bid":1.08421,"ask":1.08424,"time":"2024-03-01T12:00:00.123Z"}`),
+	"book":  []byte(`{"symbol":"EURUSD","bids":[` + strings.TrimSuffix(strings.Repeat(`{"price":1.08421,"size":1500000},`, 40), ",") + `]}`),
+	"text":  []byte(`market closed`),
+}
+
+// BenchmarkSSEPipeline measures the hub's work per event, without the network
+func BenchmarkSSEPipeline(b *testing.B) {
+	for _, name := range []string{"quote", "book", "text"} {
+		b.Run(name, func(b *testing.B) {
+			discardLogs(b)
+			platform := New(DefaultConfig())
+			handler := platform.sseHandler("bench", payloadEndpoint{payload: benchPayloads[name]})
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/bench/stream?max_count=%d", b.N), nil)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(benchPayloads[name])))
+			b.ResetTimer()
+
+			handler(&discardWriter{header: make(http.Header)}, req)
+		})
+	}
+}
+
+// BenchmarkRESTPipeline measures the hub's work per REST call, without the network
+func BenchmarkRESTPipeline(b *testing.B) {
+	for _, name := range []string{"quote", "book", "text"} {
+		b.Run(name, func(b *testing.B) {
+			discardLogs(b)
+			platform := New(DefaultConfig())
+			handler := platform.restHandler("bench", payloadEndpoint{payload: benchPayloads[name]}, endpointOptions{})
+			w := &discardWriter{header: make(http.Header)}
+			b.ReportAllocs()
+			b.SetBytes(int64(len(benchPayloads[name])))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				req, _ := http.NewRequest(http.MethodGet, "/bench", nil)
+				handler(w, req)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package hub
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// responseCacheSize bounds the REST responses the hub keeps, across endpoints
+const responseCacheSize = 1024
+
+// CachePolicy represents how the REST responses of an endpoint may be reused, for
+// reference data such as instruments or calendars that changes rarely
+type CachePolicy struct {
+	MaxAge time.Duration // How long a response stays fresh; zero makes clients revalidate it with its ETag
+	Public bool          // Responses are the same for every caller, so shared caches may store them
+	Store  bool          // The hub keeps responses for MaxAge and answers repeated calls without the endpoint
+}
+
+// header returns the Cache-Control header of the policy
+func (c CachePolicy) header() string {
+	scope := "private"
+	if c.Public {
+		scope = "public"
+	}
+	if c.MaxAge < time.Second {
+		return scope + ", no-cache"
+	}
+	return fmt.Sprintf("%s, max-age=%d", scope, int(c.MaxAge/time.Second))
+}
+
+// WithCachePolicy lets clients reuse the successful REST responses of the endpoint as the
+// policy says, instead of the default no-store. With policy.Store, the hub caches them too,
+// keyed by the query and, unless the policy is public, the caller.
+func WithCachePolicy(policy CachePolicy) EndpointOption {
+	return func(o *endpointOptions) {
+		o.cache = &policy
+	}
+}
+
+// cachedResponse represents a REST response kept by the hub
+type cachedResponse struct {
+	header  http.Header // The endpoint's headers, Content-Type, Cache-Control and ETag
+	body    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+// responseCache holds the REST responses of endpoints whose policy stores them
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+	size    int
+}
+
+// newResponseCache creates a responseCache holding up to size responses
+func newResponseCache(size int) *responseCache {
+	return &responseCache{entries: make(map[string]*cachedResponse), size: size}
+}
+
+// get returns the response stored under key, or nil if there is none still fresh at now
+func (c *responseCache) get(key string, now time.Time) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+	return entry
+}
+
+// put stores a response under key. When the cache is full, expired responses are dropped,
+// then the one expiring soonest.
+func (c *responseCache) put(key string, entry *cachedResponse, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		var soonest string
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+				continue
+			}
+			if soonest == "" || e.expires.Before(c.entries[soonest].expires) {
+				soonest = k
+			}
+		}
+		if len(c.entries) >= c.size {
+			delete(c.entries, soonest)
+		}
+	}
+	c.entries[key] = entry
+}
+
+// purge drops the responses of an endpoint
+func (c *responseCache) purge(endpointName string) {
+	prefix := endpointName + "\n"
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// PurgeCache drops the REST responses the hub keeps for an endpoint, e.g. once the
+// reference data behind it has changed
+func (p *Hub) PurgeCache(endpointName string) {
+	p.cache.purge(endpointName)
+}
+
+// responseCacheKey identifies a REST call: the endpoint, the query with its parameters
+// sorted and, unless the responses are public, the caller
+func responseCacheKey(endpointName string, r *http.Request, public bool) string {
+	key := endpointName + "\n" + r.URL.Query().Encode()
+	if !public {
+		if principal, ok := PrincipalFromContext(r.Context()); ok {
+			key += "\n" + principal.Scheme + ":" + principal.ID
+		}
+	}
+	return key
+}
+
+// isCacheableMethod reports whether responses to a method may be reused
+func isCacheableMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// cachedHeader returns the headers kept with a response: those the endpoint set and those
+// the hub derived from the response
+func cachedHeader(endpointHeader, header http.Header) http.Header {
+	kept := endpointHeader.Clone()
+	for _, key := range []string{"Content-Type", "Cache-Control", "ETag"} {
+		kept.Del(key)
+		for _, value := range header.Values(key) {
+			kept.Add(key, value)
+		}
+	}
+	return kept
+}
+
+// etag returns a strong entity tag for a response body
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified reports whether the client's copy of a response is current, according to
+// the conditional headers of the request. If-None-Match takes precedence over
+// If-Modified-Since, which is only compared with a Last-Modified set by the endpoint.
+func notModified(r *http.Request, header http.Header) bool {
+	if !isCacheableMethod(r.Method) {
+		return false
+	}
+	if matches := r.Header.Values("If-None-Match"); len(matches) > 0 {
+		return etagMatches(strings.Join(matches, ","), header.Get("ETag"))
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// etagMatches reports whether an If-None-Match list names tag, using the weak comparison
+func etagMatches(list, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeRESTBody writes a successful REST response, or 304 Not Modified without a body if
+// the client's copy is current, and returns the status
+func writeRESTBody(w http.ResponseWriter, r *http.Request, endpointName string, body []byte) int {
+	if notModified(r, w.Header()) {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return http.StatusNotModified
+	}
+	if _, err := w.Write(body); err != nil {
+		slog.Debug("Error writing response", "endpoint", endpointName, "error", err)
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,253 @@
+package hub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"trading/internal/clock"
+)
+
+// countingEndpoint writes how often it was called, with the symbol of the query
+type countingEndpoint struct {
+	calls *atomic.Int32
+}
+
+// HandleSSE implements the Endpoint interface
+func (e countingEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	n := e.calls.Add(1)
+	w.Write([]byte(`{"call":` + strconv.Itoa(int(n)) + `,"symbol":"` + r.URL.Query().Get("symbol") + `"}`))
+}
+
+func TestREST_ETag(t *testing.T) {
+	endpoint := writesEndpoint{payloads: []string{`{"symbol":"EURUSD"}`}}
+	rr := restCall(t, DefaultConfig(), endpoint, "/items")
+	tag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || tag == "" {
+		t.Fatalf("Expected 200 with an ETag, got %d %q", rr.Code, tag)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Expected no-store without a policy, got %q", got)
+	}
+
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("items", endpoint)
+	tests := []struct {
+		name        string
+		method      string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"current", http.MethodGet, tag, http.StatusNotModified},
+		{"weak", http.MethodGet, "W/" + tag, http.StatusNotModified},
+		{"in a list", http.MethodGet, `"other", ` + tag, http.StatusNotModified},
+		{"any", http.MethodGet, "*", http.StatusNotModified},
+		{"head", http.MethodHead, tag, http.StatusNotModified},
+		{"stale", http.MethodGet, `"other"`, http.StatusOK},
+		{"unsafe method", http.MethodPost, tag, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/items", nil)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			rr := httptest.NewRecorder()
+			platform.Handler().ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if rr.Header().Get("ETag") != tag {
+				t.Errorf("Expected ETag %s, got %q", tag, rr.Header().Get("ETag"))
+			}
+			if tt.wantStatus == http.StatusNotModified && (rr.Body.Len() > 0 || rr.Header().Get("Content-Type") != "") {
+				t.Errorf("Expected 304 without a body, got %q with %q", rr.Body.String(), rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestREST_LastModified(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	endpoint := endpointFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Write([]byte(`"calendar"`))
+	})
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("items", endpoint)
+
+	tests := []struct {
+		name        string
+		since       time.Time
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"unchanged", modified, "", http.StatusNotModified},
+		{"later copy", modified.Add(time.Hour), "", http.StatusNotModified},
+		{"older copy", modified.Add(-time.Second), "", http.StatusOK},
+		{"etag takes precedence", modified, `"other"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/items", nil)
+			req.Header.Set("If-Modified-Since", tt.since.Format(http.TimeFormat))
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rr := httptest.NewRecorder()
+			platform.Handler().ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if rr.Header().Get("Last-Modified") != modified.Format(http.TimeFormat) {
+				t.Errorf("Expected the endpoint's Last-Modified, got %q", rr.Header().Get("Last-Modified"))
+			}
+		})
+	}
+}
+
+func TestREST_CachePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint Endpoint
+		policy   CachePolicy
+		want     string
+	}{
+		{"private", writesEndpoint{payloads: []string{`1`}}, CachePolicy{MaxAge: time.Hour}, "private, max-age=3600"},
+		{"public", writesEndpoint{payloads: []string{`1`}}, CachePolicy{MaxAge: 90 * time.Second, Public: true}, "public, max-age=90"},
+		{"revalidate", writesEndpoint{payloads: []string{`1`}}, CachePolicy{}, "private, no-cache"},
+		{"endpoint header wins", endpointFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=5")
+			w.Write([]byte(`1`))
+		}), CachePolicy{MaxAge: time.Hour}, "max-age=5"},
+		{"errors not cached", writesEndpoint{status: http.StatusBadRequest}, CachePolicy{MaxAge: time.Hour}, "no-store"},
+		{"empty not cached", writesEndpoint{}, CachePolicy{MaxAge: time.Hour}, "no-store"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := restCall(t, DefaultConfig(), tt.endpoint, "/items", WithCachePolicy(tt.policy))
+			if got := rr.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("Expected Cache-Control %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestREST_ResponseCache(t *testing.T) {
+	var calls atomic.Int32
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	platform := New(DefaultConfig())
+	platform.SetClock(fake)
+	platform.RegisterEndpoint("items", countingEndpoint{calls: &calls},
+		WithCachePolicy(CachePolicy{MaxAge: time.Minute, Store: true}))
+	handler := platform.Handler()
+
+	call := func(target string, principal *Principal, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if principal != nil {
+			req = req.WithContext(ContextWithPrincipal(req.Context(), principal))
+		}
+		rr := httptest.NewRecorder()
+		platform.restHandler("items", countingEndpoint{calls: &calls}, platform.options["items"]).ServeHTTP(rr, req)
+		return rr
+	}
+	expect := func(rr *httptest.ResponseRecorder, wantCall int) {
+		t.Helper()
+		want := `{"data":{"call":` + strconv.Itoa(wantCall)
+		if rr.Code != http.StatusOK || len(rr.Body.String()) < len(want) || rr.Body.String()[:len(want)] != want {
+			t.Fatalf("Expected call %d, got %d %s", wantCall, rr.Code, rr.Body.String())
+		}
+	}
+
+	first := call("/items?symbol=EURUSD&venue=x", nil, nil)
+	expect(first, 1)
+	if first.Header().Get("Age") != "" {
+		t.Errorf("Expected no Age on a fresh response, got %q", first.Header().Get("Age"))
+	}
+
+	// The same query in another order is answered from the cache
+	fake.Advance(10 * time.Second)
+	cached := call("/items?venue=x&symbol=EURUSD", nil, nil)
+	expect(cached, 1)
+	if cached.Header().Get("Age") != "10" || cached.Header().Get("ETag") != first.Header().Get("ETag") {
+		t.Errorf("Expected Age 10 and the first ETag, got %q and %q", cached.Header().Get("Age"), cached.Header().Get("ETag"))
+	}
+	if cached.Body.String() != first.Body.String() {
+		t.Errorf("Expected the first body %q, got %q", first.Body.String(), cached.Body.String())
+	}
+
+	// A cached response still honors conditional requests
+	revalidated := call("/items?symbol=EURUSD&venue=x", nil, http.Header{"If-None-Match": {first.Header().Get("ETag")}})
+	if revalidated.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 from the cache, got %d", revalidated.Code)
+	}
+
+	// Other queries and other callers of a private policy get their own responses
+	expect(call("/items?symbol=GBPUSD&venue=x", nil, nil), 2)
+	expect(call("/items?symbol=EURUSD&venue=x", &Principal{ID: "alice", Scheme: "api_key"}, nil), 3)
+	expect(call("/items?symbol=EURUSD&venue=x", &Principal{ID: "alice", Scheme: "api_key"}, nil), 3)
+
+	// Responses expire after MaxAge and can be purged
+	fake.Advance(time.Minute)
+	expect(call("/items?symbol=EURUSD&venue=x", nil, nil), 4)
+	platform.PurgeCache("items")
+	expect(call("/items?symbol=EURUSD&venue=x", nil, nil), 5)
+
+	// Unsafe methods always reach the endpoint
+	req := httptest.NewRequest(http.MethodPost, "/items?symbol=EURUSD&venue=x", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	expect(rr, 6)
+
+	if hits := platform.metrics.cacheRequests.get("items", "hit"); hits != 3 {
+		t.Errorf("Expected 3 cache hits, got %v", hits)
+	}
+}
+
+func TestREST_ResponseCachePublic(t *testing.T) {
+	var calls atomic.Int32
+	platform := New(DefaultConfig())
+	endpoint := countingEndpoint{calls: &calls}
+	handler := platform.restHandler("items", endpoint, endpointOptions{
+		cache: &CachePolicy{MaxAge: time.Minute, Public: true, Store: true},
+	})
+	for _, id := range []string{"alice", "bob"} {
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		req = req.WithContext(ContextWithPrincipal(context.Background(), &Principal{ID: id}))
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rr.Code)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected public responses to be shared between callers, got %d calls", calls.Load())
+	}
+}
+
+func TestResponseCache_Put(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := newResponseCache(2)
+	cache.put("a\n", &cachedResponse{expires: now.Add(time.Minute)}, now)
+	cache.put("b\n", &cachedResponse{expires: now.Add(time.Second)}, now)
+	cache.put("c\n", &cachedResponse{expires: now.Add(time.Hour)}, now)
+	if cache.get("b\n", now) != nil {
+		t.Error("Expected the response expiring soonest to be evicted")
+	}
+	if cache.get("a\n", now) == nil || cache.get("c\n", now) == nil {
+		t.Error("Expected the other responses to be kept")
+	}
+
+	// Expired responses make room first
+	later := now.Add(2 * time.Minute)
+	cache.put("d\n", &cachedResponse{expires: later.Add(time.Minute)}, later)
+	if cache.get("c\n", later) == nil || cache.get("d\n", later) == nil || len(cache.entries) != 2 {
+		t.Errorf("Expected the expired response to be dropped, got %d entries", len(cache.entries))
+	}
+}
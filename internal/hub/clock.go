@@ -0,0 +1,22 @@
+package hub
+
+import "trading/internal/clock"
+
+// SetClock sets the clock handed to endpoints through clock.FromContext, so that tests
+// can drive time-based endpoints with a clock.Fake. The hub times stream expiry and
+// duration with it too. Passing nil restores the real clock.
+func (p *Hub) SetClock(c clock.Clock) {
+	if c == nil {
+		c = clock.Real
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clock = c
+}
+
+// getClock returns the clock handed to endpoints
+func (p *Hub) getClock() clock.Clock {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.clock
+}
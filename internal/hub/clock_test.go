@@ -0,0 +1,101 @@
+package hub
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"trading/internal/clock"
+)
+
+// nowEndpoint sends the time of the clock the hub hands to endpoints
+type nowEndpoint struct{}
+
+// HandleSSE implements the Endpoint interface
+func (nowEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "%q", clock.FromContext(r.Context()).Now().UTC().Format(time.RFC3339))
+}
+
+func TestSetClock(t *testing.T) {
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("now", nowEndpoint{})
+	handler := platform.Handler()
+
+	get := func(path string) string {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr.Body.String()
+	}
+
+	platform.SetClock(clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)))
+	if body := get("/now"); body != "{\"data\":\"2024-03-01T12:00:00Z\"}\n" {
+		t.Errorf("Expected the fake time over REST, got %s", body)
+	}
+	if body := get("/now/stream"); !strings.Contains(body, `data: {"data":"2024-03-01T12:00:00Z"}`) {
+		t.Errorf("Expected the fake time over SSE, got %s", body)
+	}
+
+	// Without a clock, endpoints get the real one
+	platform.SetClock(nil)
+	if body := get("/now"); strings.Contains(body, "2024-03-01") {
+		t.Errorf("Expected the real time, got %s", body)
+	}
+}
+
+// expiringAuthenticator accepts every request with credentials expiring at expires
+type expiringAuthenticator struct {
+	expires time.Time
+}
+
+// Authenticate implements Authenticator
+func (a expiringAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	return &Principal{ID: "alice", Scheme: "test", ExpiresAt: a.expires}, nil
+}
+
+// Challenge implements Authenticator
+func (expiringAuthenticator) Challenge() string {
+	return "Test"
+}
+
+func TestSetClock_StreamExpiry(t *testing.T) {
+	captureLogs(t)
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	platform := New(DefaultConfig())
+	endpoint := newBlockingEndpoint()
+	platform.RegisterEndpoint("ticks", endpoint)
+	platform.SetAuthenticator(expiringAuthenticator{expires: fake.Now().Add(time.Minute)})
+	platform.SetClock(fake)
+	handler := platform.Handler()
+
+	rr := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ticks/stream", nil))
+	}()
+	<-endpoint.started
+
+	// The stream ends when the hub's clock reaches the expiry
+	fake.BlockUntil(1)
+	fake.Advance(time.Minute - time.Second)
+	select {
+	case <-done:
+		t.Fatal("Expected the stream to last until the credentials expire")
+	case <-time.After(20 * time.Millisecond):
+	}
+	fake.Advance(time.Second)
+	<-done
+	if !strings.Contains(rr.Body.String(), "the credentials expired") {
+		t.Errorf("Expected an expiry error event, got %s", rr.Body.String())
+	}
+
+	// The duration is measured with the same clock
+	metrics := httptest.NewRecorder()
+	handler.ServeHTTP(metrics, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := `hub_stream_duration_seconds_sum{endpoint="ticks"} 60`; !strings.Contains(metrics.Body.String(), want) {
+		t.Errorf("Expected %q in the metrics, got %s", want, metrics.Body.String())
+	}
+}
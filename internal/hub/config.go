@@ -0,0 +1,478 @@
+package hub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envPrefix is the prefix of environment variables overriding configuration values
+const envPrefix = "HUB_"
+
+// maxConfigFileSize is the largest configuration file that will be read
+const maxConfigFileSize = 1 << 20
+
+// Config represents the configuration for the hub
+type Config struct {
+	Port        string                   `json:"port"`      // Default: "8080"
+	LogLevel    string                   `json:"log_level"` // Default: "info"
+	Tracing     TracingConfig            `json:"tracing"`
+	TLS         TLSConfig                `json:"tls"`
+	Auth        AuthConfig               `json:"auth"`
+	Policy      PolicyConfig             `json:"policy"`
+	Limits      LimitsConfig             `json:"limits"`
+	CORS        CORSConfig               `json:"cors"`
+	Hardening   HardeningConfig          `json:"hardening"`
+	Recovery    RecoveryConfig           `json:"recovery"`
+	Idempotency IdempotencyConfig        `json:"idempotency"`
+	Audit       AuditConfig              `json:"audit"`
+	Recording   RecordingConfig          `json:"recording"`
+	REST        RESTConfig               `json:"rest"`
+	Lifecycle   LifecycleConfig          `json:"lifecycle"`
+	Endpoints   map[string]ConfigSection `json:"endpoints"` // Per-endpoint sections, keyed by endpoint name
+}
+
+// TracingConfig represents the tracing configuration
+type TracingConfig struct {
+	Exporter     string `json:"exporter"`      // Default: "none" (none, stdout, otlp)
+	OTLPEndpoint string `json:"otlp_endpoint"` // Default: "http://localhost:4318"
+}
+
+// DefaultConfig returns a Config with default values
+func DefaultConfig() Config {
+	return Config{
+		Port:     "8080",
+		LogLevel: "info",
+		Tracing: TracingConfig{
+			Exporter:     "none",
+			OTLPEndpoint: "http://localhost:4318",
+		},
+		TLS: TLSConfig{
+			MinVersion:   "1.2",
+			CipherPolicy: "modern",
+			ClientAuth:   "none",
+		},
+		Auth: AuthConfig{
+			APIKeys: APIKeyConfig{Header: "X-API-Key"},
+			HMAC:    HMACConfig{MaxSkew: 5 * time.Minute},
+			JWT:     JWTConfig{Leeway: 30 * time.Second},
+		},
+		Limits: LimitsConfig{
+			Burst:                 20,
+			StreamsPerClient:      10,
+			MaxConnections:        10000,
+			AuthFailuresPerMinute: 30,
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{http.MethodGet, http.MethodHead, http.MethodPost},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "Last-Event-ID", "X-API-Key", "Idempotency-Key", "traceparent", "tracestate"},
+			MaxAge:         10 * time.Minute,
+		},
+		Hardening: HardeningConfig{
+			MaxHeaderBytes: 16 << 10,
+			MaxURLLength:   2048,
+			MaxQueryParams: 32,
+			HSTSMaxAge:     365 * 24 * time.Hour,
+		},
+		Recovery: RecoveryConfig{
+			QuarantineWindow: time.Minute,
+			QuarantineFor:    5 * time.Minute,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: 24 * time.Hour,
+		},
+		REST: RESTConfig{
+			Timeout: 10 * time.Second,
+		},
+		Lifecycle: LifecycleConfig{
+			StartTimeout: 30 * time.Second,
+			StopTimeout:  10 * time.Second,
+		},
+		Audit: AuditConfig{
+			MaxSizeMB: 100,
+		},
+	}
+}
+
+// Validate checks every configuration value and returns all problems at once
+func (c Config) Validate() error {
+	var errs []error
+
+	if port, err := strconv.Atoi(c.Port); err != nil {
+		errs = append(errs, fmt.Errorf("port: %q is not a number", c.Port))
+	} else if port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("port: %d is out of range 1-65535", port))
+	}
+
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log_level: %q must be one of debug, info, warn, error", c.LogLevel))
+	}
+
+	switch c.Tracing.Exporter {
+	case "", "none", "stdout":
+	case "otlp":
+		if u, err := url.Parse(c.Tracing.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.otlp_endpoint: %q must be an http or https URL", c.Tracing.OTLPEndpoint))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: %q must be one of none, stdout, otlp", c.Tracing.Exporter))
+	}
+
+	errs = append(errs, c.TLS.validate()...)
+	errs = append(errs, c.Auth.validate()...)
+	errs = append(errs, c.Policy.validate()...)
+	errs = append(errs, c.Limits.validate()...)
+	errs = append(errs, c.CORS.validate()...)
+	errs = append(errs, c.Hardening.validate()...)
+	errs = append(errs, c.Recovery.validate()...)
+	errs = append(errs, c.Idempotency.validate()...)
+	errs = append(errs, c.Audit.validate()...)
+	errs = append(errs, c.Recording.validate()...)
+	errs = append(errs, c.REST.validate()...)
+	errs = append(errs, c.Lifecycle.validate()...)
+
+	for name := range c.Endpoints {
+		if name == "" || strings.ContainsAny(name, "/ ") {
+			errs = append(errs, fmt.Errorf("endpoints: %q is not a valid endpoint name", name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ConfigSection holds the raw configuration of one endpoint until the endpoint decodes it
+type ConfigSection map[string]any
+
+// Decode decodes the section into dst, which must be a pointer to a struct.
+// Fields are matched by their json tag; fields missing from the section keep their value.
+func (s ConfigSection) Decode(dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode target must be a non-nil pointer to a struct, got %T", dst)
+	}
+	if s == nil {
+		return nil
+	}
+	return decodeValue(v.Elem(), map[string]any(s), "")
+}
+
+// EndpointConfig decodes the section of the named endpoint on top of defaults
+func EndpointConfig[T any](c Config, name string, defaults T) (T, error) {
+	cfg := defaults
+	if err := c.Endpoints[name].Decode(&cfg); err != nil {
+		return defaults, fmt.Errorf("endpoints.%s: %w", name, err)
+	}
+	return cfg, nil
+}
+
+// LoadConfig builds a Config in layers: defaults, then the file at path (if not empty),
+// then HUB_* environment variables found through lookupEnv (usually os.LookupEnv).
+// Command-line flags are applied by the caller on top. The result is not validated.
+func LoadConfig(path string, lookupEnv func(string) (string, bool)) (Config, error) {
+	config := DefaultConfig()
+
+	if path != "" {
+		raw, err := readConfigFile(path)
+		if err != nil {
+			return config, err
+		}
+		if err := decodeValue(reflect.ValueOf(&config).Elem(), raw, ""); err != nil {
+			return config, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+
+	if lookupEnv != nil {
+		raw := envOverrides(reflect.TypeOf(config), envPrefix, lookupEnv)
+		if err := decodeValue(reflect.ValueOf(&config).Elem(), raw, ""); err != nil {
+			return config, fmt.Errorf("environment: %w", err)
+		}
+	}
+
+	return config, nil
+}
+
+// readConfigFile reads a JSON (.json) or YAML-lite (any other extension) file into a generic map
+func readConfigFile(path string) (map[string]any, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	if info.Size() > maxConfigFileSize {
+		return nil, fmt.Errorf("config file %s is larger than %d bytes", path, maxConfigFileSize)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var raw map[string]any
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+		return raw, nil
+	}
+
+	raw, err := parseYAMLLite(data)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return raw, nil
+}
+
+// envOverrides collects the environment variables matching the scalar and list fields of t.
+// A field's variable name is the prefix followed by its upper-cased json path, e.g. HUB_TRACING_EXPORTER.
+func envOverrides(t reflect.Type, prefix string, lookupEnv func(string) (string, bool)) map[string]any {
+	raw := make(map[string]any)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := fieldName(field)
+		if name == "" {
+			continue
+		}
+		key := prefix + strings.ToUpper(name)
+
+		switch {
+		case field.Type.Kind() == reflect.Struct && field.Type != durationType:
+			if nested := envOverrides(field.Type, key+"_", lookupEnv); len(nested) > 0 {
+				raw[name] = nested
+			}
+		case field.Type.Kind() == reflect.Map:
+			// Maps (such as endpoint sections) can only be set from the config file
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+			// Lists of structures can only be set from the config file
+		default:
+			if value, ok := lookupEnv(key); ok {
+				raw[name] = value
+			}
+		}
+	}
+	return raw
+}
+
+// durationType is decoded from strings like "1.5s" rather than as an integer
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// configSectionType is kept as a raw map rather than decoded
+var configSectionType = reflect.TypeOf(ConfigSection(nil))
+
+// fieldName returns the configuration key of a struct field, or "" if it is not configurable
+func fieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" || name == "" {
+		return ""
+	}
+	return name
+}
+
+// decodeValue assigns a generic value (maps, lists, strings, json.Number, bools) to dst
+func decodeValue(dst reflect.Value, raw any, path string) error {
+	if raw == nil {
+		return nil
+	}
+
+	if dst.Type() == configSectionType {
+		section, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a section, got %T", displayPath(path), raw)
+		}
+		dst.Set(reflect.ValueOf(ConfigSection(section)))
+		return nil
+	}
+
+	if dst.Type() == durationType {
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("%s: durations must be written as strings like \"5s\"", displayPath(path))
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a duration", displayPath(path), s)
+		}
+		dst.SetInt(int64(d))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Struct:
+		m, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a section, got %T", displayPath(path), raw)
+		}
+		fields := make(map[string]int)
+		for i := 0; i < dst.NumField(); i++ {
+			if name := fieldName(dst.Type().Field(i)); name != "" {
+				fields[name] = i
+			}
+		}
+		var errs []error
+		for _, key := range sortedKeys(m) {
+			i, ok := fields[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown field", joinPath(path, key)))
+				continue
+			}
+			if err := decodeValue(dst.Field(i), m[key], joinPath(path, key)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+
+	case reflect.Map:
+		m, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a section, got %T", displayPath(path), raw)
+		}
+		if dst.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%s: unsupported map key type %s", displayPath(path), dst.Type().Key())
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		for _, key := range sortedKeys(m) {
+			mapKey := reflect.ValueOf(key).Convert(dst.Type().Key())
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if existing := dst.MapIndex(mapKey); existing.IsValid() {
+				elem.Set(existing)
+			}
+			if err := decodeValue(elem, m[key], joinPath(path, key)); err != nil {
+				return err
+			}
+			dst.SetMapIndex(mapKey, elem)
+		}
+		return nil
+
+	case reflect.Slice:
+		var items []any
+		switch value := raw.(type) {
+		case []any:
+			items = value
+		case string:
+			// Lists given as a single string (e.g. from the environment) are comma separated
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		default:
+			return fmt.Errorf("%s: expected a list, got %T", displayPath(path), raw)
+		}
+		slice := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(slice.Index(i), item, fmt.Sprintf("%s[%d]", displayPath(path), i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(slice)
+		return nil
+
+	case reflect.String:
+		s, err := scalarString(raw, path)
+		if err != nil {
+			return err
+		}
+		dst.SetString(s)
+		return nil
+
+	case reflect.Bool:
+		if b, ok := raw.(bool); ok {
+			dst.SetBool(b)
+			return nil
+		}
+		s, err := scalarString(raw, path)
+		if err != nil {
+			return err
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a boolean", displayPath(path), s)
+		}
+		dst.SetBool(b)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s, err := scalarString(raw, path)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.ParseInt(s, 10, dst.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", displayPath(path), s)
+		}
+		dst.SetInt(n)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s, err := scalarString(raw, path)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.ParseUint(s, 10, dst.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a non-negative integer", displayPath(path), s)
+		}
+		dst.SetUint(n)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		s, err := scalarString(raw, path)
+		if err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(s, dst.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", displayPath(path), s)
+		}
+		dst.SetFloat(f)
+		return nil
+	}
+
+	return fmt.Errorf("%s: unsupported type %s", displayPath(path), dst.Type())
+}
+
+// scalarString converts a generic scalar to its string form
+func scalarString(raw any, path string) (string, error) {
+	switch value := raw.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	default:
+		return "", fmt.Errorf("%s: expected a value, got %T", displayPath(path), raw)
+	}
+}
+
+// joinPath appends a key to a dotted configuration path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// displayPath returns a printable name for a configuration path
+func displayPath(path string) string {
+	if path == "" {
+		return "config"
+	}
+	return path
+}
@@ -0,0 +1,227 @@
+package hub
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes a config file into a temporary directory and returns its path
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+	return path
+}
+
+// envMap returns a lookup function backed by a map
+func envMap(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+// sampleEndpointConfig mimics an endpoint configuration such as date.Config
+type sampleEndpointConfig struct {
+	Interval time.Duration `json:"interval"`
+	Symbols  []string      `json:"symbols"`
+	Depth    int           `json:"depth"`
+	Enabled  bool          `json:"enabled"`
+}
+
+func TestLoadConfig_Layers(t *testing.T) {
+	path := writeConfigFile(t, "hub.json", `{
+		"port": 9000,
+		"log_level": "debug",
+		"tracing": {"exporter": "stdout"},
+		"endpoints": {"prices": {"interval": "250ms", "symbols": ["EURUSD", "GBPUSD"], "depth": 5}}
+	}`)
+
+	config, err := LoadConfig(path, envMap(map[string]string{
+		"HUB_LOG_LEVEL":        "warn",
+		"HUB_TRACING_EXPORTER": "otlp",
+	}))
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+
+	if config.Port != "9000" {
+		t.Errorf("Expected port from file, got %q", config.Port)
+	}
+	if config.LogLevel != "warn" {
+		t.Errorf("Expected log level from environment, got %q", config.LogLevel)
+	}
+	if config.Tracing.Exporter != "otlp" {
+		t.Errorf("Expected exporter from environment, got %q", config.Tracing.Exporter)
+	}
+	if config.Tracing.OTLPEndpoint != DefaultConfig().Tracing.OTLPEndpoint {
+		t.Errorf("Expected default OTLP endpoint, got %q", config.Tracing.OTLPEndpoint)
+	}
+
+	prices, err := EndpointConfig(config, "prices", sampleEndpointConfig{Enabled: true})
+	if err != nil {
+		t.Fatalf("Error decoding endpoint config: %v", err)
+	}
+	want := sampleEndpointConfig{Interval: 250 * time.Millisecond, Symbols: []string{"EURUSD", "GBPUSD"}, Depth: 5, Enabled: true}
+	if !reflect.DeepEqual(prices, want) {
+		t.Errorf("Expected %+v, got %+v", want, prices)
+	}
+
+	// A missing section leaves the defaults untouched
+	missing, err := EndpointConfig(config, "missing", sampleEndpointConfig{Depth: 1})
+	if err != nil || missing.Depth != 1 {
+		t.Errorf("Expected defaults for a missing section, got %+v (%v)", missing, err)
+	}
+}
+
+func TestLoadConfig_YAMLLite(t *testing.T) {
+	path := writeConfigFile(t, "hub.yaml", `
+# Hub configuration
+port: "9090"
+log_level: error   # trailing comment
+tracing:
+  exporter: otlp
+  otlp_endpoint: 'http://collector:4318'
+endpoints:
+  prices:
+    interval: 2s
+    symbols: [EURUSD, "USD#JPY"]
+    enabled: true
+  book:
+    symbols:
+      - EURUSD
+      - GBPUSD
+`)
+
+	config, err := LoadConfig(path, nil)
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	if config.Port != "9090" || config.LogLevel != "error" {
+		t.Errorf("Unexpected top-level values: %+v", config)
+	}
+	if config.Tracing.OTLPEndpoint != "http://collector:4318" {
+		t.Errorf("Unexpected OTLP endpoint %q", config.Tracing.OTLPEndpoint)
+	}
+
+	prices, err := EndpointConfig(config, "prices", sampleEndpointConfig{})
+	if err != nil {
+		t.Fatalf("Error decoding endpoint config: %v", err)
+	}
+	if prices.Interval != 2*time.Second || !prices.Enabled || !reflect.DeepEqual(prices.Symbols, []string{"EURUSD", "USD#JPY"}) {
+		t.Errorf("Unexpected prices config: %+v", prices)
+	}
+
+	book, err := EndpointConfig(config, "book", sampleEndpointConfig{})
+	if err != nil {
+		t.Fatalf("Error decoding endpoint config: %v", err)
+	}
+	if !reflect.DeepEqual(book.Symbols, []string{"EURUSD", "GBPUSD"}) {
+		t.Errorf("Unexpected book symbols: %v", book.Symbols)
+	}
+}
+
+func TestParseYAMLLite_ListOfMappings(t *testing.T) {
+	raw, err := parseYAMLLite([]byte(`
+rules:
+  - role: trader
+    methods: [GET, POST]
+  - role: viewer
+    methods:
+      - GET
+`))
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+	want := map[string]any{
+		"rules": []any{
+			map[string]any{"role": "trader", "methods": []any{"GET", "POST"}},
+			map[string]any{"role": "viewer", "methods": []any{"GET"}},
+		},
+	}
+	if !reflect.DeepEqual(raw, want) {
+		t.Errorf("Expected %#v, got %#v", want, raw)
+	}
+}
+
+func TestParseYAMLLite_Errors(t *testing.T) {
+	tests := map[string]string{
+		"duplicate key":     "port: 1\nport: 2\n",
+		"bad indentation":   "port: 1\n  log_level: info\n",
+		"tab indentation":   "tracing:\n\texporter: none\n",
+		"missing separator": "port 8080\n",
+		"bad quote":         "port: \"8080\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseYAMLLite([]byte(content)); err == nil {
+				t.Errorf("Expected an error for %q", content)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_UnknownAndInvalidFields(t *testing.T) {
+	path := writeConfigFile(t, "hub.json", `{"prot": "8080", "tracing": {"exporter": 1, "color": "red"}}`)
+
+	_, err := LoadConfig(path, nil)
+	if err == nil {
+		t.Fatal("Expected an error for unknown fields")
+	}
+	for _, want := range []string{"prot: unknown field", "tracing.color: unknown field"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadConfig_InvalidEnvironment(t *testing.T) {
+	_, err := LoadConfig("", envMap(map[string]string{"HUB_PORT": "8080"}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	type withDuration struct {
+		Timeout time.Duration `json:"timeout"`
+	}
+	err = ConfigSection{"timeout": "soon"}.Decode(&withDuration{})
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("Expected a duration error, got %v", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("Expected default config to be valid, got %v", err)
+	}
+
+	config := DefaultConfig()
+	config.Port = "http"
+	config.LogLevel = "verbose"
+	config.Tracing.Exporter = "jaeger"
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"port", "log_level", "tracing.exporter"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+
+	config = DefaultConfig()
+	config.Port = "70000"
+	config.Tracing.Exporter = "otlp"
+	config.Tracing.OTLPEndpoint = "collector:4318"
+	err = config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "out of range") || !strings.Contains(err.Error(), "otlp_endpoint") {
+		t.Errorf("Expected port range and endpoint errors, got %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package hub
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a significant (non-blank, non-comment) line of a YAML-lite document
+type yamlLine struct {
+	num    int    // 1-based line number for error messages
+	indent int    // number of leading spaces
+	text   string // content without indentation and comments
+}
+
+// parseYAMLLite parses the small YAML subset used for configuration files:
+// nested mappings by indentation, "- " lists (of scalars or mappings), inline [a, b] lists,
+// quoted and plain scalars, and # comments. Scalars are returned as strings and are
+// converted to the target field type when the configuration is decoded.
+func parseYAMLLite(data []byte) (map[string]any, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		text := stripYAMLComment(raw)
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.TrimSpace(trimmed) == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") || strings.Contains(text[:len(text)-len(trimmed)], "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		if trimmed == "---" {
+			continue
+		}
+		lines = append(lines, yamlLine{
+			num:    i + 1,
+			indent: len(text) - len(trimmed),
+			text:   strings.TrimRight(trimmed, " "),
+		})
+	}
+
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+
+	p := &yamlParser{lines: lines}
+	value, err := p.parseBlock(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+
+	m, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("line %d: document must be a mapping", lines[0].num)
+	}
+	return m, nil
+}
+
+// yamlParser walks the significant lines of a document
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseBlock parses a mapping or a list whose lines start at the given indentation
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	if isYAMLListItem(p.lines[p.pos].text) {
+		return p.parseList(indent)
+	}
+	return p.parseMap(indent)
+}
+
+// parseMap parses "key: value" lines at the given indentation
+func (p *yamlParser) parseMap(indent int) (map[string]any, error) {
+	result := make(map[string]any)
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		if isYAMLListItem(line.text) {
+			return nil, fmt.Errorf("line %d: unexpected list item", line.num)
+		}
+
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.num)
+		}
+		if _, exists := result[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			value, err := parseYAMLScalar(rest, line.num)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+			continue
+		}
+
+		// A key without a value introduces a nested block, which may be a list at the same indentation
+		if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			if next.indent > indent || (next.indent == indent && isYAMLListItem(next.text)) {
+				value, err := p.parseBlock(next.indent)
+				if err != nil {
+					return nil, err
+				}
+				result[key] = value
+				continue
+			}
+		}
+		result[key] = nil
+	}
+	return result, nil
+}
+
+// parseList parses "- item" lines at the given indentation
+func (p *yamlParser) parseList(indent int) ([]any, error) {
+	result := []any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || !isYAMLListItem(line.text) {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+
+		item := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if item == "" {
+			// The item is a nested block on the following lines
+			p.pos++
+			if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+				result = append(result, nil)
+				continue
+			}
+			value, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+			continue
+		}
+
+		if _, _, isMap := splitYAMLKey(item); isMap && !strings.HasPrefix(item, `"`) && !strings.HasPrefix(item, "'") {
+			// "- key: value" starts a mapping whose keys are aligned with the first key
+			itemIndent := indent + len(line.text) - len(item)
+			p.lines[p.pos] = yamlLine{num: line.num, indent: itemIndent, text: item}
+			value, err := p.parseMap(itemIndent)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+			continue
+		}
+
+		value, err := parseYAMLScalar(item, line.num)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+		p.pos++
+	}
+	return result, nil
+}
+
+// isYAMLListItem reports whether a line is a list item
+func isYAMLListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits "key: value" or "key:" into its parts
+func splitYAMLKey(text string) (string, string, bool) {
+	if strings.HasSuffix(text, ":") {
+		key := strings.TrimSpace(strings.TrimSuffix(text, ":"))
+		return unquoteYAMLKey(key), "", key != "" && !strings.Contains(key, ": ")
+	}
+	key, rest, ok := strings.Cut(text, ": ")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return "", "", false
+	}
+	return unquoteYAMLKey(key), strings.TrimSpace(rest), true
+}
+
+// unquoteYAMLKey removes quotes around a key
+func unquoteYAMLKey(key string) string {
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
+
+// parseYAMLScalar parses a quoted, plain or inline-list value
+func parseYAMLScalar(text string, num int) (any, error) {
+	switch {
+	case strings.HasPrefix(text, `"`):
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", num, text)
+		}
+		return s, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", num, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("line %d: unterminated list %s", num, text)
+		}
+		items := []any{}
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		if inner == "" {
+			return items, nil
+		}
+		for _, item := range strings.Split(inner, ",") {
+			value, err := parseYAMLScalar(strings.TrimSpace(item), num)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	case text == "~" || text == "null":
+		return nil, nil
+	}
+	return text, nil
+}
+
+// stripYAMLComment removes a trailing # comment that is not inside quotes
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' '):
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,222 @@
+package hub
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig represents the cross-origin policy applied to every route. Without allowed
+// origins no CORS headers are sent and browsers only allow same-origin requests.
+type CORSConfig struct {
+	AllowedOrigins   []string      `json:"allowed_origins"`   // Origins such as "https://ui.example.com" or "https://*.example.com"; "*" for any
+	AllowedMethods   []string      `json:"allowed_methods"`   // Default: GET, HEAD, POST
+	AllowedHeaders   []string      `json:"allowed_headers"`   // Default: Authorization, Content-Type, Last-Event-ID, X-API-Key, Idempotency-Key, traceparent, tracestate
+	ExposedHeaders   []string      `json:"exposed_headers"`   // Response headers readable by scripts
+	AllowCredentials bool          `json:"allow_credentials"` // Allow cookies and HTTP authentication; requires explicit origins
+	MaxAge           time.Duration `json:"max_age"`           // Default: 10m; how long browsers may cache a preflight
+}
+
+// validate checks the CORS settings
+func (c CORSConfig) validate() []error {
+	var errs []error
+	for i, origin := range c.AllowedOrigins {
+		if origin == corsAnyOrigin {
+			if c.AllowCredentials {
+				errs = append(errs, fmt.Errorf("cors.allowed_origins[%d]: \"*\" cannot be combined with allow_credentials", i))
+			}
+			continue
+		}
+		if _, err := parseOriginPattern(origin); err != nil {
+			errs = append(errs, fmt.Errorf("cors.allowed_origins[%d]: %w", i, err))
+		}
+	}
+	for i, method := range c.AllowedMethods {
+		if method == "" || strings.ContainsAny(method, " ,") {
+			errs = append(errs, fmt.Errorf("cors.allowed_methods[%d]: %q is not an HTTP method", i, method))
+		}
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("cors.max_age: %s must not be negative", c.MaxAge))
+	}
+	return errs
+}
+
+// corsAnyOrigin allows every origin, without credentials
+const corsAnyOrigin = "*"
+
+// originPattern matches an origin by scheme, host and port. A host starting with "*."
+// matches any subdomain, at any depth, of the rest.
+type originPattern struct {
+	scheme string
+	host   string // Without the "*." prefix for wildcard patterns
+	port   string
+	suffix bool
+}
+
+// parseOriginPattern parses an allowed origin such as "https://*.example.com:8443"
+func parseOriginPattern(pattern string) (originPattern, error) {
+	u, err := url.Parse(pattern)
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return originPattern{}, fmt.Errorf("%q must be an origin like https://ui.example.com", pattern)
+	}
+	p := originPattern{scheme: strings.ToLower(u.Scheme), host: strings.ToLower(u.Hostname()), port: u.Port()}
+	if rest, ok := strings.CutPrefix(p.host, "*."); ok {
+		p.host, p.suffix = rest, true
+	}
+	if p.host == "" || strings.Contains(p.host, "*") {
+		return originPattern{}, fmt.Errorf("%q may only use a wildcard as the first label, like https://*.example.com", pattern)
+	}
+	return p, nil
+}
+
+// matches reports whether the pattern allows origin
+func (p originPattern) matches(origin *url.URL) bool {
+	host := strings.ToLower(origin.Hostname())
+	if strings.ToLower(origin.Scheme) != p.scheme || origin.Port() != p.port {
+		return false
+	}
+	if p.suffix {
+		return strings.HasSuffix(host, "."+p.host)
+	}
+	return host == p.host
+}
+
+// corsPolicy is the compiled form of a CORSConfig
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     []originPattern
+	methods     map[string]bool
+	headers     map[string]bool // Canonical header names
+	allowMethod string
+	allowHeader string
+	expose      string
+	credentials bool
+	maxAge      string
+}
+
+// newCORSPolicy compiles a validated CORS configuration
+func newCORSPolicy(config CORSConfig) *corsPolicy {
+	c := &corsPolicy{
+		methods:     make(map[string]bool),
+		headers:     make(map[string]bool),
+		allowMethod: strings.Join(config.AllowedMethods, ", "),
+		allowHeader: strings.Join(config.AllowedHeaders, ", "),
+		expose:      strings.Join(config.ExposedHeaders, ", "),
+		credentials: config.AllowCredentials,
+		maxAge:      strconv.Itoa(int(config.MaxAge.Seconds())),
+	}
+	for _, origin := range config.AllowedOrigins {
+		if origin == corsAnyOrigin {
+			c.anyOrigin = true
+			continue
+		}
+		if pattern, err := parseOriginPattern(origin); err == nil {
+			c.origins = append(c.origins, pattern)
+		}
+	}
+	for _, method := range config.AllowedMethods {
+		c.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range config.AllowedHeaders {
+		c.headers[http.CanonicalHeaderKey(header)] = true
+	}
+	return c
+}
+
+// enabled reports whether any origin is allowed
+func (c *corsPolicy) enabled() bool {
+	return c != nil && (c.anyOrigin || len(c.origins) > 0)
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, or "" if it is not allowed
+func (c *corsPolicy) allowOrigin(origin string) string {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	for _, pattern := range c.origins {
+		if pattern.matches(u) {
+			return origin
+		}
+	}
+	if c.anyOrigin {
+		return corsAnyOrigin
+	}
+	return ""
+}
+
+// allowsHeaders reports whether every header of an Access-Control-Request-Headers list is allowed
+func (c *corsPolicy) allowsHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !c.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+// getCORS returns the current CORS policy
+func (p *Hub) getCORS() *corsPolicy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cors
+}
+
+// withCORS adds CORS headers to responses for allowed origins and answers preflight requests.
+// Preflights are answered before authentication because browsers send them without credentials.
+func (p *Hub) withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cors := p.getCORS()
+		origin := r.Header.Get("Origin")
+		if !cors.enabled() || origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		allowed := cors.allowOrigin(origin)
+
+		// Preflight
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+
+			method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+			if allowed == "" || !cors.methods[method] || !cors.allowsHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+				WriteError(w, http.StatusForbidden, "Forbidden", "the cross-origin request is not allowed")
+				return
+			}
+			cors.setAllowHeaders(header, allowed)
+			header.Set("Access-Control-Allow-Methods", cors.allowMethod)
+			if cors.allowHeader != "" {
+				header.Set("Access-Control-Allow-Headers", cors.allowHeader)
+			}
+			header.Set("Access-Control-Max-Age", cors.maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Actual request: the browser enforces the policy on the response
+		if allowed != "" {
+			cors.setAllowHeaders(header, allowed)
+			if cors.expose != "" {
+				header.Set("Access-Control-Expose-Headers", cors.expose)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setAllowHeaders sets the headers common to preflight and actual responses
+func (c *corsPolicy) setAllowHeaders(header http.Header, allowed string) {
+	header.Set("Access-Control-Allow-Origin", allowed)
+	if c.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
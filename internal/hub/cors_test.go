@@ -0,0 +1,160 @@
+package hub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// corsTestHub returns a hub allowing the trading UI origins with credentials
+func corsTestHub(t *testing.T) *Hub {
+	t.Helper()
+	config := DefaultConfig()
+	config.CORS.AllowedOrigins = []string{"https://ui.example.com", "https://*.desk.example.com"}
+	config.CORS.AllowCredentials = true
+	config.CORS.ExposedHeaders = []string{"Retry-After"}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Invalid test config: %v", err)
+	}
+	platform := New(config)
+	platform.RegisterEndpoint("test", NewMockEndpoint([]byte(`"ok"`)))
+	return platform
+}
+
+func TestCORS_ActualRequests(t *testing.T) {
+	handler := corsTestHub(t).Handler()
+
+	tests := []struct {
+		path   string
+		origin string
+		want   string
+	}{
+		{"/test", "https://ui.example.com", "https://ui.example.com"},
+		{"/test/stream?max_count=1", "https://ui.example.com", "https://ui.example.com"},
+		{"/test", "https://eu.desk.example.com", "https://eu.desk.example.com"},
+		{"/test", "https://desk.example.com", ""},
+		{"/test", "http://ui.example.com", ""},
+		{"/test", "https://ui.example.com:8443", ""},
+		{"/test", "https://evil.com", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Origin", tt.origin)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s from %s: expected status code %d, got %d", tt.path, tt.origin, http.StatusOK, rr.Code)
+		}
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("%s from %s: expected Access-Control-Allow-Origin %q, got %q", tt.path, tt.origin, tt.want, got)
+		}
+		if tt.want != "" && rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("%s from %s: expected credentials to be allowed", tt.path, tt.origin)
+		}
+		if tt.want != "" && rr.Header().Get("Access-Control-Expose-Headers") != "Retry-After" {
+			t.Errorf("%s from %s: expected exposed headers", tt.path, tt.origin)
+		}
+		if !strings.Contains(strings.Join(rr.Header().Values("Vary"), ","), "Origin") {
+			t.Errorf("%s from %s: expected Vary: Origin", tt.path, tt.origin)
+		}
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	platform := corsTestHub(t)
+	platform.SetAuthenticator(roleAuthenticator{})
+	handler := platform.Handler()
+
+	preflight := func(origin, method, headers string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/test/stream", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		if headers != "" {
+			req.Header.Set("Access-Control-Request-Headers", headers)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Preflights are answered without credentials, even though the endpoint requires them
+	rr := preflight("https://ui.example.com", "GET", "authorization, last-event-id")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://ui.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, HEAD, POST",
+		"Access-Control-Max-Age":           "600",
+	} {
+		if got := rr.Header().Get(header); got != want {
+			t.Errorf("Expected %s %q, got %q", header, want, got)
+		}
+	}
+	if !strings.Contains(rr.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Errorf("Expected allowed headers, got %q", rr.Header().Get("Access-Control-Allow-Headers"))
+	}
+
+	for name, rr := range map[string]*httptest.ResponseRecorder{
+		"origin": preflight("https://evil.com", "GET", ""),
+		"method": preflight("https://ui.example.com", "DELETE", ""),
+		"header": preflight("https://ui.example.com", "GET", "X-Secret"),
+	} {
+		if rr.Code != http.StatusForbidden || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("Expected a preflight with a disallowed %s to be rejected, got %d", name, rr.Code)
+		}
+	}
+}
+
+func TestCORS_DisabledByDefault(t *testing.T) {
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("test", NewMockEndpoint([]byte(`"ok"`)))
+
+	req := httptest.NewRequest(http.MethodGet, "/test/stream?max_count=1", nil)
+	req.Header.Set("Origin", "https://ui.example.com")
+	rr := httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no CORS headers by default, got %q", got)
+	}
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	config := DefaultConfig()
+	config.CORS.AllowedOrigins = []string{"*"}
+	platform := New(config)
+	platform.RegisterEndpoint("test", NewMockEndpoint([]byte(`"ok"`)))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Origin", "https://anywhere.example.org")
+	rr := httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected a wildcard origin, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no credentials with a wildcard origin, got %q", got)
+	}
+}
+
+func TestCORSConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	config.CORS = CORSConfig{
+		AllowedOrigins:   []string{"*", "ui.example.com", "https://app.*.example.com", "https://ok.example.com/path"},
+		AllowCredentials: true,
+	}
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"allowed_origins[0]", "allowed_origins[1]", "allowed_origins[2]", "allowed_origins[3]"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package hub
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxPooledBuffer bounds the buffers kept for reuse, so that one large event does not pin
+// its memory for the life of the process
+const maxPooledBuffer = 64 << 10
+
+// bufferPool holds the buffers that carry payloads and encoded envelopes
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns a buffer to the pool. The buffer must not be used afterwards.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// writeData writes the data envelope of a payload to buf: {"data":<payload>}
+func writeData(buf *bytes.Buffer, payload json.RawMessage) {
+	buf.WriteString(`{"data":`)
+	writeValue(buf, payload)
+	buf.WriteByte('}')
+}
+
+// writeList writes the data envelope of a list of payloads to buf: {"data":[<payload>,...]}
+func writeList(buf *bytes.Buffer, payloads []json.RawMessage) {
+	buf.WriteString(`{"data":[`)
+	for i, payload := range payloads {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeValue(buf, payload)
+	}
+	buf.WriteString(`]}`)
+}
+
+// writeValue writes a payload to buf as a JSON value. A JSON payload is embedded as it is,
+// only compacted so that it holds on one SSE line, which keeps its numbers and key order
+// exactly. Any other payload is embedded as a string.
+func writeValue(buf *bytes.Buffer, payload json.RawMessage) {
+	// Compact validates while it copies, and leaves buf unchanged on error
+	start := buf.Len()
+	if !mayBeJSON(payload) || json.Compact(buf, payload) != nil {
+		writeString(buf, payload)
+		return
+	}
+	escapeLineSeparators(buf, start)
+}
+
+// lineSeparatorPrefix starts the UTF-8 encoding of U+2028 and U+2029
+var lineSeparatorPrefix = []byte("\xe2\x80")
+
+// escapeLineSeparators escapes U+2028 and U+2029 in buf from start, as writeString does.
+// Compact leaves them as they are, and in valid JSON they can only be inside strings.
+func escapeLineSeparators(buf *bytes.Buffer, start int) {
+	value := buf.Bytes()[start:]
+	i := bytes.Index(value, lineSeparatorPrefix)
+	if i < 0 {
+		return
+	}
+	tail := bytes.Clone(value[i:])
+	buf.Truncate(start + i)
+	last := 0
+	for j := 0; j+2 < len(tail); j++ {
+		if tail[j] == 0xe2 && tail[j+1] == 0x80 && (tail[j+2] == 0xa8 || tail[j+2] == 0xa9) {
+			buf.Write(tail[last:j])
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hexDigits[tail[j+2]&0xf])
+			j += 2
+			last = j + 1
+		}
+	}
+	buf.Write(tail[last:])
+}
+
+// mayBeJSON reports whether payload starts like a JSON value, so that text is encoded
+// without a parse attempt
+func mayBeJSON(payload []byte) bool {
+	for _, c := range payload {
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case '{', '[', '"', '-', 't', 'f', 'n':
+			return true
+		default:
+			return c >= '0' && c <= '9'
+		}
+	}
+	return false
+}
+
+// hexDigits are the digits of \u escapes
+const hexDigits = "0123456789abcdef"
+
+// writeString writes s to buf as a JSON string, as encoding/json does but without
+// escaping HTML characters. Invalid UTF-8 is replaced with U+FFFD.
+func writeString(buf *bytes.Buffer, s []byte) {
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			buf.Write(s[start:i])
+			switch c {
+			case '"', '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case '\n':
+				buf.WriteString(`\n`)
+			case '\r':
+				buf.WriteString(`\r`)
+			case '\t':
+				buf.WriteString(`\t`)
+			default:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRune(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf.Write(s[start:i])
+			buf.WriteString("\ufffd")
+		case r == '\u2028' || r == '\u2029':
+			// Valid JSON, but not valid JavaScript
+			buf.Write(s[start:i])
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hexDigits[r&0xf])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		start = i
+	}
+	buf.Write(s[start:])
+	buf.WriteByte('"')
+}
@@ -0,0 +1,102 @@
+package hub
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteData(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{"object", `{"b":1,"a":2}`, `{"data":{"b":1,"a":2}}`},
+		{"exact numbers", `{"id":9007199254740993,"price":1.10}`, `{"data":{"id":9007199254740993,"price":1.10}}`},
+		{"compacted", "{\n  \"a\": [1, 2]\n}\n", `{"data":{"a":[1,2]}}`},
+		{"scalar", `42`, `{"data":42}`},
+		{"string", `"ok"`, `{"data":"ok"}`},
+		{"text", `market closed`, `{"data":"market closed"}`},
+		{"text like JSON", `true story`, `{"data":"true story"}`},
+		{"truncated JSON", `{"a":`, `{"data":"{\"a\":"}`},
+		{"empty", ``, `{"data":""}`},
+		{"escaped text", "line 1\nline \"2\"\t\\", `{"data":"line 1\nline \"2\"\t\\"}`},
+		{"line separators in JSON", "{\"a\":\"x\u2028y\u2029z\u2026\"}", `{"data":{"a":"x\u2028y\u2029z` + "\u2026" + `"}}`},
+		{"line separators in text", "x\u2028y", `{"data":"x\u2028y"}`},
+	}
+	for _, tt := range tests {
+		buf := new(bytes.Buffer)
+		writeData(buf, []byte(tt.payload))
+		if buf.String() != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, buf.String())
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("%s: invalid JSON %s", tt.name, buf.String())
+		}
+	}
+}
+
+func TestWriteString(t *testing.T) {
+	// The encoding matches encoding/json without HTML escaping
+	for _, s := range []string{
+		"plain",
+		"<b>&</b>",
+		"quote \" backslash \\ slash /",
+		"\x00\x01\x1f\x7f",
+		"\r\n\t",
+		"héllo wörld €",
+		"bad \xff\xfe utf-8 \xe2\x82",
+		"line\u2028separator\u2029",
+	} {
+		var want bytes.Buffer
+		encoder := json.NewEncoder(&want)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(s)
+
+		got := new(bytes.Buffer)
+		writeString(got, []byte(s))
+		if got.String() != strings.TrimSuffix(want.String(), "\n") {
+			t.Errorf("%q: expected %s, got %s", s, want.String(), got.String())
+		}
+	}
+}
+
+func TestPutBuffer(t *testing.T) {
+	buf := getBuffer()
+	buf.WriteString("leftover")
+	putBuffer(buf)
+	if got := getBuffer(); got.Len() != 0 {
+		t.Errorf("Expected an empty buffer from the pool, got %q", got.String())
+	}
+
+	// A buffer grown past the limit is left to the garbage collector
+	large := new(bytes.Buffer)
+	large.Grow(2 * maxPooledBuffer)
+	putBuffer(large)
+	if got := getBuffer(); got == large {
+		t.Error("Expected the large buffer not to be pooled")
+	}
+}
+
+// multilineEndpoint writes an indented JSON object
+type multilineEndpoint struct{}
+
+// HandleSSE implements the Endpoint interface
+func (multilineEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("{\n  \"bid\": 1.0842,\n  \"ask\": 1.0845\n}\n"))
+}
+
+func TestSSE_MultilinePayload(t *testing.T) {
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("quotes", multilineEndpoint{})
+
+	rr := httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/quotes/stream", nil))
+	if want := "data: {\"data\":{\"bid\":1.0842,\"ask\":1.0845}}\n\n"; rr.Body.String() != want {
+		t.Errorf("Expected the event on one line, got %q", rr.Body.String())
+	}
+}
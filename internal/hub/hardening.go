@@ -0,0 +1,118 @@
+package hub
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// HardeningConfig represents the request limits and security headers applied to every route
+type HardeningConfig struct {
+	MaxHeaderBytes int           `json:"max_header_bytes"` // Default: 16384; larger request headers get 431
+	MaxURLLength   int           `json:"max_url_length"`   // Default: 2048; longer request URIs get 414
+	MaxQueryParams int           `json:"max_query_params"` // Default: 32; more query parameters get 400
+	HSTSMaxAge     time.Duration `json:"hsts_max_age"`     // Default: 8760h; Strict-Transport-Security on TLS responses, 0 to disable
+}
+
+// validate checks the hardening settings
+func (c HardeningConfig) validate() []error {
+	var errs []error
+	for name, value := range map[string]int{
+		"max_header_bytes": c.MaxHeaderBytes,
+		"max_url_length":   c.MaxURLLength,
+		"max_query_params": c.MaxQueryParams,
+	} {
+		if value < 1 {
+			errs = append(errs, fmt.Errorf("hardening.%s: %d must be positive", name, value))
+		}
+	}
+	if c.HSTSMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("hardening.hsts_max_age: %s must not be negative", c.HSTSMaxAge))
+	}
+	return errs
+}
+
+// getHardening returns the current hardening settings. A hub whose configuration was
+// never validated has zero limits, which get the defaults instead of refusing everything.
+func (p *Hub) getHardening() HardeningConfig {
+	p.mu.RLock()
+	config := p.config.Hardening
+	p.mu.RUnlock()
+
+	defaults := DefaultConfig().Hardening
+	if config.MaxHeaderBytes <= 0 {
+		config.MaxHeaderBytes = defaults.MaxHeaderBytes
+	}
+	if config.MaxURLLength <= 0 {
+		config.MaxURLLength = defaults.MaxURLLength
+	}
+	if config.MaxQueryParams <= 0 {
+		config.MaxQueryParams = defaults.MaxQueryParams
+	}
+	return config
+}
+
+// harden sets the security headers and rejects oversized or malformed requests with
+// JSON:API errors before any other handler sees them
+func (p *Hub) harden(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := p.getHardening()
+
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "no-referrer")
+		if r.TLS != nil && config.HSTSMaxAge > 0 {
+			header.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(config.HSTSMaxAge.Seconds())))
+		}
+
+		if len(r.RequestURI) > config.MaxURLLength {
+			rejectRequest(w, r, http.StatusRequestURITooLong, "URI Too Long",
+				fmt.Sprintf("the request URI is longer than %d bytes", config.MaxURLLength))
+			return
+		}
+		if size := headerSize(r.Header); size > config.MaxHeaderBytes {
+			rejectRequest(w, r, http.StatusRequestHeaderFieldsTooLarge, "Request Header Fields Too Large",
+				fmt.Sprintf("the request headers are larger than %d bytes", config.MaxHeaderBytes))
+			return
+		}
+
+		// The server does not validate the query string; a malformed one would be dropped silently
+		query, err := url.ParseQuery(r.URL.RawQuery)
+		if err != nil {
+			rejectRequest(w, r, http.StatusBadRequest, "Bad Request", "the query string is not correctly percent-encoded")
+			return
+		}
+		count := 0
+		for _, values := range query {
+			count += len(values)
+		}
+		if count > config.MaxQueryParams {
+			rejectRequest(w, r, http.StatusBadRequest, "Bad Request",
+				fmt.Sprintf("the query string has more than %d parameters", config.MaxQueryParams))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// headerSize approximates the wire size of the request headers
+func headerSize(header http.Header) int {
+	size := 0
+	for key, values := range header {
+		for _, value := range values {
+			size += len(key) + len(value) + len(": \r\n")
+		}
+	}
+	return size
+}
+
+// rejectRequest logs and answers a request refused by the hardening layer
+func rejectRequest(w http.ResponseWriter, r *http.Request, status int, title, detail string) {
+	slog.Warn("Rejected request", "status", status, "reason", detail, "remote_addr", r.RemoteAddr)
+	WriteError(w, status, title, detail)
+}
@@ -0,0 +1,142 @@
+package hub
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHardening_SecurityHeaders(t *testing.T) {
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("test", NewMockEndpoint([]byte(`"ok"`)))
+	handler := platform.Handler()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/test", nil))
+	for header, want := range map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+		"Cache-Control":          "no-store",
+	} {
+		if got := rr.Header().Get(header); got != want {
+			t.Errorf("Expected %s %q, got %q", header, want, got)
+		}
+	}
+	if got := rr.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Expected no HSTS over plain HTTP, got %q", got)
+	}
+
+	// HSTS is only sent over TLS
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.TLS = &tls.ConnectionState{}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Strict-Transport-Security"); got != "max-age=31536000" {
+		t.Errorf("Expected HSTS over TLS, got %q", got)
+	}
+}
+
+func TestHardening_RejectsRequests(t *testing.T) {
+	config := DefaultConfig()
+	config.Hardening.MaxURLLength = 64
+	config.Hardening.MaxQueryParams = 3
+	config.Hardening.MaxHeaderBytes = 256
+	platform := New(config)
+	platform.RegisterEndpoint("test", NewMockEndpoint([]byte(`"ok"`)))
+	handler := platform.Handler()
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		want   int
+	}{
+		{"ok", "/test?a=1&b=2", "", http.StatusOK},
+		{"long URL", "/test?q=" + strings.Repeat("x", 64), "", http.StatusRequestURITooLong},
+		{"too many params", "/test?a=1&a=2&b=3&c=4", "", http.StatusBadRequest},
+		{"bad escape", "/test?a=%zz", "", http.StatusBadRequest},
+		{"bad escape in stream", "/test/stream?max_count=%", "", http.StatusBadRequest},
+		{"large headers", "/test", strings.Repeat("y", 300), http.StatusRequestHeaderFieldsTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("X-Padding", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("Expected status code %d, got %d", tt.want, rr.Code)
+			}
+			if tt.want != http.StatusOK {
+				if response := decodeErrors(t, rr); len(response.Errors) != 1 {
+					t.Errorf("Expected a JSON:API error, got %+v", response)
+				}
+				if rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+					t.Error("Expected security headers on rejected requests")
+				}
+			}
+		})
+	}
+}
+
+func TestHardening_ZeroConfig(t *testing.T) {
+	// A hub used without validating its configuration applies the default limits
+	config := DefaultConfig()
+	config.Hardening = HardeningConfig{}
+	platform := New(config)
+	platform.RegisterEndpoint("test", NewMockEndpoint([]byte(`"ok"`)))
+	handler := platform.Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/test?a=1", nil)
+	req.Header.Set("X-Padding", "y")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/test?q="+strings.Repeat("x", DefaultConfig().Hardening.MaxURLLength), nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestURITooLong {
+		t.Errorf("Expected status code %d, got %d", http.StatusRequestURITooLong, rr.Code)
+	}
+}
+
+func TestHardeningConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	config.Hardening = HardeningConfig{MaxHeaderBytes: 1024, HSTSMaxAge: -1}
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"max_url_length", "max_query_params", "hsts_max_age"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+}
+
+func TestReload_Hardening(t *testing.T) {
+	platform := New(DefaultConfig())
+
+	next := DefaultConfig()
+	next.Hardening.MaxURLLength = 100
+	next.Hardening.MaxHeaderBytes = 1024
+	err := platform.Reload(next)
+	if err == nil || !strings.Contains(err.Error(), "hardening.max_header_bytes") {
+		t.Fatalf("Expected the header size change to be rejected, got %v", err)
+	}
+
+	got := platform.getHardening()
+	if got.MaxURLLength != 100 || got.MaxHeaderBytes != DefaultConfig().Hardening.MaxHeaderBytes {
+		t.Errorf("Unexpected hardening after reload: %+v", got)
+	}
+}
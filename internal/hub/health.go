@@ -0,0 +1,140 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// healthCheckTimeout bounds how long a single endpoint health check may take
+const healthCheckTimeout = 2 * time.Second
+
+// Health check statuses
+const (
+	healthPass = "pass"
+	healthFail = "fail"
+)
+
+// HealthChecker is an optional interface an endpoint can implement to contribute to the
+// hub's health and readiness probes, e.g. a price feed reporting that its upstream is stale.
+// CheckHealth returns nil when the endpoint is healthy.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// HealthCheck is the result of a single check in a probe response
+type HealthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// HealthStatus is the aggregated result of a probe
+type HealthStatus struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+// handleLiveness reports that the process is up and serving requests
+func (p *Hub) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, HealthStatus{Status: healthPass, Checks: []HealthCheck{}})
+}
+
+// handleHealth reports the aggregated result of all endpoint health checks
+func (p *Hub) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, aggregateHealth(p.runHealthChecks(r.Context())))
+}
+
+// handleReadiness reports whether the hub should receive traffic.
+// It fails as soon as a graceful shutdown begins.
+func (p *Hub) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	shutdown := HealthCheck{Name: "shutdown", Status: healthPass}
+	if p.shuttingDown.Load() {
+		shutdown.Status = healthFail
+		shutdown.Detail = "hub is shutting down"
+	}
+
+	checks := append([]HealthCheck{shutdown}, p.runHealthChecks(r.Context())...)
+	writeHealth(w, aggregateHealth(checks))
+}
+
+// runHealthChecks runs the checks of every endpoint implementing HealthChecker concurrently.
+// A check that has not returned within healthCheckTimeout, even if it ignores its context,
+// is reported as failed. Errors are logged rather than shown, since the probes are anonymous.
+func (p *Hub) runHealthChecks(ctx context.Context) []HealthCheck {
+	p.mu.RLock()
+	checkers := make(map[string]HealthChecker)
+	for name, endpoint := range p.endpoints {
+		if checker, ok := endpoint.(HealthChecker); ok {
+			checkers[name] = checker
+		}
+	}
+	p.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	// The channel is buffered so that checks finishing late do not block
+	results := make(chan HealthCheck, len(checkers))
+	for name, checker := range checkers {
+		go func() {
+			check := HealthCheck{Name: name, Status: healthPass}
+			if err := checker.CheckHealth(ctx); err != nil {
+				slog.Warn("Health check failed", "endpoint", name, "error", err)
+				check.Status = healthFail
+				check.Detail = "the check failed"
+			}
+			results <- check
+		}()
+	}
+
+	checks := make([]HealthCheck, 0, len(checkers))
+	reported := make(map[string]bool, len(checkers))
+collect:
+	for len(checks) < len(checkers) {
+		select {
+		case check := <-results:
+			checks = append(checks, check)
+			reported[check.Name] = true
+		case <-ctx.Done():
+			break collect
+		}
+	}
+	for name := range checkers {
+		if !reported[name] {
+			slog.Warn("Health check timed out", "endpoint", name, "timeout", healthCheckTimeout.String())
+			checks = append(checks, HealthCheck{Name: name, Status: healthFail, Detail: "the check timed out"})
+		}
+	}
+
+	sort.Slice(checks, func(i, j int) bool { return checks[i].Name < checks[j].Name })
+	return checks
+}
+
+// aggregateHealth combines individual checks into an overall status
+func aggregateHealth(checks []HealthCheck) HealthStatus {
+	status := HealthStatus{Status: healthPass, Checks: checks}
+	for _, check := range checks {
+		if check.Status != healthPass {
+			status.Status = healthFail
+			break
+		}
+	}
+	return status
+}
+
+// writeHealth writes a probe result wrapped in a data field, using 503 when the probe fails
+func writeHealth(w http.ResponseWriter, status HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if status.Status != healthPass {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(w).Encode(DataResponse{Data: status}); err != nil {
+		slog.Error("Error encoding health response", "error", err)
+	}
+}
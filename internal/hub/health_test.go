@@ -0,0 +1,153 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// healthyEndpoint is a mock endpoint that reports a fixed health check result
+type healthyEndpoint struct {
+	MockEndpoint
+	err error
+}
+
+// CheckHealth implements the HealthChecker interface
+func (h *healthyEndpoint) CheckHealth(ctx context.Context) error {
+	return h.err
+}
+
+// getHealth performs a probe request and decodes its result
+func getHealth(t *testing.T, handler http.Handler, path string) (int, HealthStatus) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var response struct {
+		Data HealthStatus `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding %s response: %v", path, err)
+	}
+	return rr.Code, response.Data
+}
+
+func TestHealth_AggregatesEndpointChecks(t *testing.T) {
+	logs := captureLogs(t)
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("prices", &healthyEndpoint{err: errors.New("feed stale for 30s")})
+	platform.RegisterEndpoint("date", &healthyEndpoint{})
+	platform.RegisterEndpoint("plain", NewMockEndpoint(nil))
+	handler := platform.Handler()
+
+	code, status := getHealth(t, handler, "/healthz")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, code)
+	}
+	if status.Status != healthFail {
+		t.Errorf("Expected status %q, got %q", healthFail, status.Status)
+	}
+	if len(status.Checks) != 2 {
+		t.Fatalf("Expected 2 checks, got %+v", status.Checks)
+	}
+	if status.Checks[0].Name != "date" || status.Checks[0].Status != healthPass {
+		t.Errorf("Unexpected date check: %+v", status.Checks[0])
+	}
+	// The error is logged, not shown to anonymous callers
+	if status.Checks[1].Name != "prices" || status.Checks[1].Detail != "the check failed" {
+		t.Errorf("Unexpected prices check: %+v", status.Checks[1])
+	}
+	if !strings.Contains(logs.String(), "feed stale for 30s") {
+		t.Errorf("Expected the error to be logged, got %s", logs)
+	}
+
+	// Liveness does not depend on endpoint checks
+	code, status = getHealth(t, handler, "/livez")
+	if code != http.StatusOK || status.Status != healthPass {
+		t.Errorf("Expected live probe to pass, got %d %+v", code, status)
+	}
+}
+
+// stuckEndpoint has a health check that ignores its context until released
+type stuckEndpoint struct {
+	MockEndpoint
+	release chan struct{}
+}
+
+// CheckHealth implements the HealthChecker interface
+func (s *stuckEndpoint) CheckHealth(ctx context.Context) error {
+	<-s.release
+	return nil
+}
+
+func TestHealth_Timeout(t *testing.T) {
+	logs := captureLogs(t)
+	stuck := &stuckEndpoint{release: make(chan struct{})}
+	defer close(stuck.release)
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("feed", stuck)
+	platform.RegisterEndpoint("date", &healthyEndpoint{})
+	handler := platform.Handler()
+
+	// The probe returns by its deadline although the check does not
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	rr := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil).WithContext(ctx))
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the probe to return when its deadline passed")
+	}
+
+	var response struct {
+		Data HealthStatus `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	want := []HealthCheck{
+		{Name: "date", Status: healthPass},
+		{Name: "feed", Status: healthFail, Detail: "the check timed out"},
+	}
+	if rr.Code != http.StatusServiceUnavailable || !reflect.DeepEqual(response.Data.Checks, want) {
+		t.Errorf("Expected 503 with %+v, got %d %+v", want, rr.Code, response.Data.Checks)
+	}
+	if !strings.Contains(logs.String(), "Health check timed out") {
+		t.Errorf("Expected the timeout to be logged, got %s", logs)
+	}
+}
+
+func TestHealth_ReadinessDuringShutdown(t *testing.T) {
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("date", &healthyEndpoint{})
+	handler := platform.Handler()
+
+	code, status := getHealth(t, handler, "/readyz")
+	if code != http.StatusOK || status.Status != healthPass {
+		t.Fatalf("Expected ready probe to pass, got %d %+v", code, status)
+	}
+
+	if err := platform.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Error shutting down: %v", err)
+	}
+
+	code, status = getHealth(t, handler, "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, code)
+	}
+	if status.Checks[0].Name != "shutdown" || status.Checks[0].Status != healthFail {
+		t.Errorf("Expected failing shutdown check, got %+v", status.Checks)
+	}
+}
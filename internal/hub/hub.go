@@ -4,28 +4,49 @@
 package hub
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"trading/internal/audit"
+	"trading/internal/clock"
+	"trading/internal/recording"
+	"trading/internal/trace"
+)
+
+// Transports through which an endpoint can be reached
+const (
+	transportREST = "rest"
+	transportSSE  = "sse"
 )
 
-// Config represents the configuration for the hub
-type Config struct {
-	Port     string // Default: "8080"
-	LogLevel string // Default: "info"
+// defaultWriteTimeout bounds the writing of a response, and of each event of a stream
+const defaultWriteTimeout = 10 * time.Second
+
+// reservedNames are paths served by the hub itself that endpoints cannot use
+var reservedNames = map[string]bool{
+	"metrics": true,
+	"healthz": true,
+	"readyz":  true,
+	"livez":   true,
+	"admin":   true,
 }
 
-// DefaultConfig returns a Config with default values
-func DefaultConfig() Config {
-	return Config{
-		Port:     "8080",
-		LogLevel: "info",
-	}
+// isReservedName reports whether an endpoint name collides with a built-in route
+func isReservedName(name string) bool {
+	return reservedNames[name]
 }
 
 // Error represents an error in the JSON API format
@@ -73,74 +94,116 @@ type DataResponse struct {
 	Data interface{} `json:"data"`
 }
 
-// responseRecorder is a simple implementation of http.ResponseWriter for capturing responses
-type responseRecorder struct {
-	header http.Header
-	body   *strings.Builder
-	code   int
-}
-
-// Header returns the header map that will be sent by WriteHeader
-func (r *responseRecorder) Header() http.Header {
-	return r.header
-}
-
-// Write writes the data to the response body
-func (r *responseRecorder) Write(b []byte) (int, error) {
-	return r.body.Write(b)
-}
-
-// WriteHeader sends an HTTP response header with the provided status code
-func (r *responseRecorder) WriteHeader(statusCode int) {
-	r.code = statusCode
-}
-
-// BodyString returns the response body as a string
-func (r *responseRecorder) BodyString() string {
-	return r.body.String()
-}
-
-// BodyBytes returns the response body as a byte slice
-func (r *responseRecorder) BodyBytes() []byte {
-	return []byte(r.body.String())
-}
-
 // customResponseWriter is a custom implementation of http.ResponseWriter that sends
 // the response to a channel instead of writing it directly
 type customResponseWriter struct {
 	http.ResponseWriter
-	responseChan chan<- []byte
+	responseChan chan<- *bytes.Buffer
 }
 
-// Write sends the data to the response channel
+// Write sends a copy of the data to the channel, in a pooled buffer that the receiver
+// returns to the pool
 func (w *customResponseWriter) Write(b []byte) (int, error) {
-	// Send a copy of the data to the channel
-	data := make([]byte, len(b))
-	copy(data, b)
+	data := getBuffer()
+	data.Write(b)
 	w.responseChan <- data
 	return len(b), nil
 }
 
 // Hub represents the web service hub
 type Hub struct {
-	endpoints map[string]Endpoint // 8 bytes
-	config    Config              // 32 bytes
-	mu        sync.RWMutex        // 8 bytes
+	endpoints        map[string]Endpoint        // 8 bytes
+	order            []string                   // 24 bytes; endpoint names in registration order
+	started          []namedEndpoint            // 24 bytes; guarded by lifecycleMu, see StartEndpoints
+	options          map[string]endpointOptions // 8 bytes
+	config           Config                     // 32 bytes
+	metrics          *metrics                   // 8 bytes
+	tracer           *trace.Tracer              // 8 bytes
+	logLevel         *slog.LevelVar             // 8 bytes
+	tls              *tlsManager                // 8 bytes
+	authenticator    Authenticator              // 16 bytes
+	nonces           *nonceCache                // 8 bytes; shared by HMAC authenticators, see SetAuthenticator
+	auditor          audit.Recorder             // 16 bytes
+	clock            clock.Clock                // 16 bytes
+	writeTimeout     time.Duration              // 8 bytes
+	cache            *responseCache             // 8 bytes
+	policy           *policy                    // 8 bytes
+	limiter          *limiter                   // 8 bytes
+	cors             *corsPolicy                // 8 bytes
+	panics           *panicTracker              // 8 bytes
+	idempotency      IdempotencyStore           // 16 bytes
+	idempotencyLocks *keyedLocks                // 8 bytes
+	server           *http.Server               // 8 bytes
+	baseCtx          context.Context            // 16 bytes
+	cancelBase       context.CancelFunc         // 8 bytes
+	shuttingDown     atomic.Bool                // 4 bytes
+	mu               sync.RWMutex               // 8 bytes
+	lifecycleMu      sync.Mutex                 // 8 bytes
 }
 
 // New creates a new Hub with the given configuration
 func New(config Config) *Hub {
+	// The base context is canceled on shutdown so that long-lived streams end
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+
+	// The log level can be changed while running, see Reload
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(getLogLevel(config.LogLevel))
+
 	return &Hub{
-		config:    config,
-		endpoints: make(map[string]Endpoint),
+		config:           config,
+		endpoints:        make(map[string]Endpoint),
+		options:          make(map[string]endpointOptions),
+		policy:           newPolicy(config.Policy),
+		limiter:          newLimiter(config.Limits),
+		cors:             newCORSPolicy(config.CORS),
+		panics:           newPanicTracker(),
+		idempotency:      NewMemoryIdempotencyStore(),
+		nonces:           newNonceCache(),
+		idempotencyLocks: newKeyedLocks(),
+		clock:            clock.Real,
+		writeTimeout:     defaultWriteTimeout,
+		cache:            newResponseCache(responseCacheSize),
+		metrics:          newMetrics(),
+		logLevel:         logLevel,
+		baseCtx:          baseCtx,
+		cancelBase:       cancelBase,
+	}
+}
+
+// EndpointOption configures how the hub serves an endpoint
+type EndpointOption func(*endpointOptions)
+
+// endpointOptions holds the per-endpoint settings given at registration
+type endpointOptions struct {
+	scopes    []string
+	list      bool         // REST responses are lists, see WithListResponse
+	noContent bool         // Empty REST responses get 204, see WithNoContent
+	cache     *CachePolicy // REST responses may be reused, see WithCachePolicy
+}
+
+// WithScopes requires callers to hold every listed scope. Requests without them are
+// answered with 403 before the endpoint runs.
+func WithScopes(scopes ...string) EndpointOption {
+	return func(o *endpointOptions) {
+		o.scopes = append(o.scopes, scopes...)
 	}
 }
 
 // RegisterEndpoint registers an endpoint with the hub
-func (p *Hub) RegisterEndpoint(name string, endpoint Endpoint) {
+func (p *Hub) RegisterEndpoint(name string, endpoint Endpoint, opts ...EndpointOption) {
+	var options endpointOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if _, ok := p.endpoints[name]; !ok {
+		p.order = append(p.order, name)
+	}
 	p.endpoints[name] = endpoint
+	p.options[name] = options
 }
 
 // getMaxCount extracts the max_count parameter from the request
@@ -163,151 +226,465 @@ func getMaxCount(r *http.Request) int {
 
 // Start starts the hub server
 func (p *Hub) Start() error {
+	config := p.getConfig()
+
+	// Refuse to start with an invalid configuration
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", ":"+config.Port)
+	if err != nil {
+		return err
+	}
+	return p.Serve(listener)
+}
+
+// Serve serves the hub on listener until Shutdown, as Start does on the configured port.
+// The listener is closed when Serve returns.
+func (p *Hub) Serve(listener net.Listener) error {
+	config := p.getConfig()
+
+	// Refuse to start with an invalid configuration
+	if err := config.Validate(); err != nil {
+		listener.Close()
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	// Set up logging
-	logLevel := getLogLevel(p.config.LogLevel)
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: logLevel,
+		Level: p.logLevel,
 	}))
 	slog.SetDefault(logger)
 
-	// Set up HTTP server
+	// Start server with timeouts
+	slog.Info("Starting server", "address", listener.Addr().String())
+
+	server := &http.Server{
+		Handler:           p.Handler(),
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      p.writeTimeout,
+		IdleTimeout:       120 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		MaxHeaderBytes:    config.Hardening.MaxHeaderBytes,
+		BaseContext:       func(net.Listener) context.Context { return p.baseCtx },
+	}
+
+	// Terminate TLS when a certificate is configured
+	var tlsManager *tlsManager
+	if config.TLS.Enabled() {
+		var err error
+		tlsManager, err = newTLSManager(config.TLS)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		server.TLSConfig = tlsManager.serverConfig()
+		slog.Info("TLS enabled", "min_version", config.TLS.MinVersion, "client_auth", config.TLS.ClientAuth)
+	}
+
+	// Initialize the endpoints before accepting connections
+	if err := p.StartEndpoints(p.baseCtx); err != nil {
+		listener.Close()
+		return fmt.Errorf("starting endpoints: %w", err)
+	}
+
+	p.mu.Lock()
+	if p.shuttingDown.Load() {
+		p.mu.Unlock()
+		listener.Close()
+		p.StopEndpoints(context.Background())
+		return http.ErrServerClosed
+	}
+	p.server = server
+	p.tls = tlsManager
+	p.mu.Unlock()
+
+	// Cap the number of open connections
+	if config.Limits.MaxConnections > 0 {
+		listener = newLimitListener(listener, config.Limits.MaxConnections)
+	}
+
+	var err error
+	if tlsManager != nil {
+		err = server.ServeTLS(listener, "", "")
+	} else {
+		err = server.Serve(listener)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		p.StopEndpoints(context.Background())
+		return err
+	}
+	return nil
+}
+
+// Shutdown gracefully stops the hub. Readiness turns false immediately, open streams
+// are canceled and the server waits for in-flight requests until ctx expires.
+func (p *Hub) Shutdown(ctx context.Context) error {
+	slog.Info("Shutting down server")
+	p.shuttingDown.Store(true)
+
+	p.mu.RLock()
+	server := p.server
+	p.mu.RUnlock()
+
+	// End long-lived streams so the server can drain
+	p.cancelBase()
+
+	// Stop the endpoints once no request uses them
+	var err error
+	if server != nil {
+		err = server.Shutdown(ctx)
+	}
+	return errors.Join(err, p.StopEndpoints(ctx))
+}
+
+// Handler builds the HTTP handler serving the built-in routes and every registered endpoint,
+// as Start does. Endpoints registered afterwards are not served by it.
+func (p *Hub) Handler() http.Handler {
 	mux := http.NewServeMux()
 
+	// Built-in routes
+	mux.Handle("/metrics", p.metrics)
+	mux.HandleFunc("/livez", p.handleLiveness)
+	mux.HandleFunc("/healthz", p.handleHealth)
+	mux.HandleFunc("/readyz", p.handleReadiness)
+	mux.Handle("/admin/config", p.protect(adminEndpoint, transportREST, nil, http.HandlerFunc(p.handleAdminConfig)))
+	mux.Handle("/admin/endpoints", p.protect(adminEndpoint, transportREST, nil, http.HandlerFunc(p.handleAdminEndpoints)))
+
 	// Register endpoints
 	p.mu.RLock()
 	for name, endpoint := range p.endpoints {
-		endpointName := name // Create a new variable to avoid closure issues
-		endpointHandler := endpoint
+		if isReservedName(name) {
+			slog.Error("Endpoint name is reserved, skipping", "endpoint", name)
+			continue
+		}
+		options := p.options[name]
+		scopes := options.scopes
 
 		// REST endpoint (special case of SSE with max_count=1)
-		mux.HandleFunc("/"+endpointName, func(w http.ResponseWriter, r *http.Request) {
-			slog.Info("Received REST request", "endpoint", endpointName, "method", r.Method, "path", r.URL.Path)
+		rest := p.limit(name, transportREST, p.idempotent(name, p.quarantine(name, p.restHandler(name, endpoint, options))))
+		mux.Handle("/"+name, p.instrument(name, transportREST, p.protect(name, transportREST, scopes, rest)))
+
+		// SSE endpoint
+		sse := p.limit(name, transportSSE, p.quarantine(name, p.sseHandler(name, endpoint)))
+		mux.Handle("/"+name+"/stream", p.instrument(name, transportSSE, p.protect(name, transportSSE, scopes, sse)))
+	}
+	p.mu.RUnlock()
+
+	return p.harden(withClientIdentity(p.withCORS(mux)))
+}
 
-			// Set max_count=1 for REST requests
+// restHandler returns the REST handler for an endpoint. The response carries the first
+// write of the endpoint, or all of them for a list endpoint.
+func (p *Hub) restHandler(endpointName string, endpointHandler Endpoint, options endpointOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.Info("Received REST request", "endpoint", endpointName, "method", r.Method, "path", r.URL.Path)
+
+		// Set max_count=1 for REST requests, unless the endpoint answers with a list
+		maxCount := 1
+		if options.list {
+			maxCount = getMaxCount(r)
+		} else {
 			q := r.URL.Query()
 			q.Set("max_count", "1")
 			r.URL.RawQuery = q.Encode()
-
-			// Create a response recorder to capture the endpoint's response
-			rr := &responseRecorder{
-				header: make(http.Header),
-				body:   new(strings.Builder),
-				code:   http.StatusOK,
-			}
-			endpointHandler.HandleSSE(rr, r)
-
-			// Copy the headers from the recorder to the response writer
-			for k, v := range rr.Header() {
-				w.Header()[k] = v
+		}
+
+		// Trace the request, continuing the caller's trace if there is one
+		r, span := p.startRequestSpan(r, r.Method+" /"+endpointName, endpointName, maxCount)
+		status := http.StatusOK
+		defer func() { endSpanWithStatus(span, status) }()
+
+		// Answer from the cache when the endpoint's policy stores responses
+		var cacheKey string
+		if policy := options.cache; policy != nil && policy.Store && policy.MaxAge > 0 && isCacheableMethod(r.Method) {
+			cacheKey = responseCacheKey(endpointName, r, policy.Public)
+			now := p.getClock().Now()
+			if entry := p.cache.get(cacheKey, now); entry != nil {
+				p.metrics.cacheRequests.inc(endpointName, "hit")
+				for k, v := range entry.header {
+					w.Header()[k] = slices.Clone(v)
+				}
+				w.Header().Set("Age", strconv.Itoa(int(now.Sub(entry.stored)/time.Second)))
+				extendWriteDeadline(w, p.writeTimeout)
+				status = writeRESTBody(w, r, endpointName, entry.body)
+				return
 			}
-
-			// Set the content type to application/json for REST
-			w.Header().Set("Content-Type", "application/json")
-
-			// Check if the response is an error
-			if rr.code != http.StatusOK {
-				w.WriteHeader(rr.code)
-				w.Write(rr.BodyBytes())
+			p.metrics.cacheRequests.inc(endpointName, "miss")
+		}
+
+		// Run the endpoint until it returns or the deadline passes
+		timeout := p.getREST().Timeout
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		rr := newRESTRecorder()
+		done := make(chan bool, 1)
+		go func() {
+			done <- p.callEndpoint(endpointName, transportREST, endpointHandler, rr, r.WithContext(ctx))
+		}()
+
+		var panicked bool
+		select {
+		case panicked = <-done:
+		case <-ctx.Done():
+			// The endpoint may have returned just as the deadline passed
+			select {
+			case panicked = <-done:
+			default:
+				rr.abandon()
+				if completion, ok := r.Context().Value(restCompletionKey{}).(*restCompletion); ok {
+					completion.abandoned(done)
+				}
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					slog.Warn("Endpoint missed the REST deadline", "endpoint", endpointName, "timeout", timeout.String())
+					status = http.StatusGatewayTimeout
+					extendWriteDeadline(w, p.writeTimeout)
+					WriteError(w, http.StatusGatewayTimeout, "Gateway Timeout",
+						fmt.Sprintf("the endpoint did not respond within %s", timeout))
+				}
 				return
 			}
-
-			// Parse the response body
-			var responseData interface{}
-			if err := json.Unmarshal(rr.BodyBytes(), &responseData); err != nil {
-				// If the response is not valid JSON, wrap it as a string
-				responseData = rr.BodyString()
+		}
+		defer rr.release()
+
+		if panicked {
+			status = http.StatusInternalServerError
+			WriteError(w, http.StatusInternalServerError, "Internal Server Error", "the endpoint failed to handle the request")
+			return
+		}
+
+		// Financial data must not be stored by caches unless the endpoint says otherwise
+		w.Header().Set("Cache-Control", "no-store")
+
+		// Copy the headers from the recorder to the response writer
+		for k, v := range rr.Header() {
+			w.Header()[k] = v
+		}
+
+		// Set the content type to application/json for REST
+		w.Header().Set("Content-Type", "application/json")
+		extendWriteDeadline(w, p.writeTimeout)
+
+		// Check if the response is an error; its body is passed on as written
+		if rr.code != http.StatusOK {
+			status = rr.code
+			w.WriteHeader(rr.code)
+			for _, data := range rr.writes {
+				w.Write(data.Bytes())
 			}
-
-			// Wrap the response in a data field
-			wrappedResponse := DataResponse{
-				Data: responseData,
+			return
+		}
+
+		// Wrap the response in a data field
+		out := getBuffer()
+		defer putBuffer(out)
+		switch {
+		case options.list:
+			writeList(out, rr.payloads())
+		case len(rr.writes) == 0 && options.noContent:
+			status = http.StatusNoContent
+			w.Header().Del("Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case len(rr.writes) == 0:
+			status = http.StatusNotFound
+			WriteError(w, http.StatusNotFound, "Not Found", "the endpoint returned no data")
+			return
+		default:
+			if len(rr.writes) > 1 {
+				slog.Debug("Ignoring REST writes after the first", "endpoint", endpointName, "writes", len(rr.writes))
 			}
+			writeData(out, rr.writes[0].Bytes())
+		}
+		out.WriteByte('\n')
+		body := out.Bytes()
+
+		// Let clients revalidate their copy, and reuse it as the endpoint's policy allows
+		w.Header().Set("ETag", etag(body))
+		if options.cache != nil && rr.Header().Get("Cache-Control") == "" {
+			w.Header().Set("Cache-Control", options.cache.header())
+		}
+		if cacheKey != "" {
+			now := p.getClock().Now()
+			p.cache.put(cacheKey, &cachedResponse{
+				header:  cachedHeader(rr.Header(), w.Header()),
+				body:    bytes.Clone(body),
+				stored:  now,
+				expires: now.Add(options.cache.MaxAge),
+			}, now)
+		}
+		status = writeRESTBody(w, r, endpointName, body)
+	}
+}
 
-			// Encode the wrapped response
-			if err := json.NewEncoder(w).Encode(wrappedResponse); err != nil {
-				slog.Error("Error encoding response", "error", err)
-				http.Error(w, "Error encoding response", http.StatusInternalServerError)
-				return
+// sseHandler returns the SSE handler for an endpoint
+func (p *Hub) sseHandler(endpointName string, endpointHandler Endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.Info("Received SSE request", "endpoint", endpointName, "method", r.Method, "path", r.URL.Path)
+
+		// Set SSE headers
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		// Trace the stream, continuing the caller's trace if there is one
+		maxCount := getMaxCount(r)
+		r, span := p.startRequestSpan(r, r.Method+" /"+endpointName+"/stream", endpointName, maxCount)
+		status := http.StatusOK
+		defer func() { endSpanWithStatus(span, status) }()
+
+		// The endpoint's context ends with the stream, including when credentials expire
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		// Check if streaming is supported
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			slog.Error("Streaming not supported")
+			status = http.StatusInternalServerError
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		// Track the stream for the lifetime of the connection
+		clk := p.getClock()
+		started := clk.Now()
+		p.metrics.activeStreams.add(1, endpointName)
+		defer func() {
+			p.metrics.activeStreams.add(-1, endpointName)
+			p.metrics.streamDuration.observe(clk.Now().Sub(started).Seconds(), endpointName)
+		}()
+
+		// Record the stream for replay when configured
+		recorder := p.startRecording(endpointName, r)
+		defer func() {
+			if recorder != nil {
+				recorder.Close()
 			}
-		})
-
-		// SSE endpoint
-		mux.HandleFunc("/"+endpointName+"/stream", func(w http.ResponseWriter, r *http.Request) {
-			slog.Info("Received SSE request", "endpoint", endpointName, "method", r.Method, "path", r.URL.Path)
-
-			// Set SSE headers
-			w.Header().Set("Content-Type", "text/event-stream")
-			w.Header().Set("Cache-Control", "no-cache")
-			w.Header().Set("Connection", "keep-alive")
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-
-			// Check if streaming is supported
-			flusher, ok := w.(http.Flusher)
-			if !ok {
-				slog.Error("Streaming not supported")
-				http.Error(w, "Streaming not supported", http.StatusInternalServerError)
-				return
+		}()
+
+		// Create a channel to receive responses from the endpoint
+		responseChan := make(chan *bytes.Buffer)
+
+		// Start the endpoint handler in a goroutine. A panic is recovered there, since it
+		// would otherwise take down the whole process; it is read once the channel is closed.
+		var panicked bool
+		go func() {
+			// Create a custom response writer that captures the response
+			customWriter := &customResponseWriter{
+				ResponseWriter: w,
+				responseChan:   responseChan,
 			}
 
-			// Create a channel to receive responses from the endpoint
-			responseChan := make(chan []byte)
-
-			// Start the endpoint handler in a goroutine
-			go func() {
-				// Create a custom response writer that captures the response
-				customWriter := &customResponseWriter{
-					ResponseWriter: w,
-					responseChan:   responseChan,
+			// Call the endpoint handler
+			panicked = p.callEndpoint(endpointName, transportSSE, endpointHandler, customWriter, r)
+			close(responseChan)
+		}()
+
+		// End the stream when the caller's credentials expire
+		var expired <-chan time.Time
+		if principal, ok := PrincipalFromContext(r.Context()); ok && !principal.ExpiresAt.IsZero() {
+			timer := clk.NewTimer(principal.ExpiresAt.Sub(clk.Now()))
+			defer timer.Stop()
+			expired = timer.C()
+		}
+
+		// Process responses from the endpoint. Each event is encoded into one buffer that
+		// the stream reuses.
+		out := getBuffer()
+		defer putBuffer(out)
+		index := 0
+		for {
+			var data *bytes.Buffer
+			select {
+			case received, ok := <-responseChan:
+				if !ok {
+					if panicked {
+						status = http.StatusInternalServerError
+						extendWriteDeadline(w, p.writeTimeout)
+						writeSSEError(w, flusher, http.StatusInternalServerError, "Internal Server Error", "the endpoint failed and the stream ended")
+					}
+					return
 				}
-
-				// Call the endpoint handler
-				endpointHandler.HandleSSE(customWriter, r)
-				close(responseChan)
-			}()
-
-			// Process responses from the endpoint
-			for responseData := range responseChan {
-				// Parse the response body
-				var responseObj interface{}
-				if err := json.Unmarshal(responseData, &responseObj); err != nil {
-					// If the response is not valid JSON, wrap it as a string
-					responseObj = string(responseData)
+				data = received
+			case <-expired:
+				slog.Info("Credentials expired, ending stream", "endpoint", endpointName)
+				status = http.StatusUnauthorized
+				extendWriteDeadline(w, p.writeTimeout)
+				writeSSEError(w, flusher, http.StatusUnauthorized, "Unauthorized", "the credentials expired")
+				cancel()
+				// Keep draining the channel until the endpoint goroutine returns
+				for data := range responseChan {
+					putBuffer(data)
 				}
+				return
+			}
 
-				// Wrap the response in a data field
-				wrappedResponse := DataResponse{
-					Data: responseObj,
+			if recorder != nil {
+				if err := recorder.Write(recording.NewEvent(clk.Now(), data.Bytes())); err != nil {
+					slog.Error("Error recording event, stopping the recording", "endpoint", endpointName, "error", err)
+					recorder.Close()
+					recorder = nil
 				}
+			}
 
-				// Encode the wrapped response
-				wrappedData, err := json.Marshal(wrappedResponse)
-				if err != nil {
-					slog.Error("Error encoding SSE response", "error", err)
-					continue
-				}
+			eventSpan := p.startEventSpan(r.Context(), endpointName, maxCount, index)
+			index++
 
-				// Send the response as an SSE event
-				fmt.Fprintf(w, "data: %s\n\n", wrappedData)
-				flusher.Flush()
+			// Wrap the response in a data field
+			out.Reset()
+			out.WriteString("data: ")
+			writeData(out, data.Bytes())
+			out.WriteString("\n\n")
+			putBuffer(data)
+
+			// Send the response as an SSE event. The server's write timeout would end the
+			// stream after its first seconds, so the deadline is moved with each event; a
+			// client that stops reading is still dropped.
+			extendWriteDeadline(w, p.writeTimeout)
+			if _, err := w.Write(out.Bytes()); err != nil {
+				// Keep draining the channel so the endpoint goroutine is not blocked
+				slog.Debug("Error writing SSE event", "endpoint", endpointName, "error", err)
+				p.metrics.eventsDropped.inc(endpointName)
+				eventSpan.SetStatus(trace.StatusError, "write failed")
+				eventSpan.End()
+				continue
 			}
-		})
+			flusher.Flush()
+			p.metrics.eventsSent.inc(endpointName)
+			eventSpan.End()
+		}
 	}
-	p.mu.RUnlock()
-
-	// Start server with timeouts
-	addr := ":" + p.config.Port
-	slog.Info("Starting server", "port", p.config.Port)
+}
 
-	server := &http.Server{
-		Addr:              addr,
-		Handler:           mux,
-		ReadTimeout:       10 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		IdleTimeout:       120 * time.Second,
-		ReadHeaderTimeout: 5 * time.Second,
+// extendWriteDeadline allows timeout for the next write. Writers that do not support
+// deadlines, such as test recorders, are left alone.
+func extendWriteDeadline(w http.ResponseWriter, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.Debug("Error extending write deadline", "error", err)
 	}
+}
 
-	return server.ListenAndServe()
+// writeSSEError sends a JSON:API error as an SSE "error" event
+func writeSSEError(w http.ResponseWriter, flusher http.Flusher, status int, title, detail string) {
+	data, err := json.Marshal(ErrorResponse{
+		Errors: []Error{{Status: fmt.Sprintf("%d", status), Title: title, Detail: detail}},
+	})
+	if err != nil {
+		slog.Error("Error encoding SSE error event", "error", err)
+		return
+	}
+	if _, err := fmt.Fprintf(w, "event: error\ndata: %s\n\n", data); err != nil {
+		slog.Debug("Error writing SSE error event", "error", err)
+		return
+	}
+	flusher.Flush()
 }
 
 // getLogLevel converts a string log level to a slog.Level
@@ -1,13 +1,17 @@
 package hub
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 // MockEndpoint is a mock implementation of the Endpoint interface for testing
@@ -35,8 +39,10 @@ func (m *MockEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
 		fmt.Sscanf(maxCountStr, "%d", &maxCount)
 	}
 
-	// Set REST headers
-	w.Header().Set("Content-Type", "application/json")
+	// Set REST headers; the hub owns the headers of a stream
+	if !m.isSSE {
+		w.Header().Set("Content-Type", "application/json")
+	}
 
 	// In a real endpoint, we would loop up to maxCount
 	// but for the mock, we just send the data once
@@ -58,57 +64,8 @@ func TestPlatform_REST(t *testing.T) {
 	// Register the endpoint
 	platform.RegisterEndpoint("test", mockEndpoint)
 
-	// Create a test server
-	mux := http.NewServeMux()
-	// Register the endpoint with the platform's handler
-	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
-		// Set max_count=1 for REST requests
-		q := r.URL.Query()
-		q.Set("max_count", "1")
-		r.URL.RawQuery = q.Encode()
-
-		// Create a response recorder to capture the endpoint's response
-		rr := &responseRecorder{
-			header: make(http.Header),
-			body:   new(strings.Builder),
-			code:   http.StatusOK,
-		}
-		mockEndpoint.HandleSSE(rr, r)
-
-		// Copy the headers from the recorder to the response writer
-		for k, v := range rr.Header() {
-			w.Header()[k] = v
-		}
-
-		// Set the content type to application/json for REST
-		w.Header().Set("Content-Type", "application/json")
-
-		// Check if the response is an error
-		if rr.code != http.StatusOK {
-			w.WriteHeader(rr.code)
-			w.Write(rr.BodyBytes())
-			return
-		}
-
-		// Parse the response body
-		var responseData interface{}
-		if err := json.Unmarshal(rr.BodyBytes(), &responseData); err != nil {
-			// If the response is not valid JSON, wrap it as a string
-			responseData = rr.BodyString()
-		}
-
-		// Wrap the response in a data field
-		wrappedResponse := DataResponse{
-			Data: responseData,
-		}
-
-		// Encode the wrapped response
-		if err := json.NewEncoder(w).Encode(wrappedResponse); err != nil {
-			t.Fatalf("Error encoding response: %v", err)
-			return
-		}
-	})
-	server := httptest.NewServer(mux)
+	// Serve the platform's handler
+	server := httptest.NewServer(platform.Handler())
 	defer server.Close()
 
 	// Make a request to the endpoint
@@ -122,6 +79,9 @@ func TestPlatform_REST(t *testing.T) {
 	if resp.StatusCode != http.StatusOK {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
 	}
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected Content-Type %q, got %q", "application/json", resp.Header.Get("Content-Type"))
+	}
 
 	// Decode the response
 	var data map[string]interface{}
@@ -158,70 +118,12 @@ func TestPlatform_SSE(t *testing.T) {
 	// Register the endpoint
 	platform.RegisterEndpoint("test", mockEndpoint)
 
-	// Create a test server
-	mux := http.NewServeMux()
-	mux.HandleFunc("/test/stream", func(w http.ResponseWriter, r *http.Request) {
-		// Add max_count=1 to ensure the test completes
-		q := r.URL.Query()
-		q.Set("max_count", "1")
-		r.URL.RawQuery = q.Encode()
-
-		// Set SSE headers
-		w.Header().Set("Content-Type", "text/event-stream")
-		w.Header().Set("Cache-Control", "no-cache")
-		w.Header().Set("Connection", "keep-alive")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-
-		// Check if streaming is supported
-		flusher, ok := w.(http.Flusher)
-		if !ok {
-			t.Fatal("Streaming not supported")
-			return
-		}
-
-		// Create a response recorder to capture the endpoint's response
-		rr := &responseRecorder{
-			header: make(http.Header),
-			body:   new(strings.Builder),
-			code:   http.StatusOK,
-		}
-		mockEndpoint.HandleSSE(rr, r)
-
-		// Check if the response is an error
-		if rr.code != http.StatusOK {
-			w.WriteHeader(rr.code)
-			w.Write(rr.BodyBytes())
-			return
-		}
-
-		// Parse the response body
-		var responseData interface{}
-		if err := json.Unmarshal(rr.BodyBytes(), &responseData); err != nil {
-			// If the response is not valid JSON, wrap it as a string
-			responseData = rr.BodyString()
-		}
-
-		// Wrap the response in a data field
-		wrappedResponse := DataResponse{
-			Data: responseData,
-		}
-
-		// Encode the wrapped response
-		responseJSON, err := json.Marshal(wrappedResponse)
-		if err != nil {
-			t.Fatalf("Error encoding SSE response: %v", err)
-			return
-		}
-
-		// Send the response
-		fmt.Fprintf(w, "data: %s\n\n", responseJSON)
-		flusher.Flush()
-	})
-	server := httptest.NewServer(mux)
+	// Serve the platform's handler
+	server := httptest.NewServer(platform.Handler())
 	defer server.Close()
 
-	// Make a request to the SSE endpoint
-	resp, err := http.Get(server.URL + "/test/stream")
+	// Make a request to the SSE endpoint; the mock sends one event and ends the stream
+	resp, err := http.Get(server.URL + "/test/stream?max_count=1")
 	if err != nil {
 		t.Fatalf("Error making request: %v", err)
 	}
@@ -236,34 +138,30 @@ func TestPlatform_SSE(t *testing.T) {
 		t.Errorf("Expected Cache-Control %q, got %q", "no-cache", resp.Header.Get("Cache-Control"))
 	}
 
+	// CORS headers are only sent when an origin is allowed
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no Access-Control-Allow-Origin by default, got %q", got)
+	}
+
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		t.Fatalf("Error reading response body: %v", err)
 	}
 
-	// Check that the response body contains the expected data
+	// Check that the response body contains exactly one event
 	responseStr := string(body)
-	if !strings.Contains(responseStr, "data:") {
-		t.Errorf("Expected response to contain 'data:', got %q", responseStr)
+	if strings.Count(responseStr, "data: ") != 1 || !strings.HasSuffix(responseStr, "\n\n") {
+		t.Fatalf("Expected a single SSE event, got %q", responseStr)
 	}
 
-	// Extract the JSON from the SSE event
-	lines := strings.Split(responseStr, "\n")
-	if len(lines) < 2 {
-		t.Fatalf("Response body does not contain enough lines: %v", responseStr)
-	}
-
-	dataLine := lines[0]
+	dataLine := strings.Split(responseStr, "\n")[0]
 	if !strings.HasPrefix(dataLine, "data: ") {
 		t.Fatalf("SSE event does not start with 'data: ': %v", dataLine)
 	}
 
 	jsonStr := strings.TrimPrefix(dataLine, "data: ")
 
-	// Print the jsonStr for debugging
-	t.Logf("jsonStr: %s", jsonStr)
-
 	// Parse the JSON
 	var response map[string]interface{}
 	if err := json.Unmarshal([]byte(jsonStr), &response); err != nil {
@@ -300,162 +198,114 @@ func TestPlatform_MultipleEndpoints(t *testing.T) {
 	platform.RegisterEndpoint("endpoint1", endpoint1)
 	platform.RegisterEndpoint("endpoint2", endpoint2)
 
-	// Create a test server
-	mux := http.NewServeMux()
-
-	// Register endpoint1 with the platform's handler
-	mux.HandleFunc("/endpoint1", func(w http.ResponseWriter, r *http.Request) {
-		// Set max_count=1 for REST requests
-		q := r.URL.Query()
-		q.Set("max_count", "1")
-		r.URL.RawQuery = q.Encode()
-
-		// Create a response recorder to capture the endpoint's response
-		rr := &responseRecorder{
-			header: make(http.Header),
-			body:   new(strings.Builder),
-			code:   http.StatusOK,
-		}
-		endpoint1.HandleSSE(rr, r)
-
-		// Copy the headers from the recorder to the response writer
-		for k, v := range rr.Header() {
-			w.Header()[k] = v
-		}
-
-		// Set the content type to application/json for REST
-		w.Header().Set("Content-Type", "application/json")
-
-		// Check if the response is an error
-		if rr.code != http.StatusOK {
-			w.WriteHeader(rr.code)
-			w.Write(rr.BodyBytes())
-			return
-		}
-
-		// Parse the response body
-		var responseData interface{}
-		if err := json.Unmarshal(rr.BodyBytes(), &responseData); err != nil {
-			// If the response is not valid JSON, wrap it as a string
-			responseData = rr.BodyString()
-		}
+	// Serve the platform's handler
+	server := httptest.NewServer(platform.Handler())
+	defer server.Close()
 
-		// Wrap the response in a data field
-		wrappedResponse := DataResponse{
-			Data: responseData,
+	for _, name := range []string{"endpoint1", "endpoint2"} {
+		resp, err := http.Get(server.URL + "/" + name)
+		if err != nil {
+			t.Fatalf("Error making request to %s: %v", name, err)
 		}
 
-		// Encode the wrapped response
-		if err := json.NewEncoder(w).Encode(wrappedResponse); err != nil {
-			t.Fatalf("Error encoding response: %v", err)
-			return
-		}
-	})
-
-	// Register endpoint2 with the platform's handler
-	mux.HandleFunc("/endpoint2", func(w http.ResponseWriter, r *http.Request) {
-		// Set max_count=1 for REST requests
-		q := r.URL.Query()
-		q.Set("max_count", "1")
-		r.URL.RawQuery = q.Encode()
-
-		// Create a response recorder to capture the endpoint's response
-		rr := &responseRecorder{
-			header: make(http.Header),
-			body:   new(strings.Builder),
-			code:   http.StatusOK,
+		var response map[string]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Error decoding response from %s: %v", name, err)
 		}
-		endpoint2.HandleSSE(rr, r)
 
-		// Copy the headers from the recorder to the response writer
-		for k, v := range rr.Header() {
-			w.Header()[k] = v
+		// Check that the response contains the data field
+		dataJSON, ok := response["data"]
+		if !ok {
+			t.Fatalf("Response from %s does not contain 'data' field", name)
 		}
 
-		// Set the content type to application/json for REST
-		w.Header().Set("Content-Type", "application/json")
-
-		// Check if the response is an error
-		if rr.code != http.StatusOK {
-			w.WriteHeader(rr.code)
-			w.Write(rr.BodyBytes())
-			return
+		// Check the data
+		dataMap, ok := dataJSON.(map[string]interface{})
+		if !ok {
+			t.Fatalf("Data from %s is not a map: %v", name, dataJSON)
 		}
 
-		// Parse the response body
-		var responseData interface{}
-		if err := json.Unmarshal(rr.BodyBytes(), &responseData); err != nil {
-			// If the response is not valid JSON, wrap it as a string
-			responseData = rr.BodyString()
+		if dataMap["endpoint"] != name {
+			t.Errorf("Expected endpoint %q, got %q", name, dataMap["endpoint"])
 		}
+	}
+}
 
-		// Wrap the response in a data field
-		wrappedResponse := DataResponse{
-			Data: responseData,
-		}
+func TestPlatform_UnknownEndpoint(t *testing.T) {
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("test", NewMockEndpoint([]byte(`"ok"`)))
 
-		// Encode the wrapped response
-		if err := json.NewEncoder(w).Encode(wrappedResponse); err != nil {
-			t.Fatalf("Error encoding response: %v", err)
-			return
-		}
-	})
-	server := httptest.NewServer(mux)
+	server := httptest.NewServer(platform.Handler())
 	defer server.Close()
 
-	// Test endpoint1
-	resp1, err := http.Get(server.URL + "/endpoint1")
+	resp, err := http.Get(server.URL + "/missing")
 	if err != nil {
-		t.Fatalf("Error making request to endpoint1: %v", err)
-	}
-	defer resp1.Body.Close()
-
-	var response1 map[string]interface{}
-	if err := json.NewDecoder(resp1.Body).Decode(&response1); err != nil {
-		t.Fatalf("Error decoding response from endpoint1: %v", err)
+		t.Fatalf("Error making request: %v", err)
 	}
-
-	// Check that the response contains the data field
-	dataJSON1, ok := response1["data"]
-	if !ok {
-		t.Fatal("Response from endpoint1 does not contain 'data' field")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, resp.StatusCode)
 	}
+}
 
-	// Check the data
-	dataMap1, ok := dataJSON1.(map[string]interface{})
-	if !ok {
-		t.Fatalf("Data from endpoint1 is not a map: %v", dataJSON1)
-	}
+func TestServe_StreamOutlivesWriteTimeout(t *testing.T) {
+	config := DefaultConfig()
+	config.LogLevel = "error"
+	platform := New(config)
+	platform.RegisterEndpoint("ticks", tickingEndpoint{})
+	platform.writeTimeout = 100 * time.Millisecond
+	captureLogs(t)
 
-	if dataMap1["endpoint"] != "endpoint1" {
-		t.Errorf("Expected endpoint %q, got %q", "endpoint1", dataMap1["endpoint"])
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
 	}
+	served := make(chan error, 1)
+	go func() { served <- platform.Serve(listener) }()
+	defer func() {
+		platform.Shutdown(context.Background())
+		if err := <-served; err != nil {
+			t.Errorf("Error serving: %v", err)
+		}
+	}()
 
-	// Test endpoint2
-	resp2, err := http.Get(server.URL + "/endpoint2")
+	resp, err := http.Get("http://" + listener.Addr().String() + "/ticks/stream")
 	if err != nil {
-		t.Fatalf("Error making request to endpoint2: %v", err)
+		t.Fatalf("Error making request: %v", err)
 	}
-	defer resp2.Body.Close()
+	defer resp.Body.Close()
 
-	var response2 map[string]interface{}
-	if err := json.NewDecoder(resp2.Body).Decode(&response2); err != nil {
-		t.Fatalf("Error decoding response from endpoint2: %v", err)
+	// Events keep coming well past the write timeout, which each event moves forward
+	deadline := time.Now().Add(500 * time.Millisecond)
+	events := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for time.Now().Before(deadline) && scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			events++
+		}
 	}
-
-	// Check that the response contains the data field
-	dataJSON2, ok := response2["data"]
-	if !ok {
-		t.Fatal("Response from endpoint2 does not contain 'data' field")
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Stream ended after %d events: %v", events, err)
 	}
-
-	// Check the data
-	dataMap2, ok := dataJSON2.(map[string]interface{})
-	if !ok {
-		t.Fatalf("Data from endpoint2 is not a map: %v", dataJSON2)
+	if events < 10 {
+		t.Errorf("Expected the stream to go on, got %d events", events)
 	}
+}
 
-	if dataMap2["endpoint"] != "endpoint2" {
-		t.Errorf("Expected endpoint %q, got %q", "endpoint2", dataMap2["endpoint"])
+func TestServe_InvalidConfig(t *testing.T) {
+	captureLogs(t)
+	config := DefaultConfig()
+	config.Port = ""
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	if err := New(config).Serve(listener); err == nil || !strings.Contains(err.Error(), "invalid configuration") {
+		t.Errorf("Expected the configuration to be rejected, got %v", err)
+	}
+	if _, err := listener.Accept(); err == nil {
+		t.Error("Expected the listener to be closed")
 	}
 }
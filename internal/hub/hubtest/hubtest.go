@@ -0,0 +1,370 @@
+// Package hubtest runs endpoints behind a real hub on an httptest.Server, so that endpoint
+// packages can test their REST and SSE behavior end to end, including the hub's envelopes,
+// errors and middleware.
+package hubtest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"trading/internal/hub"
+)
+
+// streamTimeout bounds how long Next waits for an event before failing the test
+const streamTimeout = 5 * time.Second
+
+// Option configures the hub behind a Server
+type Option func(*options)
+
+// options holds the settings collected from Options
+type options struct {
+	config    hub.Config
+	endpoints []endpoint
+	setup     []func(*hub.Hub)
+}
+
+// endpoint is an endpoint to register with its options
+type endpoint struct {
+	name     string
+	endpoint hub.Endpoint
+	opts     []hub.EndpointOption
+}
+
+// WithConfig runs the hub with config instead of hub.DefaultConfig()
+func WithConfig(config hub.Config) Option {
+	return func(o *options) {
+		o.config = config
+	}
+}
+
+// WithEndpoint registers an endpoint under name
+func WithEndpoint(name string, e hub.Endpoint, opts ...hub.EndpointOption) Option {
+	return func(o *options) {
+		o.endpoints = append(o.endpoints, endpoint{name: name, endpoint: e, opts: opts})
+	}
+}
+
+// WithAuthenticator makes the hub require credentials, see hub.Hub.SetAuthenticator
+func WithAuthenticator(authenticator hub.Authenticator) Option {
+	return WithSetup(func(h *hub.Hub) {
+		h.SetAuthenticator(authenticator)
+	})
+}
+
+// WithSetup calls fn on the hub after the endpoints are registered and before it serves
+func WithSetup(fn func(*hub.Hub)) Option {
+	return func(o *options) {
+		o.setup = append(o.setup, fn)
+	}
+}
+
+// Server is a hub served by an httptest.Server. It is closed when the test ends.
+type Server struct {
+	*httptest.Server
+	Hub *hub.Hub
+	t   testing.TB
+}
+
+// NewServer starts a hub with the given endpoints and settings. Endpoints implementing
+// hub.Starter are started first, and the test fails if one cannot start.
+func NewServer(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+	o := options{config: hub.DefaultConfig()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	h := hub.New(o.config)
+	for _, e := range o.endpoints {
+		h.RegisterEndpoint(e.name, e.endpoint, e.opts...)
+	}
+	for _, fn := range o.setup {
+		fn(h)
+	}
+
+	// Endpoints are started as Serve would, and stopped once the server is closed
+	if err := h.StartEndpoints(context.Background()); err != nil {
+		t.Fatalf("hubtest: %v", err)
+	}
+
+	s := &Server{Server: httptest.NewServer(h.Handler()), Hub: h, t: t}
+	t.Cleanup(func() {
+		// Dropping the connections ends open streams, so Close does not wait for them
+		s.CloseClientConnections()
+		s.Close()
+		if err := h.StopEndpoints(context.Background()); err != nil {
+			t.Errorf("hubtest: %v", err)
+		}
+	})
+	return s
+}
+
+// Response represents a complete REST response
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// NewRequest creates a request to path on the server, failing the test on error
+func (s *Server) NewRequest(method, path string, body io.Reader) *http.Request {
+	s.t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, body)
+	if err != nil {
+		s.t.Fatalf("hubtest: creating request: %v", err)
+	}
+	return req
+}
+
+// Do sends req and reads the whole response, failing the test on a transport error
+func (s *Server) Do(req *http.Request) *Response {
+	s.t.Helper()
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		s.t.Fatalf("hubtest: %s %s: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatalf("hubtest: reading response of %s %s: %v", req.Method, req.URL.Path, err)
+	}
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}
+}
+
+// Get sends a GET request to path
+func (s *Server) Get(path string) *Response {
+	s.t.Helper()
+	return s.Do(s.NewRequest(http.MethodGet, path, nil))
+}
+
+// DecodeData checks that resp is a 200 JSON:API data envelope and decodes its data into T
+func DecodeData[T any](t testing.TB, resp *Response) T {
+	t.Helper()
+	var data T
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("hubtest: expected status %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("hubtest: expected Content-Type application/json, got %q", ct)
+	}
+	raw := decodeEnvelope(t, resp.Body)
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatalf("hubtest: decoding data %s: %v", raw, err)
+	}
+	return data
+}
+
+// AssertError checks that resp is a JSON:API error response with status and returns the error
+func AssertError(t testing.TB, resp *Response, status int) hub.Error {
+	t.Helper()
+	if resp.StatusCode != status {
+		t.Fatalf("hubtest: expected status %d, got %d: %s", status, resp.StatusCode, resp.Body)
+	}
+	return decodeError(t, resp.Body, status)
+}
+
+// decodeEnvelope returns the data of a {"data": ...} envelope, failing on any other shape
+func decodeEnvelope(t testing.TB, body []byte) json.RawMessage {
+	t.Helper()
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&envelope); err != nil || envelope.Data == nil {
+		t.Fatalf("hubtest: expected a data envelope, got %s", body)
+	}
+	return envelope.Data
+}
+
+// decodeError returns the single error of a JSON:API error body and checks its status
+func decodeError(t testing.TB, body []byte, status int) hub.Error {
+	t.Helper()
+	var response hub.ErrorResponse
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&response); err != nil || len(response.Errors) != 1 {
+		t.Fatalf("hubtest: expected a JSON:API error, got %s", body)
+	}
+	e := response.Errors[0]
+	if e.Status != strconv.Itoa(status) || e.Title == "" || e.Detail == "" {
+		t.Fatalf("hubtest: expected a complete error with status %d, got %+v", status, e)
+	}
+	return e
+}
+
+// Event represents one Server-Sent Event
+type Event struct {
+	Name string // "" for data events, "error" for errors
+	ID   string
+	Data []byte
+}
+
+// Stream is an open SSE stream. It is closed when the test ends.
+type Stream struct {
+	Response *http.Response
+	t        testing.TB
+	ctx      context.Context
+	cancel   context.CancelFunc
+	events   chan Event
+	err      error // Set before events is closed
+}
+
+// Stream opens the SSE stream at path and checks that the hub accepted it
+func (s *Server) Stream(path string) *Stream {
+	s.t.Helper()
+	return s.StreamRequest(s.NewRequest(http.MethodGet, path, nil))
+}
+
+// StreamRequest opens an SSE stream with req and checks that the hub accepted it
+func (s *Server) StreamRequest(req *http.Request) *Stream {
+	s.t.Helper()
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := s.Client().Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		s.t.Fatalf("hubtest: opening stream %s: %v", req.URL.Path, err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		cancel()
+		s.t.Fatalf("hubtest: expected a stream from %s, got %d %s: %s", req.URL.Path, resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+
+	stream := &Stream{Response: resp, t: s.t, ctx: ctx, cancel: cancel, events: make(chan Event)}
+	go stream.read()
+	s.t.Cleanup(stream.Close)
+	return stream
+}
+
+// read parses events from the response body until it ends
+func (st *Stream) read() {
+	defer close(st.events)
+	defer st.Response.Body.Close()
+
+	var event Event
+	var data [][]byte
+	scanner := bufio.NewScanner(st.Response.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data != nil {
+				event.Data = bytes.Join(data, []byte("\n"))
+				select {
+				case st.events <- event:
+				case <-st.ctx.Done():
+					return
+				}
+			}
+			event, data = Event{}, nil
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Name = value
+		case "id":
+			event.ID = value
+		case "data":
+			data = append(data, []byte(value))
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, context.Canceled) {
+		st.err = err
+	}
+}
+
+// Next returns the next event, or false when the stream has ended. It fails the test if
+// no event arrives within a few seconds.
+func (st *Stream) Next() (Event, bool) {
+	st.t.Helper()
+	select {
+	case event, ok := <-st.events:
+		if !ok && st.err != nil {
+			st.t.Fatalf("hubtest: reading stream: %v", st.err)
+		}
+		return event, ok
+	case <-time.After(streamTimeout):
+		st.t.Fatalf("hubtest: no event within %s", streamTimeout)
+		return Event{}, false
+	}
+}
+
+// Close ends the stream
+func (st *Stream) Close() {
+	st.cancel()
+}
+
+// NextData reads the next event, checks that it is a data envelope and decodes its data into T
+func NextData[T any](t testing.TB, st *Stream) T {
+	t.Helper()
+	event, ok := st.Next()
+	if !ok {
+		t.Fatal("hubtest: the stream ended before the next event")
+	}
+	return decodeEventData[T](t, event)
+}
+
+// ReadAll reads the data of every event until the stream ends, failing on error events
+func ReadAll[T any](t testing.TB, st *Stream) []T {
+	t.Helper()
+	var all []T
+	for {
+		event, ok := st.Next()
+		if !ok {
+			return all
+		}
+		all = append(all, decodeEventData[T](t, event))
+	}
+}
+
+// decodeEventData checks that event is a data event and decodes the data of its envelope
+func decodeEventData[T any](t testing.TB, event Event) T {
+	t.Helper()
+	if event.Name != "" {
+		t.Fatalf("hubtest: expected a data event, got %q: %s", event.Name, event.Data)
+	}
+	var data T
+	raw := decodeEnvelope(t, event.Data)
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatalf("hubtest: decoding data %s: %v", raw, err)
+	}
+	return data
+}
+
+// AssertErrorEvent checks that event is an SSE error event with status and returns the error
+func AssertErrorEvent(t testing.TB, event Event, status int) hub.Error {
+	t.Helper()
+	if event.Name != "error" {
+		t.Fatalf("hubtest: expected an error event, got %q: %s", event.Name, event.Data)
+	}
+	return decodeError(t, event.Data, status)
+}
+
+// String returns the event in its wire format, for test failure messages
+func (e Event) String() string {
+	var b strings.Builder
+	if e.Name != "" {
+		fmt.Fprintf(&b, "event: %s\n", e.Name)
+	}
+	if e.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", e.ID)
+	}
+	fmt.Fprintf(&b, "data: %s\n", e.Data)
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package hubtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"trading/internal/hub"
+)
+
+// quote is the payload of counterEndpoint
+type quote struct {
+	Symbol string `json:"symbol"`
+	Seq    int    `json:"seq"`
+}
+
+// counterEndpoint sends max_count quotes, or a 400 for an unknown symbol
+type counterEndpoint struct{}
+
+// HandleSSE implements the hub.Endpoint interface
+func (counterEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		hub.WriteError(w, http.StatusBadRequest, "Bad Request", "symbol is required")
+		return
+	}
+	count, _ := strconv.Atoi(r.URL.Query().Get("max_count"))
+	for i := 1; i <= count; i++ {
+		fmt.Fprintf(w, `{"symbol":%q,"seq":%d}`, symbol, i)
+	}
+}
+
+// denyAll rejects every request
+type denyAll struct{}
+
+// Authenticate implements hub.Authenticator
+func (denyAll) Authenticate(r *http.Request) (*hub.Principal, error) {
+	return nil, hub.ErrNoCredentials
+}
+
+// Challenge implements hub.Authenticator
+func (denyAll) Challenge() string {
+	return "Test"
+}
+
+// feedEndpoint serves its symbol once started
+type feedEndpoint struct {
+	symbol  string
+	stopped bool
+}
+
+// Start implements hub.Starter
+func (e *feedEndpoint) Start(ctx context.Context) error {
+	e.symbol = "EURUSD"
+	return nil
+}
+
+// Stop implements hub.Stopper
+func (e *feedEndpoint) Stop(ctx context.Context) error {
+	e.stopped = true
+	return nil
+}
+
+// HandleSSE implements the hub.Endpoint interface
+func (e *feedEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, `{"symbol":%q}`, e.symbol)
+}
+
+func TestServer_REST(t *testing.T) {
+	server := NewServer(t, WithEndpoint("quotes", counterEndpoint{}))
+
+	got := DecodeData[quote](t, server.Get("/quotes?symbol=EURUSD"))
+	if got != (quote{Symbol: "EURUSD", Seq: 1}) {
+		t.Errorf("Unexpected quote %+v", got)
+	}
+
+	e := AssertError(t, server.Get("/quotes"), http.StatusBadRequest)
+	if e.Detail != "symbol is required" {
+		t.Errorf("Unexpected error %+v", e)
+	}
+}
+
+func TestServer_SSE(t *testing.T) {
+	server := NewServer(t, WithEndpoint("quotes", counterEndpoint{}))
+
+	stream := server.Stream("/quotes/stream?symbol=EURUSD&max_count=3")
+	first := NextData[quote](t, stream)
+	if first.Seq != 1 {
+		t.Errorf("Expected the first quote, got %+v", first)
+	}
+	rest := ReadAll[quote](t, stream)
+	if len(rest) != 2 || rest[1].Seq != 3 {
+		t.Errorf("Expected the remaining quotes, got %+v", rest)
+	}
+	if _, ok := stream.Next(); ok {
+		t.Error("Expected the stream to have ended")
+	}
+}
+
+func TestServer_Middleware(t *testing.T) {
+	server := NewServer(t,
+		WithEndpoint("quotes", counterEndpoint{}),
+		WithAuthenticator(denyAll{}),
+	)
+
+	resp := server.Get("/quotes?symbol=EURUSD")
+	AssertError(t, resp, http.StatusUnauthorized)
+	if resp.Header.Get("WWW-Authenticate") != "Test" {
+		t.Errorf("Expected a challenge, got %q", resp.Header.Get("WWW-Authenticate"))
+	}
+}
+
+func TestServer_Config(t *testing.T) {
+	config := hub.DefaultConfig()
+	config.Hardening.MaxQueryParams = 1
+	server := NewServer(t, WithConfig(config), WithEndpoint("quotes", counterEndpoint{}))
+
+	AssertError(t, server.Get("/quotes?symbol=EURUSD&x=1"), http.StatusBadRequest)
+}
+
+func TestAssertErrorEvent(t *testing.T) {
+	event := Event{Name: "error", Data: []byte(`{"errors":[{"status":"401","title":"Unauthorized","detail":"the credentials expired"}]}`)}
+	if e := AssertErrorEvent(t, event, http.StatusUnauthorized); e.Detail != "the credentials expired" {
+		t.Errorf("Unexpected error %+v", e)
+	}
+}
+
+func TestServer_Lifecycle(t *testing.T) {
+	feed := &feedEndpoint{}
+	t.Run("serve", func(t *testing.T) {
+		server := NewServer(t, WithEndpoint("feed", feed))
+		if got := DecodeData[quote](t, server.Get("/feed")); got.Symbol != "EURUSD" {
+			t.Errorf("Expected the endpoint to be started, got %+v", got)
+		}
+	})
+	if !feed.stopped {
+		t.Error("Expected the endpoint to be stopped when the test ends")
+	}
+}
@@ -0,0 +1,339 @@
+package hub
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HeaderIdempotencyKey is the request header that makes a mutating REST request safe to retry
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// headerIdempotentReplayed marks a response replayed from the idempotency store
+const headerIdempotentReplayed = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength bounds the keys kept in the store
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBodySize is the largest request body that can be fingerprinted
+const maxIdempotentBodySize = 1 << 20
+
+// IdempotencyConfig represents how long responses to idempotent requests are kept
+type IdempotencyConfig struct {
+	TTL time.Duration `json:"ttl"` // Default: 24h; how long a response is replayed for retries
+}
+
+// validate checks the idempotency settings
+func (c IdempotencyConfig) validate() []error {
+	if c.TTL <= 0 {
+		return []error{fmt.Errorf("idempotency.ttl: %s must be positive", c.TTL)}
+	}
+	return nil
+}
+
+// IdempotencyRecord represents the first response to an idempotent request
+type IdempotencyRecord struct {
+	RequestHash string      `json:"request_hash"` // Fingerprint of the method, path, query and body
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// IdempotencyStore keeps the responses to idempotent requests. Keys already include the
+// client and the endpoint. Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Get returns the record stored for key, or false if there is none or it expired
+	Get(ctx context.Context, key string) (*IdempotencyRecord, bool, error)
+	// Put stores record for key until ttl has passed
+	Put(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+}
+
+// memoryIdempotencyEntry is a record with its expiry
+type memoryIdempotencyEntry struct {
+	record  *IdempotencyRecord
+	expires time.Time
+}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore. Records are lost on restart
+// and are not shared between hub instances.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryIdempotencyEntry
+	pruned  time.Time
+	now     func() time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty MemoryIdempotencyStore
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]memoryIdempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+// Get implements the IdempotencyStore interface
+func (s *MemoryIdempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || !s.now().Before(entry.expires) {
+		return nil, false, nil
+	}
+	return entry.record, true, nil
+}
+
+// Put implements the IdempotencyStore interface
+func (s *MemoryIdempotencyStore) Put(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.entries[key] = memoryIdempotencyEntry{record: record, expires: now.Add(ttl)}
+
+	// Drop expired records at most once a minute
+	if now.Sub(s.pruned) >= time.Minute {
+		s.pruned = now
+		for k, entry := range s.entries {
+			if !now.Before(entry.expires) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	return nil
+}
+
+// keyedLocks serializes work on the same key while letting different keys run concurrently
+type keyedLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+// keyedLock is the lock of one key and the number of goroutines holding or waiting for it
+type keyedLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// newKeyedLocks creates an empty keyedLocks
+func newKeyedLocks() *keyedLocks {
+	return &keyedLocks{locks: make(map[string]*keyedLock)}
+}
+
+// lock waits until key is free or ctx ends, and returns the function releasing it
+func (l *keyedLocks) lock(ctx context.Context, key string) (func(), error) {
+	l.mu.Lock()
+	entry, ok := l.locks[key]
+	if !ok {
+		entry = &keyedLock{ch: make(chan struct{}, 1)}
+		l.locks[key] = entry
+	}
+	entry.refs++
+	l.mu.Unlock()
+
+	release := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		entry.refs--
+		if entry.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+
+	select {
+	case entry.ch <- struct{}{}:
+		return func() {
+			<-entry.ch
+			release()
+		}, nil
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+}
+
+// SetIdempotencyStore replaces the store of idempotent responses. The hub starts with a
+// MemoryIdempotencyStore.
+func (p *Hub) SetIdempotencyStore(store IdempotencyStore) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idempotency = store
+}
+
+// getIdempotency returns the current store and settings
+func (p *Hub) getIdempotency() (IdempotencyStore, IdempotencyConfig) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.idempotency, p.config.Idempotency
+}
+
+// isSafeMethod reports whether a method does not change state and is never made idempotent
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// idempotent stores the first response to a mutating request carrying an Idempotency-Key
+// and replays it when the client retries. A retry with a different request gets 422, and
+// concurrent duplicates wait for the first to finish. Keys are scoped to the client and
+// the endpoint. An endpoint that missed the REST deadline may still take effect, so its
+// key stays locked until the endpoint returns and the 504 is then stored as the outcome.
+func (p *Hub) idempotent(endpointName string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderIdempotencyKey)
+		if key == "" || isSafeMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			WriteError(w, http.StatusBadRequest, "Bad Request",
+				fmt.Sprintf("the %s header is longer than %d bytes", HeaderIdempotencyKey, maxIdempotencyKeyLength))
+			return
+		}
+
+		hash, err := requestFingerprint(r)
+		if err != nil {
+			WriteError(w, http.StatusRequestEntityTooLarge, "Request Entity Too Large", err.Error())
+			return
+		}
+
+		store, config := p.getIdempotency()
+		storeKey := clientKey(r) + "\n" + endpointName + "\n" + key
+
+		unlock, err := p.idempotencyLocks.lock(r.Context(), storeKey)
+		if err != nil {
+			// The client gave up while a duplicate was running
+			return
+		}
+		handedOff := false
+		defer func() {
+			if !handedOff {
+				unlock()
+			}
+		}()
+
+		record, ok, err := store.Get(r.Context(), storeKey)
+		if err != nil {
+			slog.Error("Error reading idempotency store", "endpoint", endpointName, "error", err)
+			WriteError(w, http.StatusInternalServerError, "Internal Server Error", "the idempotency store is unavailable")
+			return
+		}
+		if ok {
+			if record.RequestHash != hash {
+				WriteError(w, http.StatusUnprocessableEntity, "Unprocessable Entity",
+					fmt.Sprintf("the %s was already used with a different request", HeaderIdempotencyKey))
+				return
+			}
+			slog.Debug("Replaying idempotent response", "endpoint", endpointName)
+			for k, v := range record.Header {
+				w.Header()[k] = v
+			}
+			w.Header().Set(headerIdempotentReplayed, "true")
+			w.WriteHeader(record.Status)
+			w.Write(record.Body)
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w, header: make(http.Header)}
+		completion := &restCompletion{}
+		next.ServeHTTP(rec, r.WithContext(contextWithRESTCompletion(r.Context(), completion)))
+		if rec.status == 0 {
+			rec.WriteHeader(http.StatusOK)
+		}
+		record = &IdempotencyRecord{
+			RequestHash: hash,
+			Status:      rec.status,
+			Header:      rec.header,
+			Body:        rec.body.Bytes(),
+		}
+
+		// The endpoint is still running: duplicates wait for it, then get the 504, since
+		// running the request again could repeat its effect
+		if returned := completion.pending(); returned != nil {
+			handedOff = true
+			go func() {
+				defer unlock()
+				<-returned
+				if err := store.Put(context.Background(), storeKey, record, config.TTL); err != nil {
+					slog.Error("Error writing idempotency store", "endpoint", endpointName, "error", err)
+				}
+			}()
+			return
+		}
+
+		// Server errors and rate limits are not final, so a retry runs the request again
+		if record.Status >= http.StatusInternalServerError || record.Status == http.StatusTooManyRequests {
+			return
+		}
+		if err := store.Put(r.Context(), storeKey, record, config.TTL); err != nil {
+			slog.Error("Error writing idempotency store", "endpoint", endpointName, "error", err)
+		}
+	})
+}
+
+// requestFingerprint hashes the method, path, query and body of r, restoring the body for
+// the handler. The query is encoded sorted by key, so the order of parameters does not matter.
+func requestFingerprint(r *http.Request) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", r.Method, r.URL.EscapedPath(), r.URL.Query().Encode())
+
+	if r.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodySize+1))
+		if err != nil {
+			return "", fmt.Errorf("reading body: %w", err)
+		}
+		if len(body) > maxIdempotentBodySize {
+			return "", fmt.Errorf("the body of an idempotent request must not be larger than %d bytes", maxIdempotentBodySize)
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// idempotencyRecorder is an http.ResponseWriter that forwards the response and keeps a copy.
+// It has its own header map so that only the headers set by the handler are stored.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header returns the header map of the handler's response
+func (w *idempotencyRecorder) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader copies the handler's headers to the response and sends the status code
+func (w *idempotencyRecorder) WriteHeader(statusCode int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = statusCode
+	for k, v := range w.header {
+		w.ResponseWriter.Header()[k] = v
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write records an implicit 200 status and forwards the data
+func (w *idempotencyRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,291 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// orderEndpoint counts the orders it executes and answers with the order number
+type orderEndpoint struct {
+	executed atomic.Int32
+	gate     chan struct{} // When set, every order waits for it
+	status   int
+}
+
+// HandleSSE implements the Endpoint interface
+func (e *orderEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	if e.gate != nil {
+		<-e.gate
+	}
+	n := e.executed.Add(1)
+	if e.status != 0 {
+		w.WriteHeader(e.status)
+	}
+	w.Header().Set("X-Order", strconv.Itoa(int(n)))
+	io.Copy(io.Discard, r.Body)
+	w.Write([]byte(`{"order":` + strconv.Itoa(int(n)) + `}`))
+}
+
+// submitOrder posts body to /orders with an Idempotency-Key
+func submitOrder(handler http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestIdempotency_Replay(t *testing.T) {
+	platform := New(DefaultConfig())
+	endpoint := &orderEndpoint{}
+	platform.RegisterEndpoint("orders", endpoint)
+	handler := platform.Handler()
+
+	first := submitOrder(handler, "key-1", `{"qty":10}`)
+	retry := submitOrder(handler, "key-1", `{"qty":10}`)
+
+	if endpoint.executed.Load() != 1 {
+		t.Fatalf("Expected the order to execute once, got %d", endpoint.executed.Load())
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the retry to replay %d %s, got %d %s", first.Code, first.Body.String(), retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("X-Order") != "1" || retry.Header().Get(headerIdempotentReplayed) != "true" {
+		t.Errorf("Expected the stored headers to be replayed, got %v", retry.Header())
+	}
+	if first.Header().Get(headerIdempotentReplayed) != "" {
+		t.Error("Expected the first response not to be marked as replayed")
+	}
+
+	// A different body with the same key is rejected
+	rr := submitOrder(handler, "key-1", `{"qty":1000}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+
+	// Other keys, requests without a key and GET requests run every time
+	submitOrder(handler, "key-2", `{"qty":10}`)
+	submitOrder(handler, "", `{"qty":10}`)
+	submitOrder(handler, "", `{"qty":10}`)
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(HeaderIdempotencyKey, "key-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if endpoint.executed.Load() != 5 {
+		t.Errorf("Expected 5 executions, got %d", endpoint.executed.Load())
+	}
+}
+
+func TestIdempotency_Query(t *testing.T) {
+	platform := New(DefaultConfig())
+	endpoint := &orderEndpoint{}
+	platform.RegisterEndpoint("orders", endpoint)
+	handler := platform.Handler()
+
+	submit := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"qty":10}`))
+		req.Header.Set(HeaderIdempotencyKey, "key-1")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	submit("/orders?account=1&side=buy")
+	// The order of the parameters does not matter
+	if rr := submit("/orders?side=buy&account=1"); rr.Header().Get(headerIdempotentReplayed) != "true" {
+		t.Errorf("Expected the retry to be replayed, got %d %v", rr.Code, rr.Header())
+	}
+	// A different query with the same key is rejected
+	if rr := submit("/orders?account=2&side=buy"); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	if endpoint.executed.Load() != 1 {
+		t.Errorf("Expected the order to execute once, got %d", endpoint.executed.Load())
+	}
+}
+
+func TestIdempotency_ResponseController(t *testing.T) {
+	platform := New(DefaultConfig())
+	// The handler behind the middleware reaches the connection through the recorder
+	handler := platform.idempotent("deadline", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(time.Minute)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set(HeaderIdempotencyKey, "key-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error making request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Errorf("Expected the write deadline to be set, got %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestIdempotency_AbandonedCall(t *testing.T) {
+	captureLogs(t)
+	config := DefaultConfig()
+	config.REST.Timeout = 50 * time.Millisecond
+	platform := New(config)
+	endpoint := &orderEndpoint{gate: make(chan struct{})}
+	platform.RegisterEndpoint("orders", endpoint)
+	handler := platform.Handler()
+
+	// The endpoint misses the deadline but keeps running
+	if rr := submitOrder(handler, "key-1", `{"qty":10}`); rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected status code %d, got %d", http.StatusGatewayTimeout, rr.Code)
+	}
+
+	// A retry waits for it rather than running the order a second time
+	retried := make(chan *httptest.ResponseRecorder)
+	go func() { retried <- submitOrder(handler, "key-1", `{"qty":10}`) }()
+	select {
+	case rr := <-retried:
+		t.Fatalf("Expected the retry to wait for the running order, got %d", rr.Code)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(endpoint.gate)
+
+	rr := <-retried
+	if rr.Code != http.StatusGatewayTimeout || rr.Header().Get(headerIdempotentReplayed) != "true" {
+		t.Errorf("Expected the 504 to be replayed, got %d %v", rr.Code, rr.Header())
+	}
+	if endpoint.executed.Load() != 1 {
+		t.Errorf("Expected the order to execute once, got %d", endpoint.executed.Load())
+	}
+}
+
+func TestIdempotency_ScopedToClient(t *testing.T) {
+	platform := New(DefaultConfig())
+	endpoint := &orderEndpoint{}
+	platform.RegisterEndpoint("orders", endpoint)
+	handler := platform.Handler()
+
+	for _, addr := range []string{"10.0.0.1:1000", "10.0.0.2:1000"} {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`))
+		req.RemoteAddr = addr
+		req.Header.Set(HeaderIdempotencyKey, "same")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if endpoint.executed.Load() != 2 {
+		t.Errorf("Expected each client to have its own keys, got %d executions", endpoint.executed.Load())
+	}
+}
+
+func TestIdempotency_ServerErrorsNotStored(t *testing.T) {
+	platform := New(DefaultConfig())
+	endpoint := &orderEndpoint{status: http.StatusServiceUnavailable}
+	platform.RegisterEndpoint("orders", endpoint)
+	handler := platform.Handler()
+
+	submitOrder(handler, "key-1", `{}`)
+	submitOrder(handler, "key-1", `{}`)
+	if endpoint.executed.Load() != 2 {
+		t.Errorf("Expected a failed request to run again on retry, got %d executions", endpoint.executed.Load())
+	}
+}
+
+func TestIdempotency_ConcurrentDuplicates(t *testing.T) {
+	platform := New(DefaultConfig())
+	endpoint := &orderEndpoint{gate: make(chan struct{})}
+	platform.RegisterEndpoint("orders", endpoint)
+	handler := platform.Handler()
+
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, 5)
+	for i := range responses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = submitOrder(handler, "key-1", `{"qty":10}`)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(endpoint.gate)
+	wg.Wait()
+
+	if endpoint.executed.Load() != 1 {
+		t.Fatalf("Expected concurrent duplicates to execute once, got %d", endpoint.executed.Load())
+	}
+	for i, rr := range responses {
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"order":1`) {
+			t.Errorf("Response %d: expected the first order, got %d %s", i, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+// failingIdempotencyStore fails every operation
+type failingIdempotencyStore struct{}
+
+// Get implements the IdempotencyStore interface
+func (failingIdempotencyStore) Get(context.Context, string) (*IdempotencyRecord, bool, error) {
+	return nil, false, errors.New("store down")
+}
+
+// Put implements the IdempotencyStore interface
+func (failingIdempotencyStore) Put(context.Context, string, *IdempotencyRecord, time.Duration) error {
+	return errors.New("store down")
+}
+
+func TestIdempotency_StoreUnavailable(t *testing.T) {
+	platform := New(DefaultConfig())
+	endpoint := &orderEndpoint{}
+	platform.RegisterEndpoint("orders", endpoint)
+	platform.SetIdempotencyStore(failingIdempotencyStore{})
+	captureLogs(t)
+
+	rr := submitOrder(platform.Handler(), "key-1", `{}`)
+	if rr.Code != http.StatusInternalServerError || endpoint.executed.Load() != 0 {
+		t.Errorf("Expected 500 without executing the order, got %d and %d executions", rr.Code, endpoint.executed.Load())
+	}
+}
+
+func TestIdempotency_InvalidKey(t *testing.T) {
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("orders", &orderEndpoint{})
+
+	rr := submitOrder(platform.Handler(), strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestMemoryIdempotencyStore_Expiry(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	store := NewMemoryIdempotencyStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	store.Put(ctx, "a", &IdempotencyRecord{Status: http.StatusCreated}, time.Hour)
+	if record, ok, _ := store.Get(ctx, "a"); !ok || record.Status != http.StatusCreated {
+		t.Fatalf("Expected the stored record, got %v %v", record, ok)
+	}
+
+	now = now.Add(time.Hour)
+	if _, ok, _ := store.Get(ctx, "a"); ok {
+		t.Error("Expected the record to expire")
+	}
+	store.Put(ctx, "b", &IdempotencyRecord{}, time.Hour)
+	if len(store.entries) != 1 {
+		t.Errorf("Expected expired records to be pruned, got %d entries", len(store.entries))
+	}
+}
@@ -0,0 +1,162 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Starter is an optional interface an endpoint can implement to initialize itself, such as
+// opening files or connecting a feed, before the hub serves it. The context only bounds
+// the initialization; work that outlives Start should run until Stop.
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// Stopper is an optional interface an endpoint can implement to release what it holds,
+// such as stopping background feeds, once the hub has stopped serving it
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// LifecycleConfig represents how long endpoints have to start and stop
+type LifecycleConfig struct {
+	StartTimeout time.Duration `json:"start_timeout"` // Default: 30s; for each endpoint
+	StopTimeout  time.Duration `json:"stop_timeout"`  // Default: 10s; for each endpoint, within the shutdown deadline
+}
+
+// validate checks the lifecycle settings
+func (c LifecycleConfig) validate() []error {
+	var errs []error
+	if c.StartTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("lifecycle.start_timeout: %s must be positive", c.StartTimeout))
+	}
+	if c.StopTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("lifecycle.stop_timeout: %s must be positive", c.StopTimeout))
+	}
+	return errs
+}
+
+// namedEndpoint is an endpoint with its registered name
+type namedEndpoint struct {
+	name     string
+	endpoint Endpoint
+}
+
+// orderedEndpoints returns the served endpoints in registration order
+func (p *Hub) orderedEndpoints() []namedEndpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	endpoints := make([]namedEndpoint, 0, len(p.order))
+	for _, name := range p.order {
+		if isReservedName(name) {
+			continue
+		}
+		endpoints = append(endpoints, namedEndpoint{name: name, endpoint: p.endpoints[name]})
+	}
+	return endpoints
+}
+
+// StartEndpoints calls Start on the endpoints implementing Starter, in registration order,
+// each within lifecycle.start_timeout. Every endpoint is tried; if any fails, those already
+// started are stopped and the failures are returned together. Serve calls it before
+// accepting connections, so it is only needed to serve Handler some other way. Calling it
+// again before StopEndpoints does nothing.
+func (p *Hub) StartEndpoints(ctx context.Context) error {
+	p.lifecycleMu.Lock()
+	defer p.lifecycleMu.Unlock()
+	if p.started != nil {
+		return nil
+	}
+
+	timeout := p.getConfig().Lifecycle.StartTimeout
+	endpoints := p.orderedEndpoints()
+	started := make([]namedEndpoint, 0, len(endpoints))
+	var errs []error
+	for _, e := range endpoints {
+		if starter, ok := e.endpoint.(Starter); ok {
+			began := time.Now()
+			if err := runHook(ctx, timeout, starter.Start); err != nil {
+				slog.Error("Endpoint failed to start", "endpoint", e.name, "error", err)
+				errs = append(errs, fmt.Errorf("endpoint %q: starting: %w", e.name, err))
+				continue
+			}
+			slog.Info("Endpoint started", "endpoint", e.name, "duration", time.Since(began).String())
+		}
+		started = append(started, e)
+	}
+
+	if len(errs) > 0 {
+		// Release what the other endpoints hold, since the hub will not serve them
+		p.stopEndpoints(context.Background(), started)
+		return errors.Join(errs...)
+	}
+	p.started = started
+	return nil
+}
+
+// StopEndpoints calls Stop on the started endpoints implementing Stopper, in reverse
+// registration order, each within lifecycle.stop_timeout and ctx. Every endpoint is tried
+// and the failures are returned together. Shutdown calls it once the server has drained.
+func (p *Hub) StopEndpoints(ctx context.Context) error {
+	p.lifecycleMu.Lock()
+	defer p.lifecycleMu.Unlock()
+	started := p.started
+	p.started = nil
+	return p.stopEndpoints(ctx, started)
+}
+
+// stopEndpoints stops endpoints in reverse order
+func (p *Hub) stopEndpoints(ctx context.Context, endpoints []namedEndpoint) error {
+	timeout := p.getConfig().Lifecycle.StopTimeout
+	var errs []error
+	for i := len(endpoints) - 1; i >= 0; i-- {
+		e := endpoints[i]
+		stopper, ok := e.endpoint.(Stopper)
+		if !ok {
+			continue
+		}
+		if err := runHook(ctx, timeout, stopper.Stop); err != nil {
+			slog.Error("Endpoint failed to stop", "endpoint", e.name, "error", err)
+			errs = append(errs, fmt.Errorf("endpoint %q: stopping: %w", e.name, err))
+			continue
+		}
+		slog.Info("Endpoint stopped", "endpoint", e.name)
+	}
+	return errors.Join(errs...)
+}
+
+// runHook runs a Start or Stop method until it returns or timeout passes. A hook that
+// ignores its context is abandoned at the deadline, and a panic is returned as an error.
+func runHook(ctx context.Context, timeout time.Duration, hook func(context.Context) error) error {
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				done <- fmt.Errorf("panic: %v", v)
+			}
+		}()
+		done <- hook(hookCtx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-hookCtx.Done():
+		// The hook may have returned just as the deadline passed
+		select {
+		case err := <-done:
+			return err
+		default:
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("did not return within %s", timeout)
+	}
+}
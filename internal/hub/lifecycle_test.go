@@ -0,0 +1,281 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lifecycleLog records the Start and Stop calls of endpoints
+type lifecycleLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+// add records a call
+func (l *lifecycleLog) add(call string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, call)
+}
+
+// take returns the calls recorded so far and forgets them
+func (l *lifecycleLog) take() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	calls := strings.Join(l.calls, ",")
+	l.calls = nil
+	return calls
+}
+
+// hookFunc is the behavior of a Start or Stop method: return an error, block, or panic
+type hookFunc func(ctx context.Context) error
+
+// starterEndpoint records its Start calls and writes its name
+type starterEndpoint struct {
+	name  string
+	log   *lifecycleLog
+	start hookFunc
+}
+
+// HandleSSE implements the Endpoint interface
+func (e *starterEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`"` + e.name + `"`))
+}
+
+// Start implements Starter
+func (e *starterEndpoint) Start(ctx context.Context) error {
+	e.log.add("start " + e.name)
+	if e.start != nil {
+		return e.start(ctx)
+	}
+	return nil
+}
+
+// stopperEndpoint records its Stop calls
+type stopperEndpoint struct {
+	name string
+	log  *lifecycleLog
+	stop hookFunc
+}
+
+// HandleSSE implements the Endpoint interface
+func (e *stopperEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {}
+
+// Stop implements Stopper
+func (e *stopperEndpoint) Stop(ctx context.Context) error {
+	e.log.add("stop " + e.name)
+	if e.stop != nil {
+		return e.stop(ctx)
+	}
+	return nil
+}
+
+// lifecycleEndpoint records both its Start and Stop calls
+type lifecycleEndpoint struct {
+	starterEndpoint
+	stop hookFunc
+}
+
+// Stop implements Stopper
+func (e *lifecycleEndpoint) Stop(ctx context.Context) error {
+	e.log.add("stop " + e.name)
+	if e.stop != nil {
+		return e.stop(ctx)
+	}
+	return nil
+}
+
+// newLifecycleEndpoint creates a lifecycleEndpoint
+func newLifecycleEndpoint(name string, log *lifecycleLog, start, stop hookFunc) *lifecycleEndpoint {
+	return &lifecycleEndpoint{starterEndpoint: starterEndpoint{name: name, log: log, start: start}, stop: stop}
+}
+
+// blockForever is a hook that ignores its context
+func blockForever(context.Context) error {
+	select {}
+}
+
+func TestStartEndpoints_Order(t *testing.T) {
+	captureLogs(t)
+	log := &lifecycleLog{}
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("a", newLifecycleEndpoint("a", log, nil, nil))
+	platform.RegisterEndpoint("b", &starterEndpoint{name: "b", log: log})
+	platform.RegisterEndpoint("c", writesEndpoint{})
+	platform.RegisterEndpoint("d", &stopperEndpoint{name: "d", log: log})
+	platform.RegisterEndpoint("e", newLifecycleEndpoint("e", log, nil, nil))
+	// Registering a name again keeps its place
+	platform.RegisterEndpoint("a", newLifecycleEndpoint("a2", log, nil, nil))
+
+	if err := platform.StartEndpoints(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := log.take(); got != "start a2,start b,start e" {
+		t.Errorf("Expected registration order, got %q", got)
+	}
+	if err := platform.StartEndpoints(context.Background()); err != nil || log.take() != "" {
+		t.Errorf("Expected a second start to do nothing, got %v", err)
+	}
+
+	if err := platform.StopEndpoints(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := log.take(); got != "stop e,stop d,stop a2" {
+		t.Errorf("Expected reverse registration order, got %q", got)
+	}
+	if err := platform.StopEndpoints(context.Background()); err != nil || log.take() != "" {
+		t.Errorf("Expected a second stop to do nothing, got %v", err)
+	}
+}
+
+func TestStartEndpoints_Failure(t *testing.T) {
+	logs := captureLogs(t)
+	log := &lifecycleLog{}
+	config := DefaultConfig()
+	config.Lifecycle.StartTimeout = 50 * time.Millisecond
+	platform := New(config)
+	platform.RegisterEndpoint("a", newLifecycleEndpoint("a", log, nil, nil))
+	platform.RegisterEndpoint("b", newLifecycleEndpoint("b", log, func(context.Context) error { return errors.New("no feed") }, nil))
+	platform.RegisterEndpoint("c", newLifecycleEndpoint("c", log, blockForever, nil))
+	platform.RegisterEndpoint("d", newLifecycleEndpoint("d", log, func(context.Context) error { panic("bad file") }, nil))
+	platform.RegisterEndpoint("e", &stopperEndpoint{name: "e", log: log})
+
+	err := platform.StartEndpoints(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{
+		`endpoint "b": starting: no feed`,
+		`endpoint "c": starting: did not return within 50ms`,
+		`endpoint "d": starting: panic: bad file`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %q", want, err)
+		}
+	}
+
+	// Every endpoint was tried, and those that started were stopped again
+	if got := log.take(); got != "start a,start b,start c,start d,stop e,stop a" {
+		t.Errorf("Unexpected calls %q", got)
+	}
+	if !strings.Contains(logs.String(), "Endpoint failed to start") {
+		t.Errorf("Expected the failures to be logged, got %s", logs)
+	}
+
+	// Nothing is left to stop
+	if err := platform.StopEndpoints(context.Background()); err != nil || log.take() != "" {
+		t.Errorf("Expected nothing to stop, got %v", err)
+	}
+}
+
+func TestStopEndpoints_Failure(t *testing.T) {
+	captureLogs(t)
+	log := &lifecycleLog{}
+	config := DefaultConfig()
+	config.Lifecycle.StopTimeout = 50 * time.Millisecond
+	platform := New(config)
+	platform.RegisterEndpoint("a", newLifecycleEndpoint("a", log, nil, nil))
+	platform.RegisterEndpoint("b", newLifecycleEndpoint("b", log, nil, blockForever))
+	platform.RegisterEndpoint("c", newLifecycleEndpoint("c", log, nil, func(context.Context) error { return errors.New("flush failed") }))
+
+	if err := platform.StartEndpoints(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	log.take()
+
+	err := platform.StopEndpoints(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `endpoint "c": stopping: flush failed`) ||
+		!strings.Contains(err.Error(), `endpoint "b": stopping: did not return within 50ms`) {
+		t.Errorf("Expected both failures, got %v", err)
+	}
+	if got := log.take(); got != "stop c,stop b,stop a" {
+		t.Errorf("Expected every endpoint to be stopped, got %q", got)
+	}
+
+	// A canceled context is reported as such
+	if err := runHook(canceledContext(), time.Minute, blockForever); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// canceledContext returns a context that is already canceled
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func TestServe_Lifecycle(t *testing.T) {
+	log := &lifecycleLog{}
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("feed", newLifecycleEndpoint("feed", log, nil, nil))
+	captureLogs(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- platform.Serve(listener) }()
+
+	// The endpoint is started before the first request is served
+	resp, err := http.Get("http://" + listener.Addr().String() + "/feed")
+	if err != nil {
+		t.Fatalf("Error making request: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if got := log.take(); got != "start feed" {
+		t.Errorf("Expected the endpoint to be started, got %q", got)
+	}
+
+	if err := platform.Shutdown(context.Background()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Error serving: %v", err)
+	}
+	if got := log.take(); got != "stop feed" {
+		t.Errorf("Expected the endpoint to be stopped on shutdown, got %q", got)
+	}
+}
+
+func TestServe_StartFailure(t *testing.T) {
+	log := &lifecycleLog{}
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("feed", newLifecycleEndpoint("feed", log, func(context.Context) error { return errors.New("no feed") }, nil))
+	captureLogs(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	err = platform.Serve(listener)
+	if err == nil || !strings.Contains(err.Error(), `starting endpoints: endpoint "feed": starting: no feed`) {
+		t.Errorf("Expected the start failure, got %v", err)
+	}
+	if _, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		t.Error("Expected the listener to be closed")
+	}
+}
+
+func TestLifecycleConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	config.Lifecycle = LifecycleConfig{StartTimeout: -time.Second}
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{"lifecycle.start_timeout: -1s must be positive", "lifecycle.stop_timeout: 0s must be positive"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %q", want, err)
+		}
+	}
+}
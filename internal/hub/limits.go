@@ -0,0 +1,317 @@
+package hub
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// streamRetryAfter is the Retry-After sent when a stream quota is exhausted
+const streamRetryAfter = 5 * time.Second
+
+// bucketIdleTimeout is how long an unused, full token bucket is kept
+const bucketIdleTimeout = 10 * time.Minute
+
+// LimitsConfig represents the rate limits and stream quotas. Clients are identified by their
+// authenticated principal, else their verified client certificate, else their IP address.
+type LimitsConfig struct {
+	RequestsPerSecond  float64 `json:"requests_per_second"`  // Default: 0 (unlimited); REST requests per client
+	Burst              int     `json:"burst"`                // Default: 20; REST requests a client may send at once
+	StreamsPerClient   int     `json:"streams_per_client"`   // Default: 10; 0 for unlimited
+	StreamsPerEndpoint int     `json:"streams_per_endpoint"` // Default: 0 (unlimited); across all clients
+	MaxConnections     int     `json:"max_connections"`      // Default: 10000; 0 for unlimited
+	// AuthFailuresPerMinute bounds the failed authentications of a client address, which
+	// may each cost a bcrypt comparison. Default: 30, also the burst; 0 for unlimited.
+	AuthFailuresPerMinute int `json:"auth_failures_per_minute"`
+}
+
+// validate checks the limits
+func (c LimitsConfig) validate() []error {
+	var errs []error
+	if c.RequestsPerSecond < 0 || math.IsNaN(c.RequestsPerSecond) || math.IsInf(c.RequestsPerSecond, 0) {
+		errs = append(errs, fmt.Errorf("limits.requests_per_second: %v must be a non-negative number", c.RequestsPerSecond))
+	}
+	if c.RequestsPerSecond > 0 && c.Burst < 1 {
+		errs = append(errs, fmt.Errorf("limits.burst: %d must be at least 1 when requests_per_second is set", c.Burst))
+	}
+	for name, value := range map[string]int{
+		"streams_per_client":       c.StreamsPerClient,
+		"streams_per_endpoint":     c.StreamsPerEndpoint,
+		"max_connections":          c.MaxConnections,
+		"auth_failures_per_minute": c.AuthFailuresPerMinute,
+	} {
+		if value < 0 {
+			errs = append(errs, fmt.Errorf("limits.%s: %d must not be negative", name, value))
+		}
+	}
+	return errs
+}
+
+// tokenBucket holds the REST request allowance of one client
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter enforces the request rate and stream quotas
+type limiter struct {
+	mu              sync.Mutex
+	config          LimitsConfig
+	buckets         map[string]*tokenBucket
+	authFailures    map[string]*tokenBucket // Keyed by client address, see allowAuthAttempt
+	clientStreams   map[string]int
+	endpointStreams map[string]int
+	pruned          time.Time
+	now             func() time.Time
+}
+
+// newLimiter creates a limiter for config
+func newLimiter(config LimitsConfig) *limiter {
+	return &limiter{
+		config:          config,
+		buckets:         make(map[string]*tokenBucket),
+		authFailures:    make(map[string]*tokenBucket),
+		clientStreams:   make(map[string]int),
+		endpointStreams: make(map[string]int),
+		now:             time.Now,
+	}
+}
+
+// update applies new limits. Existing buckets keep their tokens; open streams are not ended.
+func (l *limiter) update(config LimitsConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config = config
+}
+
+// allowRequest takes a token from the client's bucket. When the bucket is empty it returns
+// false and how long until the next token.
+func (l *limiter) allowRequest(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rate, burst := l.config.RequestsPerSecond, float64(l.config.Burst)
+	if rate <= 0 {
+		return true, 0
+	}
+	now := l.now()
+	l.prune(now)
+
+	bucket := refill(l.buckets, client, rate, burst, now)
+	if bucket.tokens < 1 {
+		return false, bucket.wait(rate)
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// allowAuthAttempt reports whether the client may try to authenticate, or how long until
+// it may when it has used up its failures
+func (l *limiter) allowAuthAttempt(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	perMinute := l.config.AuthFailuresPerMinute
+	if perMinute <= 0 {
+		return true, 0
+	}
+	rate := float64(perMinute) / 60
+	now := l.now()
+	l.prune(now)
+	bucket := refill(l.authFailures, client, rate, float64(perMinute), now)
+	if bucket.tokens < 1 {
+		return false, bucket.wait(rate)
+	}
+	return true, 0
+}
+
+// chargeAuthFailure takes a token from the client's failure allowance
+func (l *limiter) chargeAuthFailure(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	perMinute := l.config.AuthFailuresPerMinute
+	if perMinute <= 0 {
+		return
+	}
+	bucket := refill(l.authFailures, client, float64(perMinute)/60, float64(perMinute), l.now())
+	bucket.tokens = math.Max(0, bucket.tokens-1)
+}
+
+// refill returns the client's bucket in buckets, created full, with the tokens gained since
+// it was last used
+func refill(buckets map[string]*tokenBucket, client string, rate, burst float64, now time.Time) *tokenBucket {
+	bucket, ok := buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+	return bucket
+}
+
+// wait returns how long until the bucket holds a whole token again
+func (b *tokenBucket) wait(rate float64) time.Duration {
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// prune drops buckets that have been idle long enough to be full again, at most once a minute
+func (l *limiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < time.Minute {
+		return
+	}
+	l.pruned = now
+	for _, buckets := range []map[string]*tokenBucket{l.buckets, l.authFailures} {
+		for client, bucket := range buckets {
+			if now.Sub(bucket.last) > bucketIdleTimeout {
+				delete(buckets, client)
+			}
+		}
+	}
+}
+
+// acquireStream reserves a stream slot for the client on the endpoint. On success it returns
+// a function releasing the slot; otherwise it returns the exhausted quota.
+func (l *limiter) acquireStream(client, endpoint string) (func(), string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if max := l.config.StreamsPerClient; max > 0 && l.clientStreams[client] >= max {
+		return nil, "client_streams"
+	}
+	if max := l.config.StreamsPerEndpoint; max > 0 && l.endpointStreams[endpoint] >= max {
+		return nil, "endpoint_streams"
+	}
+	l.clientStreams[client]++
+	l.endpointStreams[endpoint]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.clientStreams[client]--; l.clientStreams[client] <= 0 {
+				delete(l.clientStreams, client)
+			}
+			if l.endpointStreams[endpoint]--; l.endpointStreams[endpoint] <= 0 {
+				delete(l.endpointStreams, endpoint)
+			}
+		})
+	}, ""
+}
+
+// clientKey identifies the client of a request for rate limiting
+func clientKey(r *http.Request) string {
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		return "principal:" + principal.ID
+	}
+	if identity, ok := ClientIdentityFromContext(r.Context()); ok {
+		return "cert:" + identity.Fingerprint
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// limit applies the REST rate limit or the stream quotas before the endpoint is called
+func (p *Hub) limit(endpointName, transport string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := clientKey(r)
+
+		if transport == transportREST {
+			if ok, wait := p.limiter.allowRequest(client); !ok {
+				p.rejectLimited(w, endpointName, client, "rate", wait, "the request rate limit was exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		release, quota := p.limiter.acquireStream(client, endpointName)
+		if release == nil {
+			detail := "too many concurrent streams for this client"
+			if quota == "endpoint_streams" {
+				detail = "too many concurrent streams for this endpoint"
+			}
+			p.rejectLimited(w, endpointName, client, quota, streamRetryAfter, detail)
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rejectLimited answers 429 with a Retry-After header rounded up to whole seconds
+func (p *Hub) rejectLimited(w http.ResponseWriter, endpointName, client, reason string, wait time.Duration, detail string) {
+	slog.Warn("Request limited", "endpoint", endpointName, "client", client, "reason", reason)
+	p.metrics.limited.inc(endpointName, reason)
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	WriteError(w, http.StatusTooManyRequests, "Too Many Requests", detail)
+}
+
+// limitListener caps the number of concurrently open connections. When the cap is reached,
+// Accept waits for a connection to close, leaving new clients in the listen backlog.
+type limitListener struct {
+	net.Listener
+	slots     chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newLimitListener wraps ln so at most max connections are open at once
+func newLimitListener(ln net.Listener, max int) *limitListener {
+	return &limitListener{
+		Listener: ln,
+		slots:    make(chan struct{}, max),
+		done:     make(chan struct{}),
+	}
+}
+
+// Accept waits for a free slot, then accepts the next connection
+func (l *limitListener) Accept() (net.Conn, error) {
+	select {
+	case l.slots <- struct{}{}:
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		<-l.slots
+		return nil, err
+	}
+	return &limitConn{Conn: conn, release: func() { <-l.slots }}, nil
+}
+
+// Close stops accepting connections
+func (l *limitListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// limitConn frees its listener slot when closed
+type limitConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+// Close closes the connection and frees its slot
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
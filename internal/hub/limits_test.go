@@ -0,0 +1,309 @@
+package hub
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingEndpoint holds every request open until released
+type blockingEndpoint struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+// newBlockingEndpoint creates a blockingEndpoint
+func newBlockingEndpoint() *blockingEndpoint {
+	return &blockingEndpoint{started: make(chan struct{}, 16), release: make(chan struct{})}
+}
+
+// HandleSSE implements the Endpoint interface
+func (e *blockingEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	e.started <- struct{}{}
+	select {
+	case <-e.release:
+	case <-r.Context().Done():
+	}
+}
+
+func TestLimiter_TokenBucket(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newLimiter(LimitsConfig{RequestsPerSecond: 2, Burst: 3})
+	l.now = func() time.Time { return now }
+
+	// The burst is available at once
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allowRequest("ip:1"); !ok {
+			t.Fatalf("Expected request %d of the burst to be allowed", i)
+		}
+	}
+	ok, wait := l.allowRequest("ip:1")
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("Expected the empty bucket to refuse with a 500ms wait, got %v %v", ok, wait)
+	}
+
+	// Other clients have their own bucket
+	if ok, _ := l.allowRequest("ip:2"); !ok {
+		t.Error("Expected another client to be allowed")
+	}
+
+	// Tokens refill at the configured rate
+	now = now.Add(time.Second)
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allowRequest("ip:1"); !ok {
+			t.Fatalf("Expected refilled request %d to be allowed", i)
+		}
+	}
+	if ok, _ := l.allowRequest("ip:1"); ok {
+		t.Error("Expected the bucket to be empty again")
+	}
+
+	// Turning the limit off allows everything
+	l.update(LimitsConfig{})
+	if ok, _ := l.allowRequest("ip:1"); !ok {
+		t.Error("Expected requests to be allowed without a rate")
+	}
+}
+
+func TestRateLimit_REST(t *testing.T) {
+	config := DefaultConfig()
+	config.Limits.RequestsPerSecond = 0.5
+	config.Limits.Burst = 2
+	platform := New(config)
+	platform.RegisterEndpoint("test", NewMockEndpoint([]byte(`"ok"`)))
+	handler := platform.Handler()
+
+	codes := make([]int, 3)
+	var rr *httptest.ResponseRecorder
+	for i := range codes {
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/test", nil))
+		codes[i] = rr.Code
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("Expected 200, 200, 429, got %v", codes)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", got)
+	}
+	if response := decodeErrors(t, rr); len(response.Errors) != 1 || response.Errors[0].Status != "429" {
+		t.Errorf("Unexpected error response %+v", response)
+	}
+	if got := platform.metrics.limited.get("test", "rate"); got != 1 {
+		t.Errorf("Expected 1 limited request, got %v", got)
+	}
+}
+
+func TestRateLimit_AuthFailures(t *testing.T) {
+	captureLogs(t)
+	key, entry := testAPIKey(t, "desk1")
+	apiKeys, err := NewAPIKeyAuthenticator(APIKeyConfig{Header: "X-API-Key", Keys: []APIKey{entry}})
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+	config := DefaultConfig()
+	config.Limits.AuthFailuresPerMinute = 3
+	platform := New(config)
+	now := time.Unix(1_700_000_000, 0)
+	platform.limiter.now = func() time.Time { return now }
+	platform.RegisterEndpoint("whoami", principalEndpoint{})
+	platform.SetAuthenticator(apiKeys)
+	handler := platform.Handler()
+
+	call := func(remoteAddr, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		req.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Requests without credentials are cheap and not charged
+	for i := 0; i < 5; i++ {
+		if rr := call("192.0.2.1:1000", ""); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected 401 without credentials, got %d", rr.Code)
+		}
+	}
+
+	// Each failure is charged to the address, until it is turned away before authenticating
+	for i := 0; i < 3; i++ {
+		if rr := call("192.0.2.1:1000", "unknown.secret"); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected failure %d to get 401, got %d", i, rr.Code)
+		}
+	}
+	rr := call("192.0.2.1:1001", "unknown.secret")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "20" {
+		t.Fatalf("Expected 429 with Retry-After 20, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if rr := call("192.0.2.1:1002", key); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected valid credentials from the address to wait too, got %d", rr.Code)
+	}
+	if got := platform.metrics.limited.get("whoami", "auth_failures"); got != 2 {
+		t.Errorf("Expected 2 limited requests, got %v", got)
+	}
+
+	// Other addresses are not affected, and the allowance refills over time
+	if rr := call("192.0.2.2:1000", key); rr.Code != http.StatusOK {
+		t.Errorf("Expected another address to authenticate, got %d", rr.Code)
+	}
+	now = now.Add(20 * time.Second)
+	if rr := call("192.0.2.1:1000", key); rr.Code != http.StatusOK {
+		t.Errorf("Expected the address to authenticate after the wait, got %d", rr.Code)
+	}
+}
+
+func TestStreamQuotas(t *testing.T) {
+	config := DefaultConfig()
+	config.Limits.StreamsPerClient = 2
+	config.Limits.StreamsPerEndpoint = 3
+	platform := New(config)
+	endpoint := newBlockingEndpoint()
+	platform.RegisterEndpoint("ticks", endpoint)
+	handler := platform.Handler()
+
+	// open starts a stream from addr and returns its recorder once the endpoint runs or it is rejected
+	var wg sync.WaitGroup
+	open := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/ticks/stream", nil)
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		done := make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done)
+			handler.ServeHTTP(rr, req)
+		}()
+		select {
+		case <-endpoint.started:
+		case <-done:
+		}
+		return rr
+	}
+
+	open("10.0.0.1:1000")
+	open("10.0.0.1:1001")
+	rejected := open("10.0.0.1:1002")
+	if rejected.Code != http.StatusTooManyRequests || rejected.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected the third stream of a client to get 429 with Retry-After, got %d", rejected.Code)
+	}
+	if !strings.Contains(rejected.Body.String(), "this client") {
+		t.Errorf("Expected a per-client error, got %s", rejected.Body.String())
+	}
+
+	open("10.0.0.2:1000")
+	rejected = open("10.0.0.3:1000")
+	if rejected.Code != http.StatusTooManyRequests || !strings.Contains(rejected.Body.String(), "this endpoint") {
+		t.Errorf("Expected the endpoint quota to be exhausted, got %d: %s", rejected.Code, rejected.Body.String())
+	}
+
+	// Closing the streams frees the slots
+	close(endpoint.release)
+	wg.Wait()
+	endpoint.release = make(chan struct{})
+	defer close(endpoint.release)
+	if rr := open("10.0.0.3:1000"); rr.Code == http.StatusTooManyRequests {
+		t.Error("Expected slots to be freed when streams end")
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.RemoteAddr = "192.0.2.7:5555"
+	if got := clientKey(req); got != "ip:192.0.2.7" {
+		t.Errorf("Expected the IP key, got %q", got)
+	}
+
+	req = req.WithContext(ContextWithPrincipal(req.Context(), &Principal{ID: "alice"}))
+	if got := clientKey(req); got != "principal:alice" {
+		t.Errorf("Expected the principal key, got %q", got)
+	}
+}
+
+func TestLimitListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	ln := newLimitListener(inner, 1)
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatalf("Error dialing: %v", err)
+		}
+		defer conn.Close()
+	}
+
+	first := <-accepted
+	select {
+	case <-accepted:
+		t.Fatal("Expected the second connection to wait for a free slot")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	first.Close()
+	select {
+	case conn := <-accepted:
+		conn.Close()
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the second connection to be accepted once the first closed")
+	}
+}
+
+func TestReload_Limits(t *testing.T) {
+	platform := New(DefaultConfig())
+
+	next := DefaultConfig()
+	next.Limits.StreamsPerClient = 1
+	next.Limits.MaxConnections = 5
+	err := platform.Reload(next)
+	if err == nil || !strings.Contains(err.Error(), "limits.max_connections") {
+		t.Fatalf("Expected the connection limit change to be rejected, got %v", err)
+	}
+
+	got := platform.getConfig().Limits
+	if got.StreamsPerClient != 1 || got.MaxConnections != DefaultConfig().Limits.MaxConnections {
+		t.Errorf("Unexpected limits after reload: %+v", got)
+	}
+	if release, _ := platform.limiter.acquireStream("ip:1", "x"); release == nil {
+		t.Fatal("Expected the first stream to be allowed")
+	}
+	if release, _ := platform.limiter.acquireStream("ip:1", "x"); release != nil {
+		t.Error("Expected the reloaded quota to apply")
+	}
+}
+
+func TestLimitsConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	config.Limits = LimitsConfig{RequestsPerSecond: 5, StreamsPerClient: -1, AuthFailuresPerMinute: -1}
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"limits.burst", "limits.streams_per_client", "limits.auth_failures_per_minute"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+}
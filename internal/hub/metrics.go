@@ -0,0 +1,374 @@
+package hub
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultLatencyBuckets are the histogram buckets (in seconds) used for REST latency
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// defaultStreamBuckets are the histogram buckets (in seconds) used for stream durations
+var defaultStreamBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200}
+
+// metrics holds the hub's instrumentation and serves it in the Prometheus text exposition format
+type metrics struct {
+	requests       *counterVec
+	requestErrors  *counterVec
+	restLatency    *histogramVec
+	activeStreams  *gaugeVec
+	eventsSent     *counterVec
+	eventsDropped  *counterVec
+	streamDuration *histogramVec
+	limited        *counterVec
+	panics         *counterVec
+	cacheRequests  *counterVec
+}
+
+// newMetrics creates the hub's metric families
+func newMetrics() *metrics {
+	return &metrics{
+		requests: newCounterVec("hub_requests_total",
+			"Total number of requests received, by endpoint and transport.", "endpoint", "transport"),
+		requestErrors: newCounterVec("hub_request_errors_total",
+			"Total number of requests answered with an error status, by endpoint, transport and status.", "endpoint", "transport", "status"),
+		restLatency: newHistogramVec("hub_rest_request_duration_seconds",
+			"Latency of REST requests in seconds, by endpoint.", defaultLatencyBuckets, "endpoint"),
+		activeStreams: newGaugeVec("hub_active_streams",
+			"Number of SSE streams currently open, by endpoint.", "endpoint"),
+		eventsSent: newCounterVec("hub_stream_events_sent_total",
+			"Total number of SSE events sent, by endpoint.", "endpoint"),
+		eventsDropped: newCounterVec("hub_stream_events_dropped_total",
+			"Total number of SSE events that could not be encoded or delivered, by endpoint.", "endpoint"),
+		streamDuration: newHistogramVec("hub_stream_duration_seconds",
+			"Duration of SSE streams in seconds, by endpoint.", defaultStreamBuckets, "endpoint"),
+		limited: newCounterVec("hub_limited_requests_total",
+			"Total number of requests rejected by a rate limit or stream quota, by endpoint and reason.", "endpoint", "reason"),
+		panics: newCounterVec("hub_endpoint_panics_total",
+			"Total number of panics recovered from endpoint handlers, by endpoint and transport.", "endpoint", "transport"),
+		cacheRequests: newCounterVec("hub_rest_cache_requests_total",
+			"Total number of REST requests to endpoints whose responses the hub stores, by endpoint and result (hit or miss).", "endpoint", "result"),
+	}
+}
+
+// ServeHTTP writes all metrics in the Prometheus text exposition format
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		WriteError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "metrics only support GET")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+
+	m.requests.write(bw)
+	m.requestErrors.write(bw)
+	m.restLatency.write(bw)
+	m.activeStreams.write(bw)
+	m.eventsSent.write(bw)
+	m.eventsDropped.write(bw)
+	m.streamDuration.write(bw)
+	m.limited.write(bw)
+	m.panics.write(bw)
+	m.cacheRequests.write(bw)
+
+	// The goroutine count is sampled at scrape time
+	writeHeader(bw, "go_goroutines", "Number of goroutines that currently exist.", "gauge")
+	fmt.Fprintf(bw, "go_goroutines %d\n", runtime.NumGoroutine())
+
+	if err := bw.Flush(); err != nil {
+		slog.Debug("Error writing metrics", "error", err)
+	}
+}
+
+// instrument wraps an endpoint route so that every request is counted and REST latency is observed
+func (p *Hub) instrument(endpointName, transport string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		p.metrics.requests.inc(endpointName, transport)
+		next.ServeHTTP(sw, r)
+
+		status := sw.statusCode()
+		if status >= http.StatusBadRequest {
+			p.metrics.requestErrors.inc(endpointName, transport, strconv.Itoa(status))
+		}
+		if transport == transportREST {
+			p.metrics.restLatency.observe(time.Since(started).Seconds(), endpointName)
+		}
+	})
+}
+
+// statusWriter is an http.ResponseWriter that remembers the status code it sent
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code and forwards it
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write records an implicit 200 status and forwards the data
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush forwards to the underlying writer if it supports flushing
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCode returns the recorded status, defaulting to 200 when nothing was written
+func (w *statusWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// counterVec is a family of monotonically increasing counters partitioned by labels
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// newCounterVec creates a counter family
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+// inc increments the counter identified by the label values
+func (c *counterVec) inc(labelValues ...string) {
+	key := formatLabels(c.labels, labelValues)
+	c.mu.Lock()
+	c.values[key]++
+	c.mu.Unlock()
+}
+
+// get returns the current value of the counter identified by the label values
+func (c *counterVec) get(labelValues ...string) float64 {
+	key := formatLabels(c.labels, labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+// write writes the counter family in the exposition format
+func (c *counterVec) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, key, formatFloat(c.values[key]))
+	}
+}
+
+// gaugeVec is a family of values that can go up and down partitioned by labels
+type gaugeVec struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// newGaugeVec creates a gauge family
+func newGaugeVec(name, help string, labels ...string) *gaugeVec {
+	return &gaugeVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+// add adds delta to the gauge identified by the label values
+func (g *gaugeVec) add(delta float64, labelValues ...string) {
+	key := formatLabels(g.labels, labelValues)
+	g.mu.Lock()
+	g.values[key] += delta
+	g.mu.Unlock()
+}
+
+// get returns the current value of the gauge identified by the label values
+func (g *gaugeVec) get(labelValues ...string) float64 {
+	key := formatLabels(g.labels, labelValues)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.values[key]
+}
+
+// write writes the gauge family in the exposition format
+func (g *gaugeVec) write(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, key, formatFloat(g.values[key]))
+	}
+}
+
+// histogram holds the observations of a single label combination
+type histogram struct {
+	counts []uint64 // one per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// histogramVec is a family of histograms partitioned by labels
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+// newHistogramVec creates a histogram family with the given upper bounds
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogram)}
+}
+
+// observe records a value in the histogram identified by the label values
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	key := formatLabels(h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist, ok := h.values[key]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hist
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+			break
+		}
+	}
+	hist.sum += value
+	hist.count++
+}
+
+// count returns the number of observations of the histogram identified by the label values
+func (h *histogramVec) count(labelValues ...string) uint64 {
+	key := formatLabels(h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if hist, ok := h.values[key]; ok {
+		return hist.count
+	}
+	return 0
+}
+
+// write writes the histogram family in the exposition format
+func (h *histogramVec) write(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		hist := h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += hist.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLabel(key, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLabel(key, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, key, formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, key, hist.count)
+	}
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric family
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// formatLabels renders label names and values as {name="value",...}
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withLabel appends one more label to an already formatted label set
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + escapeLabelValue(value) + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return strings.TrimSuffix(labels, "}") + "," + pair + "}"
+}
+
+// labelEscaper escapes label values; it is built once since labels are formatted per event
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabelValue escapes backslashes, double quotes and newlines as required by the format
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// formatFloat renders a sample value the way Prometheus expects
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of a map in a stable order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,107 @@
+package hub
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics_Exposition(t *testing.T) {
+	m := newMetrics()
+	m.requests.inc("date", transportREST)
+	m.requests.inc("date", transportREST)
+	m.requestErrors.inc("date", transportSSE, "500")
+	m.activeStreams.add(2, "date")
+	m.restLatency.observe(0.02, "date")
+	m.restLatency.observe(3, "date")
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected Content-Type %q", ct)
+	}
+
+	body := rr.Body.String()
+	expected := []string{
+		"# TYPE hub_requests_total counter",
+		`hub_requests_total{endpoint="date",transport="rest"} 2`,
+		`hub_request_errors_total{endpoint="date",transport="sse",status="500"} 1`,
+		`hub_active_streams{endpoint="date"} 2`,
+		"# TYPE hub_rest_request_duration_seconds histogram",
+		`hub_rest_request_duration_seconds_bucket{endpoint="date",le="0.01"} 0`,
+		`hub_rest_request_duration_seconds_bucket{endpoint="date",le="0.025"} 1`,
+		`hub_rest_request_duration_seconds_bucket{endpoint="date",le="5"} 2`,
+		`hub_rest_request_duration_seconds_bucket{endpoint="date",le="+Inf"} 2`,
+		`hub_rest_request_duration_seconds_sum{endpoint="date"} 3.02`,
+		`hub_rest_request_duration_seconds_count{endpoint="date"} 2`,
+		"# TYPE go_goroutines gauge",
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, body)
+		}
+	}
+}
+
+func TestMetrics_EscapeLabelValue(t *testing.T) {
+	got := formatLabels([]string{"endpoint"}, []string{"a\"b\\c\nd"})
+	want := `{endpoint="a\"b\\c\nd"}`
+	if got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestMetrics_InstrumentedHandlers(t *testing.T) {
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("test", NewMockEndpoint([]byte(`{"message":"Hello"}`)))
+
+	server := httptest.NewServer(platform.Handler())
+	defer server.Close()
+
+	for _, path := range []string{"/test", "/test", "/test/stream"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Error making request: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	if got := platform.metrics.requests.get("test", transportREST); got != 2 {
+		t.Errorf("Expected 2 REST requests, got %v", got)
+	}
+	if got := platform.metrics.requests.get("test", transportSSE); got != 1 {
+		t.Errorf("Expected 1 SSE request, got %v", got)
+	}
+	if got := platform.metrics.restLatency.count("test"); got != 2 {
+		t.Errorf("Expected 2 REST latency observations, got %d", got)
+	}
+	if got := platform.metrics.eventsSent.get("test"); got != 1 {
+		t.Errorf("Expected 1 event sent, got %v", got)
+	}
+	if got := platform.metrics.activeStreams.get("test"); got != 0 {
+		t.Errorf("Expected no active streams, got %v", got)
+	}
+	if got := platform.metrics.streamDuration.count("test"); got != 1 {
+		t.Errorf("Expected 1 stream duration observation, got %d", got)
+	}
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Error making request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error reading response body: %v", err)
+	}
+	if !strings.Contains(string(body), `hub_requests_total{endpoint="test",transport="rest"} 2`) {
+		t.Errorf("Expected REST request counter in metrics output, got:\n%s", body)
+	}
+}
@@ -0,0 +1,171 @@
+package hub
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// policyWildcard matches any role, endpoint, method or transport in a policy rule
+const policyWildcard = "*"
+
+// adminEndpoint is the endpoint name the policy uses for the /admin routes
+const adminEndpoint = "admin"
+
+// PolicyConfig represents the role-based authorization policy. With no rules every
+// authenticated request is allowed; once a rule exists, requests no rule allows are denied.
+type PolicyConfig struct {
+	DryRun bool         `json:"dry_run"` // Log would-be denials without enforcing them
+	Rules  []PolicyRule `json:"rules"`
+}
+
+// PolicyRule allows a role to use endpoints with the given methods and transports.
+// Empty methods or transports allow all of them; "*" matches anything.
+type PolicyRule struct {
+	Role       string   `json:"role"`       // "*" for any authenticated principal
+	Endpoints  []string `json:"endpoints"`  // Endpoint names; "admin" covers the /admin routes
+	Methods    []string `json:"methods"`    // HTTP methods, e.g. GET, POST
+	Transports []string `json:"transports"` // rest, sse
+}
+
+// validate checks the policy rules
+func (c PolicyConfig) validate() []error {
+	var errs []error
+	for i, rule := range c.Rules {
+		if rule.Role == "" {
+			errs = append(errs, fmt.Errorf("policy.rules[%d].role: must be set", i))
+		}
+		if len(rule.Endpoints) == 0 {
+			errs = append(errs, fmt.Errorf("policy.rules[%d].endpoints: must list at least one endpoint", i))
+		}
+		for _, method := range rule.Methods {
+			if method == "" || strings.ContainsAny(method, " /") {
+				errs = append(errs, fmt.Errorf("policy.rules[%d].methods: %q is not an HTTP method", i, method))
+			}
+		}
+		for _, transport := range rule.Transports {
+			switch transport {
+			case transportREST, transportSSE, policyWildcard:
+			default:
+				errs = append(errs, fmt.Errorf("policy.rules[%d].transports: %q must be one of rest, sse", i, transport))
+			}
+		}
+	}
+	return errs
+}
+
+// policy is the compiled form of a PolicyConfig
+type policy struct {
+	dryRun bool
+	rules  []policyRule
+}
+
+// policyRule holds the sets a request is matched against; a nil set matches anything
+type policyRule struct {
+	role       string
+	endpoints  map[string]bool
+	methods    map[string]bool
+	transports map[string]bool
+}
+
+// newPolicy compiles a validated policy configuration
+func newPolicy(config PolicyConfig) *policy {
+	p := &policy{dryRun: config.DryRun}
+	for _, rule := range config.Rules {
+		p.rules = append(p.rules, policyRule{
+			role:       rule.Role,
+			endpoints:  policySet(rule.Endpoints, false),
+			methods:    policySet(rule.Methods, true),
+			transports: policySet(rule.Transports, false),
+		})
+	}
+	return p
+}
+
+// policySet turns a list into a set, or nil when it is empty or contains the wildcard
+func policySet(items []string, upper bool) map[string]bool {
+	if len(items) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item == policyWildcard {
+			return nil
+		}
+		if upper {
+			item = strings.ToUpper(item)
+		}
+		set[item] = true
+	}
+	return set
+}
+
+// matches reports whether the set is nil (anything) or holds value
+func matches(set map[string]bool, value string) bool {
+	return set == nil || set[value]
+}
+
+// enabled reports whether the policy restricts anything
+func (p *policy) enabled() bool {
+	return p != nil && len(p.rules) > 0
+}
+
+// allows reports whether a principal may use an endpoint with a method over a transport
+func (p *policy) allows(principal *Principal, endpoint, method, transport string) bool {
+	if !p.enabled() {
+		return true
+	}
+	if principal == nil {
+		return false
+	}
+	for _, rule := range p.rules {
+		if rule.role != policyWildcard && !contains(principal.Roles, rule.role) {
+			continue
+		}
+		if matches(rule.endpoints, endpoint) && matches(rule.methods, method) && matches(rule.transports, transport) {
+			return true
+		}
+	}
+	return false
+}
+
+// getPolicy returns the current authorization policy
+func (p *Hub) getPolicy() *policy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.policy
+}
+
+// authorize applies the authorization policy after authentication and before the endpoint
+// is called. Denials are written to the audit log; in dry-run mode they are only logged.
+func (p *Hub) authorize(endpointName, transport string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := p.getPolicy()
+		principal, _ := PrincipalFromContext(r.Context())
+		if policy.allows(principal, endpointName, r.Method, transport) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		attrs := []any{
+			"audit", "authorization_denied",
+			"endpoint", endpointName,
+			"method", r.Method,
+			"transport", transport,
+			"remote_addr", r.RemoteAddr,
+			"dry_run", policy.dryRun,
+		}
+		if principal != nil {
+			attrs = append(attrs, "principal", principal.ID, "scheme", principal.Scheme, "roles", principal.Roles)
+		}
+
+		if policy.dryRun {
+			slog.Warn("Authorization would be denied", attrs...)
+			next.ServeHTTP(w, r)
+			return
+		}
+		slog.Warn("Authorization denied", attrs...)
+		WriteError(w, http.StatusForbidden, "Forbidden", "the request is not permitted by the authorization policy")
+	})
+}
@@ -0,0 +1,166 @@
+package hub
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// roleAuthenticator trusts the roles listed in the X-Test-Roles header
+type roleAuthenticator struct{}
+
+// Authenticate implements Authenticator
+func (roleAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	roles := r.Header.Get("X-Test-Roles")
+	if roles == "" {
+		return nil, ErrNoCredentials
+	}
+	return &Principal{ID: "user-" + roles, Scheme: "test", Roles: strings.Split(roles, ",")}, nil
+}
+
+// Challenge implements Authenticator
+func (roleAuthenticator) Challenge() string {
+	return "Test"
+}
+
+// tradingPolicy lets traders POST orders and viewers only stream prices
+func tradingPolicy() PolicyConfig {
+	return PolicyConfig{Rules: []PolicyRule{
+		{Role: "trader", Endpoints: []string{"orders"}, Methods: []string{"GET", "post"}},
+		{Role: "trader", Endpoints: []string{"prices"}},
+		{Role: "viewer", Endpoints: []string{"prices"}, Methods: []string{"GET"}, Transports: []string{"sse"}},
+		{Role: "operator", Endpoints: []string{"admin"}},
+	}}
+}
+
+// captureLogs sends the default logger to a buffer for the duration of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return buf
+}
+
+// policyTestHub returns a hub with orders and prices endpoints behind the role authenticator
+func policyTestHub(config PolicyConfig) *Hub {
+	hubConfig := DefaultConfig()
+	hubConfig.Policy = config
+	platform := New(hubConfig)
+	platform.RegisterEndpoint("orders", NewMockEndpoint([]byte(`{"id":1}`)))
+	platform.RegisterEndpoint("prices", NewMockEndpoint([]byte(`{"bid":1}`)))
+	platform.SetAuthenticator(roleAuthenticator{})
+	return platform
+}
+
+func TestPolicy_Enforced(t *testing.T) {
+	logs := captureLogs(t)
+	handler := policyTestHub(tradingPolicy()).Handler()
+
+	tests := []struct {
+		roles  string
+		method string
+		path   string
+		want   int
+	}{
+		{"trader", http.MethodPost, "/orders", http.StatusOK},
+		{"trader", http.MethodGet, "/orders/stream?max_count=1", http.StatusOK},
+		{"trader", http.MethodDelete, "/orders", http.StatusForbidden},
+		{"trader", http.MethodGet, "/prices", http.StatusOK},
+		{"viewer", http.MethodGet, "/prices/stream?max_count=1", http.StatusOK},
+		{"viewer", http.MethodGet, "/prices", http.StatusForbidden},
+		{"viewer", http.MethodPost, "/orders", http.StatusForbidden},
+		{"viewer,trader", http.MethodPost, "/orders", http.StatusOK},
+		{"viewer", http.MethodGet, "/admin/config", http.StatusForbidden},
+		{"operator", http.MethodGet, "/admin/config", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("X-Test-Roles", tt.roles)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s %s %s: expected status code %d, got %d", tt.roles, tt.method, tt.path, tt.want, rr.Code)
+		}
+		if tt.want == http.StatusForbidden && rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s %s: expected a JSON:API error before the stream opens", tt.roles, tt.method, tt.path)
+		}
+	}
+
+	// Denials are audit logged with the principal and the request
+	if !strings.Contains(logs.String(), "audit=authorization_denied") || !strings.Contains(logs.String(), "principal=user-viewer") {
+		t.Errorf("Expected denials in the audit log, got %s", logs.String())
+	}
+}
+
+func TestPolicy_DryRun(t *testing.T) {
+	logs := captureLogs(t)
+	config := tradingPolicy()
+	config.DryRun = true
+	handler := policyTestHub(config).Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	req.Header.Set("X-Test-Roles", "viewer")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected dry-run to allow the request, got %d", rr.Code)
+	}
+	if !strings.Contains(logs.String(), "Authorization would be denied") || !strings.Contains(logs.String(), "dry_run=true") {
+		t.Errorf("Expected the would-be denial to be logged, got %s", logs.String())
+	}
+}
+
+func TestPolicy_NoRulesAllowsAll(t *testing.T) {
+	handler := policyTestHub(PolicyConfig{}).Handler()
+
+	req := httptest.NewRequest(http.MethodDelete, "/orders", nil)
+	req.Header.Set("X-Test-Roles", "anyone")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d without rules, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestPolicy_Reload(t *testing.T) {
+	platform := policyTestHub(PolicyConfig{})
+	handler := platform.Handler()
+
+	next := platform.getConfig()
+	next.Policy = PolicyConfig{Rules: []PolicyRule{{Role: "*", Endpoints: []string{"prices"}}}}
+	if err := platform.Reload(next); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for path, want := range map[string]int{"/prices": http.StatusOK, "/orders": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Test-Roles", "guest")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("%s: expected status code %d after reload, got %d", path, want, rr.Code)
+		}
+	}
+}
+
+func TestPolicyConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	config.Policy.Rules = []PolicyRule{{Methods: []string{"GET /"}, Transports: []string{"websocket"}}}
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"rules[0].role", "rules[0].endpoints", "rules[0].methods", "rules[0].transports"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+}
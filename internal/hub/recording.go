@@ -0,0 +1,53 @@
+package hub
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"trading/internal/recording"
+)
+
+// RecordingConfig represents the recording of streams for later replay, see the replay
+// package. Without a directory nothing is recorded.
+type RecordingConfig struct {
+	Dir       string   `json:"dir"`       // Directory of the recordings, one JSON Lines file per stream
+	Endpoints []string `json:"endpoints"` // Default: every endpoint
+}
+
+// validate checks the recording settings
+func (c RecordingConfig) validate() []error {
+	if c.Dir == "" && len(c.Endpoints) > 0 {
+		return []error{fmt.Errorf("recording.dir: required to record %v", c.Endpoints)}
+	}
+	return nil
+}
+
+// records reports whether the streams of endpointName are recorded
+func (c RecordingConfig) records(endpointName string) bool {
+	return c.Dir != "" && (len(c.Endpoints) == 0 || slices.Contains(c.Endpoints, endpointName))
+}
+
+// startRecording creates the recording of a stream if its endpoint is recorded. A
+// recording that cannot be created is logged and the stream goes on without it.
+func (p *Hub) startRecording(endpointName string, r *http.Request) *recording.Writer {
+	p.mu.RLock()
+	config := p.config.Recording
+	p.mu.RUnlock()
+	if !config.records(endpointName) {
+		return nil
+	}
+
+	w, err := recording.Create(config.Dir, recording.Header{
+		Endpoint: endpointName,
+		Query:    r.URL.Query(),
+		Started:  p.getClock().Now(),
+	})
+	if err != nil {
+		slog.Error("Error starting recording", "endpoint", endpointName, "error", err)
+		return nil
+	}
+	slog.Debug("Recording stream", "endpoint", endpointName, "file", w.Name())
+	return w
+}
@@ -0,0 +1,91 @@
+package hub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"trading/internal/clock"
+	"trading/internal/recording"
+)
+
+// sequenceEndpoint sends a JSON event and a text event
+type sequenceEndpoint struct{}
+
+// HandleSSE implements the Endpoint interface
+func (sequenceEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"n":1}`))
+	w.Write([]byte(`plain`))
+}
+
+func TestRecording(t *testing.T) {
+	dir := t.TempDir()
+	config := DefaultConfig()
+	config.Recording = RecordingConfig{Dir: dir, Endpoints: []string{"quotes"}}
+	platform := New(config)
+	platform.RegisterEndpoint("quotes", sequenceEndpoint{})
+	platform.RegisterEndpoint("other", sequenceEndpoint{})
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	platform.SetClock(clock.NewFake(now))
+	handler := platform.Handler()
+
+	for _, path := range []string{"/quotes/stream?symbol=EURUSD", "/quotes?symbol=EURUSD", "/other/stream"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Only the streams of the listed endpoints are recorded
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 || !strings.HasPrefix(filepath.Base(files[0]), "quotes-") {
+		t.Fatalf("Expected one recording of quotes, got %v", files)
+	}
+
+	rec, err := recording.Read(files[0])
+	if err != nil {
+		t.Fatalf("Error reading recording: %v", err)
+	}
+	if rec.Header.Endpoint != "quotes" || !rec.Header.Started.Equal(now) || rec.Header.Query["symbol"][0] != "EURUSD" {
+		t.Errorf("Unexpected header %+v", rec.Header)
+	}
+	want := []recording.Event{
+		{Time: now, Data: []byte(`{"n":1}`)},
+		{Time: now, Text: "plain"},
+	}
+	if !reflect.DeepEqual(rec.Events, want) {
+		t.Errorf("Expected events %+v, got %+v", want, rec.Events)
+	}
+}
+
+func TestRecording_Failure(t *testing.T) {
+	// A file where the directory should be
+	dir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(dir, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := DefaultConfig()
+	config.Recording.Dir = dir
+	platform := New(config)
+	platform.RegisterEndpoint("quotes", sequenceEndpoint{})
+	logs := captureLogs(t)
+
+	rr := httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/quotes/stream", nil))
+	if strings.Count(rr.Body.String(), "data: ") != 2 {
+		t.Errorf("Expected the stream to go on without recording, got %s", rr.Body.String())
+	}
+	if !strings.Contains(logs.String(), "Error starting recording") {
+		t.Errorf("Expected the failure to be logged, got %s", logs.String())
+	}
+}
+
+func TestRecordingConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	config.Recording.Endpoints = []string{"quotes"}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "recording.dir") {
+		t.Errorf("Expected a missing directory to be reported, got %v", err)
+	}
+}
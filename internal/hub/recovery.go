@@ -0,0 +1,145 @@
+package hub
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"trading/internal/clock"
+)
+
+// RecoveryConfig represents what the hub does when an endpoint panics. A panic is always
+// recovered and answered with a 500; an endpoint that keeps panicking can be quarantined.
+type RecoveryConfig struct {
+	QuarantineAfter  int           `json:"quarantine_after"`  // Default: 0 (never); panics within quarantine_window that quarantine the endpoint
+	QuarantineWindow time.Duration `json:"quarantine_window"` // Default: 1m
+	QuarantineFor    time.Duration `json:"quarantine_for"`    // Default: 5m; how long a quarantined endpoint answers 503
+}
+
+// validate checks the recovery settings
+func (c RecoveryConfig) validate() []error {
+	var errs []error
+	if c.QuarantineAfter < 0 {
+		errs = append(errs, fmt.Errorf("recovery.quarantine_after: %d must not be negative", c.QuarantineAfter))
+	}
+	if c.QuarantineAfter > 0 && c.QuarantineWindow <= 0 {
+		errs = append(errs, fmt.Errorf("recovery.quarantine_window: %s must be positive when quarantine_after is set", c.QuarantineWindow))
+	}
+	if c.QuarantineAfter > 0 && c.QuarantineFor <= 0 {
+		errs = append(errs, fmt.Errorf("recovery.quarantine_for: %s must be positive when quarantine_after is set", c.QuarantineFor))
+	}
+	return errs
+}
+
+// panicTracker remembers recent panics per endpoint and which endpoints are quarantined
+type panicTracker struct {
+	mu          sync.Mutex
+	panics      map[string][]time.Time
+	quarantined map[string]time.Time // Endpoint name to the end of its quarantine
+	now         func() time.Time
+}
+
+// newPanicTracker creates an empty panicTracker
+func newPanicTracker() *panicTracker {
+	return &panicTracker{
+		panics:      make(map[string][]time.Time),
+		quarantined: make(map[string]time.Time),
+		now:         time.Now,
+	}
+}
+
+// record notes a panic of endpointName and reports whether it put the endpoint in quarantine
+func (t *panicTracker) record(endpointName string, config RecoveryConfig) bool {
+	if config.QuarantineAfter < 1 {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+
+	// Only panics within the window count
+	recent := t.panics[endpointName][:0]
+	for _, at := range t.panics[endpointName] {
+		if now.Sub(at) < config.QuarantineWindow {
+			recent = append(recent, at)
+		}
+	}
+	recent = append(recent, now)
+
+	if len(recent) < config.QuarantineAfter {
+		t.panics[endpointName] = recent
+		return false
+	}
+	delete(t.panics, endpointName)
+	t.quarantined[endpointName] = now.Add(config.QuarantineFor)
+	return true
+}
+
+// remaining returns how long endpointName stays quarantined, or 0 if it is not
+func (t *panicTracker) remaining(endpointName string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until, ok := t.quarantined[endpointName]
+	if !ok {
+		return 0
+	}
+	left := until.Sub(t.now())
+	if left <= 0 {
+		delete(t.quarantined, endpointName)
+		return 0
+	}
+	return left
+}
+
+// getRecovery returns the current recovery settings
+func (p *Hub) getRecovery() RecoveryConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config.Recovery
+}
+
+// callEndpoint runs the endpoint's handler and recovers a panic, logging it with its stack
+// trace. It reports whether the handler panicked.
+func (p *Hub) callEndpoint(endpointName, transport string, endpoint Endpoint, w http.ResponseWriter, r *http.Request) (panicked bool) {
+	defer func() {
+		value := recover()
+		if value == nil {
+			return
+		}
+		panicked = true
+
+		slog.Error("Endpoint panicked", "endpoint", endpointName, "transport", transport,
+			"panic", fmt.Sprint(value), "stack", string(debug.Stack()))
+		p.metrics.panics.inc(endpointName, transport)
+
+		config := p.getRecovery()
+		if p.panics.record(endpointName, config) {
+			slog.Error("Endpoint quarantined after repeated panics", "endpoint", endpointName,
+				"panics", config.QuarantineAfter, "window", config.QuarantineWindow.String(), "duration", config.QuarantineFor.String())
+		}
+	}()
+
+	endpoint.HandleSSE(w, r.WithContext(clock.ContextWithClock(r.Context(), p.getClock())))
+	return false
+}
+
+// quarantine answers 503 for an endpoint quarantined after repeated panics
+func (p *Hub) quarantine(endpointName string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		left := p.panics.remaining(endpointName)
+		if left <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(left.Seconds()))))
+		WriteError(w, http.StatusServiceUnavailable, "Service Unavailable", "the endpoint is quarantined after repeated failures")
+	})
+}
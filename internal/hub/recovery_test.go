@@ -0,0 +1,127 @@
+package hub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// panickingEndpoint sends the given number of events, then panics
+type panickingEndpoint struct {
+	events int
+}
+
+// HandleSSE implements the Endpoint interface
+func (e panickingEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	for i := 0; i < e.events; i++ {
+		w.Write([]byte(`"tick"`))
+	}
+	panic("broken feed")
+}
+
+func TestRecovery_REST(t *testing.T) {
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("broken", panickingEndpoint{})
+	logs := captureLogs(t)
+
+	rr := httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/broken", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if response := decodeErrors(t, rr); len(response.Errors) != 1 || response.Errors[0].Status != "500" {
+		t.Errorf("Unexpected error response %+v", response)
+	}
+	if !strings.Contains(logs.String(), "Endpoint panicked") || !strings.Contains(logs.String(), "broken feed") {
+		t.Errorf("Expected the panic to be logged, got %s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "recovery.go") {
+		t.Errorf("Expected a stack trace in the log, got %s", logs.String())
+	}
+	if got := platform.metrics.panics.get("broken", transportREST); got != 1 {
+		t.Errorf("Expected 1 panic, got %v", got)
+	}
+}
+
+func TestRecovery_SSE(t *testing.T) {
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("broken", panickingEndpoint{events: 2})
+	captureLogs(t)
+
+	rr := httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/broken/stream", nil))
+
+	body := rr.Body.String()
+	if strings.Count(body, `data: {"data":"tick"}`) != 2 {
+		t.Errorf("Expected the events sent before the panic, got %s", body)
+	}
+	if !strings.HasSuffix(body, "event: error\ndata: {\"errors\":[{\"status\":\"500\",\"title\":\"Internal Server Error\",\"detail\":\"the endpoint failed and the stream ended\"}]}\n\n") {
+		t.Errorf("Expected the stream to end with an error event, got %s", body)
+	}
+	if got := platform.metrics.panics.get("broken", transportSSE); got != 1 {
+		t.Errorf("Expected 1 panic, got %v", got)
+	}
+}
+
+func TestRecovery_Quarantine(t *testing.T) {
+	config := DefaultConfig()
+	config.Recovery.QuarantineAfter = 2
+	platform := New(config)
+	platform.RegisterEndpoint("broken", panickingEndpoint{})
+	platform.RegisterEndpoint("test", NewMockEndpoint([]byte(`"ok"`)))
+	handler := platform.Handler()
+	captureLogs(t)
+
+	now := time.Unix(1_700_000_000, 0)
+	platform.panics.now = func() time.Time { return now }
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	// Panics outside the window do not add up
+	get("/broken")
+	now = now.Add(2 * time.Minute)
+	if rr := get("/broken"); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+
+	// The second panic within the window quarantines the endpoint, on both transports
+	now = now.Add(30 * time.Second)
+	get("/broken")
+	for _, path := range []string{"/broken", "/broken/stream"} {
+		rr := get(path)
+		if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") != "300" {
+			t.Errorf("%s: expected 503 with Retry-After 300, got %d %q", path, rr.Code, rr.Header().Get("Retry-After"))
+		}
+	}
+	if rr := get("/test"); rr.Code != http.StatusOK {
+		t.Errorf("Expected other endpoints to be unaffected, got %d", rr.Code)
+	}
+
+	// The quarantine ends after its duration
+	now = now.Add(5 * time.Minute)
+	if rr := get("/broken"); rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected the endpoint to run again, got %d", rr.Code)
+	}
+}
+
+func TestRecoveryConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	config.Recovery = RecoveryConfig{QuarantineAfter: 3}
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"recovery.quarantine_window", "recovery.quarantine_for"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+}
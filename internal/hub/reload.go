@@ -0,0 +1,209 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+)
+
+// Reconfigurable is an optional interface an endpoint can implement to receive its
+// configuration section when the hub configuration is reloaded. The endpoint decodes
+// the section itself, typically with section.Decode(&cfg). Returning an error keeps the
+// endpoint's previous configuration.
+type Reconfigurable interface {
+	Reconfigure(section ConfigSection) error
+}
+
+// ErrInvalidConfig is returned by Reload when the configuration is rejected as a whole and
+// nothing was applied
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// Reload applies a new configuration to the running hub. Changes that can be applied live
+// (log level, TLS certificates and settings, authorization policy, rate limits and stream
+// quotas, CORS policy, request hardening, panic quarantine, idempotency TTL, stream
+// recording, REST timeout, endpoint start and stop timeouts, endpoint sections) take effect
+// immediately; changes that need a restart (port, tracing, switching TLS on or off, the
+// connection and header size limits, the audit log, switching authentication off) are
+// rejected with a logged error and the running value is kept. An invalid configuration is
+// rejected as a whole with an error wrapping ErrInvalidConfig.
+func (p *Hub) Reload(config Config) error {
+	if err := config.Validate(); err != nil {
+		slog.Error("Rejected configuration reload", "error", err)
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	p.mu.Lock()
+	current := p.config
+	endpoints := make(map[string]Endpoint, len(p.endpoints))
+	for name, endpoint := range p.endpoints {
+		endpoints[name] = endpoint
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	next := config
+
+	// Settings bound at startup cannot change without a restart
+	if next.Port != current.Port {
+		err := fmt.Errorf("port: changing from %q to %q requires a restart", current.Port, next.Port)
+		slog.Error("Rejected configuration change", "field", "port", "error", err)
+		errs = append(errs, err)
+		next.Port = current.Port
+	}
+	if next.Tracing != current.Tracing {
+		err := errors.New("tracing: changing the tracing configuration requires a restart")
+		slog.Error("Rejected configuration change", "field", "tracing", "error", err)
+		errs = append(errs, err)
+		next.Tracing = current.Tracing
+	}
+
+	if next.Limits.MaxConnections != current.Limits.MaxConnections {
+		err := errors.New("limits.max_connections: changing the connection limit requires a restart")
+		slog.Error("Rejected configuration change", "field", "limits.max_connections", "error", err)
+		errs = append(errs, err)
+		next.Limits.MaxConnections = current.Limits.MaxConnections
+	}
+
+	if next.Hardening.MaxHeaderBytes != current.Hardening.MaxHeaderBytes {
+		err := errors.New("hardening.max_header_bytes: changing the header size limit requires a restart")
+		slog.Error("Rejected configuration change", "field", "hardening.max_header_bytes", "error", err)
+		errs = append(errs, err)
+		next.Hardening.MaxHeaderBytes = current.Hardening.MaxHeaderBytes
+	}
+
+	if !reflect.DeepEqual(next.Audit, current.Audit) {
+		err := errors.New("audit: changing the audit log requires a restart")
+		slog.Error("Rejected configuration change", "field", "audit", "error", err)
+		errs = append(errs, err)
+		next.Audit = current.Audit
+	}
+
+	// Keys can be replaced live, but a file missing its auth section must not make the
+	// endpoints anonymous
+	if current.Auth.Enabled() && !next.Auth.Enabled() {
+		err := errors.New("auth: switching authentication off requires a restart")
+		slog.Error("Rejected configuration change", "field", "auth", "error", err)
+		errs = append(errs, err)
+		next.Auth = current.Auth
+	}
+
+	// Certificates can be replaced live, but TLS cannot be switched on or off
+	if next.TLS.Enabled() != current.TLS.Enabled() {
+		err := errors.New("tls: enabling or disabling TLS requires a restart")
+		slog.Error("Rejected configuration change", "field", "tls", "error", err)
+		errs = append(errs, err)
+		next.TLS = current.TLS
+	} else if err := p.reloadTLS(next.TLS); err != nil {
+		err = fmt.Errorf("tls: %w", err)
+		slog.Error("Rejected configuration change", "field", "tls", "error", err)
+		errs = append(errs, err)
+		next.TLS = current.TLS
+	}
+
+	// Endpoint sections are handed to endpoints that support reconfiguration
+	next.Endpoints = make(map[string]ConfigSection, len(config.Endpoints))
+	for name, section := range config.Endpoints {
+		next.Endpoints[name] = section
+	}
+	for _, name := range sortedKeys(endpoints) {
+		section := config.Endpoints[name]
+		previous := current.Endpoints[name]
+		if reflect.DeepEqual(section, previous) {
+			continue
+		}
+
+		reconfigurable, ok := endpoints[name].(Reconfigurable)
+		if !ok {
+			err := fmt.Errorf("endpoints.%s: endpoint does not support reconfiguration, restart required", name)
+			slog.Error("Rejected configuration change", "endpoint", name, "error", err)
+			errs = append(errs, err)
+			restoreSection(next.Endpoints, name, previous)
+			continue
+		}
+		if err := reconfigurable.Reconfigure(section); err != nil {
+			err = fmt.Errorf("endpoints.%s: %w", name, err)
+			slog.Error("Endpoint rejected configuration", "endpoint", name, "error", err)
+			errs = append(errs, err)
+			restoreSection(next.Endpoints, name, previous)
+			continue
+		}
+		slog.Info("Endpoint reconfigured", "endpoint", name)
+	}
+
+	// Safe settings are applied live
+	p.logLevel.Set(getLogLevel(next.LogLevel))
+	p.limiter.update(next.Limits)
+
+	p.mu.Lock()
+	p.config = next
+	p.policy = newPolicy(next.Policy)
+	p.cors = newCORSPolicy(next.CORS)
+	p.mu.Unlock()
+
+	slog.Info("Configuration reloaded", "log_level", next.LogLevel, "rejected", len(errs))
+	return errors.Join(errs...)
+}
+
+// reloadTLS applies new TLS settings, or reads the current certificate files again
+// so that certificates rotated on disk are picked up
+func (p *Hub) reloadTLS(config TLSConfig) error {
+	p.mu.RLock()
+	manager := p.tls
+	p.mu.RUnlock()
+
+	if manager == nil {
+		return nil
+	}
+	if err := manager.update(config); err != nil {
+		return err
+	}
+	slog.Info("TLS certificates reloaded")
+	return nil
+}
+
+// restoreSection puts back the previous section of an endpoint after a rejected change
+func restoreSection(sections map[string]ConfigSection, name string, previous ConfigSection) {
+	if previous == nil {
+		delete(sections, name)
+		return
+	}
+	sections[name] = previous
+}
+
+// getConfig returns the effective configuration
+func (p *Hub) getConfig() Config {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config
+}
+
+// redactedHash replaces API key hashes in the configuration served to admins
+const redactedHash = "[REDACTED]"
+
+// redacted returns a copy of the configuration without credential material
+func (c Config) redacted() Config {
+	keys := make([]APIKey, len(c.Auth.APIKeys.Keys))
+	for i, key := range c.Auth.APIKeys.Keys {
+		key.Hash = redactedHash
+		keys[i] = key
+	}
+	c.Auth.APIKeys.Keys = keys
+	return c
+}
+
+// handleAdminConfig returns the effective configuration, without credential material
+func (p *Hub) handleAdminConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "the configuration can only be read")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(DataResponse{Data: p.getConfig().redacted()}); err != nil {
+		slog.Error("Error encoding configuration", "error", err)
+	}
+}
@@ -0,0 +1,173 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// reconfigurableEndpoint is a mock endpoint that records the sections it receives
+type reconfigurableEndpoint struct {
+	MockEndpoint
+	mu       sync.Mutex
+	sections []ConfigSection
+	err      error
+}
+
+// Reconfigure implements the Reconfigurable interface
+func (e *reconfigurableEndpoint) Reconfigure(section ConfigSection) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	e.sections = append(e.sections, section)
+	return nil
+}
+
+func TestReload_AppliesSafeChanges(t *testing.T) {
+	config := DefaultConfig()
+	config.Endpoints = map[string]ConfigSection{"prices": {"depth": "5"}}
+	platform := New(config)
+	prices := &reconfigurableEndpoint{}
+	platform.RegisterEndpoint("prices", prices)
+
+	next := config
+	next.LogLevel = "debug"
+	next.Endpoints = map[string]ConfigSection{"prices": {"depth": "10"}}
+	if err := platform.Reload(next); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if platform.logLevel.Level() != slog.LevelDebug {
+		t.Errorf("Expected log level debug, got %v", platform.logLevel.Level())
+	}
+	if len(prices.sections) != 1 || prices.sections[0]["depth"] != "10" {
+		t.Errorf("Expected endpoint to be reconfigured, got %v", prices.sections)
+	}
+	if got := platform.getConfig(); got.LogLevel != "debug" || got.Endpoints["prices"]["depth"] != "10" {
+		t.Errorf("Unexpected effective config: %+v", got)
+	}
+
+	// An unchanged section is not handed to the endpoint again
+	if err := platform.Reload(next); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(prices.sections) != 1 {
+		t.Errorf("Expected no reconfiguration for an unchanged section, got %d", len(prices.sections))
+	}
+}
+
+func TestReload_RejectsUnsafeChanges(t *testing.T) {
+	config := DefaultConfig()
+	platform := New(config)
+	platform.RegisterEndpoint("plain", NewMockEndpoint(nil))
+	failing := &reconfigurableEndpoint{err: errors.New("depth too large")}
+	platform.RegisterEndpoint("failing", failing)
+
+	next := config
+	next.Port = "9999"
+	next.LogLevel = "warn"
+	next.Tracing.Exporter = "stdout"
+	next.Endpoints = map[string]ConfigSection{
+		"plain":   {"x": "1"},
+		"failing": {"depth": "1000"},
+	}
+
+	err := platform.Reload(next)
+	if err == nil {
+		t.Fatal("Expected rejected changes to be reported")
+	}
+	for _, want := range []string{"port", "tracing", "endpoints.plain", "endpoints.failing: depth too large"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+
+	got := platform.getConfig()
+	if got.Port != config.Port || got.Tracing != config.Tracing {
+		t.Errorf("Expected startup settings to be kept, got %+v", got)
+	}
+	if len(got.Endpoints) != 0 {
+		t.Errorf("Expected rejected sections to be dropped, got %v", got.Endpoints)
+	}
+	if got.LogLevel != "warn" || platform.logLevel.Level() != slog.LevelWarn {
+		t.Errorf("Expected the safe log level change to be applied, got %q", got.LogLevel)
+	}
+}
+
+func TestReload_InvalidConfigIsRejected(t *testing.T) {
+	platform := New(DefaultConfig())
+
+	next := DefaultConfig()
+	next.LogLevel = "loud"
+	if err := platform.Reload(next); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("Expected an invalid configuration to be rejected with ErrInvalidConfig, got %v", err)
+	}
+	if platform.getConfig().LogLevel != "info" {
+		t.Error("Expected the running configuration to be kept")
+	}
+}
+
+func TestReload_AuthCannotBeSwitchedOff(t *testing.T) {
+	_, entry := testAPIKey(t, "desk1")
+	config := DefaultConfig()
+	config.Auth.APIKeys.Keys = []APIKey{entry}
+	platform := New(config)
+
+	// A file without its auth section keeps the running keys
+	next := DefaultConfig()
+	next.LogLevel = "debug"
+	err := platform.Reload(next)
+	if err == nil || errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), "auth: switching authentication off requires a restart") {
+		t.Fatalf("Expected the auth change alone to be rejected, got %v", err)
+	}
+	got := platform.getConfig()
+	if !got.Auth.Enabled() || got.LogLevel != "debug" {
+		t.Errorf("Expected the keys to be kept and the rest applied, got %+v", got)
+	}
+
+	// Replacing the keys is applied
+	_, other := testAPIKey(t, "desk2")
+	next.Auth.APIKeys.Keys = []APIKey{other}
+	if err := platform.Reload(next); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if keys := platform.getConfig().Auth.APIKeys.Keys; len(keys) != 1 || keys[0].ID != "desk2" {
+		t.Errorf("Expected the new keys, got %+v", keys)
+	}
+}
+
+func TestAdminConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.Endpoints = map[string]ConfigSection{"date": {"interval": "2s"}}
+	platform := New(config)
+	platform.SetAuthenticator(roleAuthenticator{})
+
+	rr := httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, adminRequest(http.MethodGet, "/admin/config"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var response struct {
+		Data Config `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if response.Data.Port != "8080" || response.Data.Endpoints["date"]["interval"] != "2s" {
+		t.Errorf("Unexpected effective config: %+v", response.Data)
+	}
+
+	rr = httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, adminRequest(http.MethodPost, "/admin/config"))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
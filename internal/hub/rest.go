@@ -0,0 +1,161 @@
+package hub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errRESTAbandoned is returned to an endpoint writing after its REST call timed out
+var errRESTAbandoned = errors.New("hub: the REST call timed out")
+
+// RESTConfig represents how the hub answers REST calls
+type RESTConfig struct {
+	Timeout time.Duration `json:"timeout"` // Default: 10s; calls the endpoint has not answered by then get 504
+}
+
+// validate checks the REST settings
+func (c RESTConfig) validate() []error {
+	if c.Timeout <= 0 {
+		return []error{fmt.Errorf("rest.timeout: %s must be positive", c.Timeout)}
+	}
+	return nil
+}
+
+// getREST returns the current REST settings. A hub whose configuration was never
+// validated has no timeout, which gets the default instead of failing every call.
+func (p *Hub) getREST() RESTConfig {
+	p.mu.RLock()
+	config := p.config.REST
+	p.mu.RUnlock()
+
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultConfig().REST.Timeout
+	}
+	return config
+}
+
+// restCompletionKey is the context key of a restCompletion
+type restCompletionKey struct{}
+
+// restCompletion tells a middleware when an endpoint abandoned by its REST call returns,
+// since it may still take effect after the client got its 504
+type restCompletion struct {
+	mu       sync.Mutex
+	returned chan struct{}
+}
+
+// contextWithRESTCompletion returns a context in which restHandler reports to c
+func contextWithRESTCompletion(ctx context.Context, c *restCompletion) context.Context {
+	return context.WithValue(ctx, restCompletionKey{}, c)
+}
+
+// abandoned records that the endpoint is still running; done receives once it returns
+func (c *restCompletion) abandoned(done <-chan bool) {
+	returned := make(chan struct{})
+	go func() {
+		<-done
+		close(returned)
+	}()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.returned = returned
+}
+
+// pending returns a channel closed once an abandoned endpoint returns, or nil if the
+// endpoint returned before its call was answered
+func (c *restCompletion) pending() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.returned
+}
+
+// WithListResponse declares that the endpoint answers a REST call with a list. Every write
+// is an item and the data of the response is an array of them, empty if nothing was
+// written. The call's max_count is passed on as the client sent it.
+func WithListResponse() EndpointOption {
+	return func(o *endpointOptions) {
+		o.list = true
+	}
+}
+
+// WithNoContent answers a REST call to which the endpoint wrote nothing with 204 No Content
+// rather than 404 Not Found
+func WithNoContent() EndpointOption {
+	return func(o *endpointOptions) {
+		o.noContent = true
+	}
+}
+
+// restRecorder captures the status, the headers and each write of an endpoint answering a
+// REST call. Once the call is abandoned, writes fail, since the response is already sent.
+type restRecorder struct {
+	mu        sync.Mutex
+	header    http.Header
+	code      int
+	writes    []*bytes.Buffer
+	abandoned bool
+}
+
+// newRESTRecorder creates a restRecorder
+func newRESTRecorder() *restRecorder {
+	return &restRecorder{header: make(http.Header), code: http.StatusOK}
+}
+
+// Header returns the header map of the response
+func (r *restRecorder) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader records the status of the response
+func (r *restRecorder) WriteHeader(statusCode int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.code = statusCode
+}
+
+// Write records one write of the endpoint. Empty writes are ignored.
+func (r *restRecorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.abandoned {
+		return 0, errRESTAbandoned
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	data := getBuffer()
+	data.Write(b)
+	r.writes = append(r.writes, data)
+	return len(b), nil
+}
+
+// abandon makes later writes fail. The buffers are left to the garbage collector, since the
+// endpoint may still hold the recorder.
+func (r *restRecorder) abandon() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.abandoned = true
+}
+
+// release returns the buffers of the writes to the pool, once the endpoint has returned
+func (r *restRecorder) release() {
+	for _, data := range r.writes {
+		putBuffer(data)
+	}
+	r.writes = nil
+}
+
+// payloads returns the writes of the endpoint
+func (r *restRecorder) payloads() []json.RawMessage {
+	payloads := make([]json.RawMessage, len(r.writes))
+	for i, data := range r.writes {
+		payloads[i] = data.Bytes()
+	}
+	return payloads
+}
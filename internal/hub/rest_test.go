@@ -0,0 +1,168 @@
+package hub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writesEndpoint writes each of its payloads, then returns the status of a last write
+type writesEndpoint struct {
+	status   int
+	payloads []string
+}
+
+// HandleSSE implements the Endpoint interface
+func (e writesEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	if e.status != 0 {
+		w.WriteHeader(e.status)
+	}
+	for _, payload := range e.payloads {
+		w.Write([]byte(payload))
+	}
+}
+
+// endpointFunc adapts a function to the Endpoint interface
+type endpointFunc func(w http.ResponseWriter, r *http.Request)
+
+// HandleSSE implements the Endpoint interface
+func (f endpointFunc) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	f(w, r)
+}
+
+// lateEndpoint writes once its request has ended and it is released, and reports the
+// result of the write
+type lateEndpoint struct {
+	release chan struct{}
+	errs    chan error
+}
+
+// HandleSSE implements the Endpoint interface
+func (e lateEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	<-r.Context().Done()
+	<-e.release
+	_, err := w.Write([]byte(`"late"`))
+	e.errs <- err
+}
+
+// restCall sends a REST request to a hub serving endpoint with opts
+func restCall(t *testing.T, config Config, endpoint Endpoint, target string, opts ...EndpointOption) *httptest.ResponseRecorder {
+	t.Helper()
+	platform := New(config)
+	platform.RegisterEndpoint("items", endpoint, opts...)
+	rr := httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+	return rr
+}
+
+func TestREST_Writes(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoint   writesEndpoint
+		target     string
+		opts       []EndpointOption
+		wantStatus int
+		wantBody   string
+	}{
+		{"first write", writesEndpoint{payloads: []string{`{"n":1}`, `{"n":2}`}}, "/items", nil,
+			http.StatusOK, `{"data":{"n":1}}` + "\n"},
+		{"empty writes ignored", writesEndpoint{payloads: []string{``, `"ok"`}}, "/items", nil,
+			http.StatusOK, `{"data":"ok"}` + "\n"},
+		{"list", writesEndpoint{payloads: []string{`{"n":1}`, `text`, `{"n":3}`}}, "/items", []EndpointOption{WithListResponse()},
+			http.StatusOK, `{"data":[{"n":1},"text",{"n":3}]}` + "\n"},
+		{"empty list", writesEndpoint{}, "/items", []EndpointOption{WithListResponse()},
+			http.StatusOK, `{"data":[]}` + "\n"},
+		{"no content", writesEndpoint{}, "/items", []EndpointOption{WithNoContent()},
+			http.StatusNoContent, ``},
+		{"error passed on", writesEndpoint{status: http.StatusConflict, payloads: []string{`{"errors":`, `[]}`}}, "/items", nil,
+			http.StatusConflict, `{"errors":[]}`},
+	}
+	for _, tt := range tests {
+		rr := restCall(t, DefaultConfig(), tt.endpoint, tt.target, tt.opts...)
+		if rr.Code != tt.wantStatus || rr.Body.String() != tt.wantBody {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.name, tt.wantStatus, tt.wantBody, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestREST_NoOutput(t *testing.T) {
+	rr := restCall(t, DefaultConfig(), writesEndpoint{}, "/items")
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+	errs := decodeErrors(t, rr).Errors
+	if len(errs) != 1 || errs[0].Detail != "the endpoint returned no data" {
+		t.Errorf("Unexpected errors %+v", errs)
+	}
+}
+
+func TestREST_MaxCount(t *testing.T) {
+	// A list endpoint sees the client's max_count; others always get 1
+	for _, tt := range []struct {
+		opts []EndpointOption
+		want string
+	}{
+		{nil, "1"},
+		{[]EndpointOption{WithListResponse()}, "7"},
+	} {
+		var got string
+		endpoint := endpointFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.URL.Query().Get("max_count")
+			w.Write([]byte(`1`))
+		})
+		restCall(t, DefaultConfig(), endpoint, "/items?max_count=7", tt.opts...)
+		if got != tt.want {
+			t.Errorf("Expected max_count %s, got %s", tt.want, got)
+		}
+	}
+}
+
+func TestREST_Deadline(t *testing.T) {
+	config := DefaultConfig()
+	config.REST.Timeout = 50 * time.Millisecond
+	endpoint := lateEndpoint{release: make(chan struct{}), errs: make(chan error, 1)}
+	logs := captureLogs(t)
+
+	rr := restCall(t, config, endpoint, "/items")
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected status %d, got %d", http.StatusGatewayTimeout, rr.Code)
+	}
+	errs := decodeErrors(t, rr).Errors
+	if len(errs) != 1 || errs[0].Detail != "the endpoint did not respond within 50ms" {
+		t.Errorf("Unexpected errors %+v", errs)
+	}
+	if !strings.Contains(logs.String(), "Endpoint missed the REST deadline") {
+		t.Errorf("Expected the timeout to be logged, got %s", logs.String())
+	}
+
+	// The endpoint's context ends with the deadline, and writing afterwards fails
+	close(endpoint.release)
+	select {
+	case err := <-endpoint.errs:
+		if err != errRESTAbandoned {
+			t.Errorf("Expected errRESTAbandoned, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the endpoint's context to end")
+	}
+}
+
+func TestREST_ZeroTimeout(t *testing.T) {
+	// A hub used without validating its configuration waits for the default timeout
+	config := DefaultConfig()
+	config.REST = RESTConfig{}
+	rr := restCall(t, config, writesEndpoint{payloads: []string{`"ok"`}}, "/items")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+	}
+}
+
+func TestRESTConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	config.REST.Timeout = 0
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "rest.timeout") {
+		t.Errorf("Expected a zero timeout to be rejected, got %v", err)
+	}
+}
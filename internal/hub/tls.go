@@ -0,0 +1,216 @@
+package hub
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync/atomic"
+)
+
+// TLSConfig represents the TLS termination settings. TLS is enabled when CertFile is set.
+type TLSConfig struct {
+	CertFile     string `json:"cert_file"`      // PEM certificate chain
+	KeyFile      string `json:"key_file"`       // PEM private key
+	MinVersion   string `json:"min_version"`    // Default: "1.2" (1.2, 1.3)
+	CipherPolicy string `json:"cipher_policy"`  // Default: "modern" (modern, default)
+	ClientCAFile string `json:"client_ca_file"` // PEM bundle of CAs trusted for client certificates
+	ClientAuth   string `json:"client_auth"`    // Default: "none" (none, optional, require)
+}
+
+// Enabled reports whether TLS termination is configured
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// validate checks the TLS settings without touching the filesystem
+func (c TLSConfig) validate() []error {
+	var errs []error
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		errs = append(errs, errors.New("tls: cert_file and key_file must be set together"))
+	}
+	if _, ok := tlsVersions[c.MinVersion]; !ok {
+		errs = append(errs, fmt.Errorf("tls.min_version: %q must be one of 1.2, 1.3", c.MinVersion))
+	}
+	if c.CipherPolicy != "modern" && c.CipherPolicy != "default" {
+		errs = append(errs, fmt.Errorf("tls.cipher_policy: %q must be one of modern, default", c.CipherPolicy))
+	}
+	switch c.ClientAuth {
+	case "none":
+	case "optional", "require":
+		if !c.Enabled() {
+			errs = append(errs, errors.New("tls.client_auth: client certificates require cert_file and key_file"))
+		}
+		if c.ClientCAFile == "" {
+			errs = append(errs, errors.New("tls.client_auth: client certificates require client_ca_file"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tls.client_auth: %q must be one of none, optional, require", c.ClientAuth))
+	}
+	return errs
+}
+
+// tlsVersions maps configuration values to TLS versions
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// modernCipherSuites are the TLS 1.2 suites allowed by the "modern" policy: forward secret AEAD only.
+// TLS 1.3 suites are not configurable and are always secure.
+var modernCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+}
+
+// tlsManager holds the live TLS configuration so certificates can be replaced without a restart
+type tlsManager struct {
+	current atomic.Pointer[tls.Config]
+	config  atomic.Pointer[TLSConfig]
+}
+
+// newTLSManager loads the certificates described by config
+func newTLSManager(config TLSConfig) (*tlsManager, error) {
+	m := &tlsManager{}
+	if err := m.update(config); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// update loads certificates for config and swaps them in. On error the previous ones stay active.
+func (m *tlsManager) update(config TLSConfig) error {
+	tlsConfig, err := buildTLSConfig(config)
+	if err != nil {
+		return err
+	}
+	m.current.Store(tlsConfig)
+	m.config.Store(&config)
+	return nil
+}
+
+// reload reads the certificate, key and CA files again, e.g. after they were rotated on disk
+func (m *tlsManager) reload() error {
+	return m.update(*m.config.Load())
+}
+
+// serverConfig returns the tls.Config to install on the http.Server.
+// Every handshake uses the most recently loaded configuration.
+func (m *tlsManager) serverConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return m.current.Load(), nil
+		},
+	}
+}
+
+// buildTLSConfig creates a tls.Config from the settings and the files they point to
+func buildTLSConfig(config TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates:     []tls.Certificate{cert},
+		MinVersion:       tlsVersions[config.MinVersion],
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		NextProtos:       []string{"h2", "http/1.1"},
+		ClientAuth:       tls.NoClientCert,
+	}
+	if config.CipherPolicy == "modern" {
+		tlsConfig.CipherSuites = modernCipherSuites
+	}
+
+	if config.ClientAuth != "none" {
+		pem, err := os.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("loading client CA bundle: no certificates found in %s", config.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+
+		if config.ClientAuth == "require" {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		} else {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// ReloadCertificates reads the TLS certificate, key and client CA files again.
+// Existing connections keep their certificates; new handshakes use the new ones.
+func (p *Hub) ReloadCertificates() error {
+	p.mu.RLock()
+	manager := p.tls
+	p.mu.RUnlock()
+
+	if manager == nil {
+		return nil
+	}
+	if err := manager.reload(); err != nil {
+		slog.Error("Error reloading TLS certificates, keeping the current ones", "error", err)
+		return err
+	}
+	slog.Info("TLS certificates reloaded")
+	return nil
+}
+
+// ClientIdentity describes a client authenticated with a verified TLS certificate
+type ClientIdentity struct {
+	CommonName   string   `json:"common_name"`
+	Organization []string `json:"organization,omitempty"`
+	DNSNames     []string `json:"dns_names,omitempty"`
+	URIs         []string `json:"uris,omitempty"`
+	SerialNumber string   `json:"serial_number"`
+	Fingerprint  string   `json:"fingerprint"` // SHA-256 of the DER certificate, hex encoded
+}
+
+// clientIdentityKey is the context key for the verified client identity
+type clientIdentityKey struct{}
+
+// ClientIdentityFromContext returns the identity of the client's verified TLS certificate, if any
+func ClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	identity, ok := ctx.Value(clientIdentityKey{}).(ClientIdentity)
+	return identity, ok
+}
+
+// withClientIdentity exposes the verified client certificate to endpoints through the request context
+func withClientIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			cert := r.TLS.VerifiedChains[0][0]
+			fingerprint := sha256.Sum256(cert.Raw)
+
+			identity := ClientIdentity{
+				CommonName:   cert.Subject.CommonName,
+				Organization: cert.Subject.Organization,
+				DNSNames:     cert.DNSNames,
+				SerialNumber: cert.SerialNumber.String(),
+				Fingerprint:  hex.EncodeToString(fingerprint[:]),
+			}
+			for _, uri := range cert.URIs {
+				identity.URIs = append(identity.URIs, uri.String())
+			}
+			r = r.WithContext(context.WithValue(r.Context(), clientIdentityKey{}, identity))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
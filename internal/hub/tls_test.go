@@ -0,0 +1,286 @@
+package hub
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA is a throwaway certificate authority for TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newTestCA creates a self-signed CA
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parsing CA certificate: %v", err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue creates a leaf certificate signed by the CA and returns its PEM certificate and key
+func (ca *testCA) issue(t *testing.T, commonName string, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Trading"}},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Error encoding key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile writes data to a file in dir and returns its path
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Error writing %s: %v", name, err)
+	}
+	return path
+}
+
+// identityEndpoint writes the verified client identity it sees
+type identityEndpoint struct{}
+
+// HandleSSE implements the Endpoint interface
+func (identityEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	identity, ok := ClientIdentityFromContext(r.Context())
+	if !ok {
+		w.Write([]byte(`"anonymous"`))
+		return
+	}
+	data, _ := json.Marshal(identity.CommonName)
+	w.Write(data)
+}
+
+// tlsTestServer starts the hub handler behind TLS terminated by the hub's tlsManager
+func tlsTestServer(t *testing.T, config TLSConfig) (*httptest.Server, *tlsManager) {
+	t.Helper()
+	manager, err := newTLSManager(config)
+	if err != nil {
+		t.Fatalf("Error loading TLS config: %v", err)
+	}
+	platform := New(DefaultConfig())
+	platform.RegisterEndpoint("whoami", identityEndpoint{})
+
+	server := httptest.NewUnstartedServer(platform.Handler())
+	server.TLS = manager.serverConfig()
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server, manager
+}
+
+// tlsClient returns a client trusting the CA and optionally presenting a client certificate
+func tlsClient(t *testing.T, ca *testCA, certPEM, keyPEM []byte) *http.Client {
+	t.Helper()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	config := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	if certPEM != nil {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			t.Fatalf("Error loading client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+}
+
+func TestTLS_MutualAuthentication(t *testing.T) {
+	dir := t.TempDir()
+	serverCA := newTestCA(t, "server-ca")
+	clientCA := newTestCA(t, "client-ca")
+	serverCert, serverKey := serverCA.issue(t, "hub", 2, x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := clientCA.issue(t, "desk-7", 3, x509.ExtKeyUsageClientAuth)
+
+	server, _ := tlsTestServer(t, TLSConfig{
+		CertFile:     writeFile(t, dir, "server.pem", serverCert),
+		KeyFile:      writeFile(t, dir, "server-key.pem", serverKey),
+		MinVersion:   "1.2",
+		CipherPolicy: "modern",
+		ClientCAFile: writeFile(t, dir, "client-ca.pem", clientCA.pem),
+		ClientAuth:   "require",
+	})
+
+	resp, err := tlsClient(t, serverCA, clientCert, clientKey).Get(server.URL + "/whoami")
+	if err != nil {
+		t.Fatalf("Error making request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Data string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if response.Data != "desk-7" {
+		t.Errorf("Expected client identity desk-7, got %q", response.Data)
+	}
+
+	// Without a client certificate the handshake fails
+	if _, err := tlsClient(t, serverCA, nil, nil).Get(server.URL + "/whoami"); err == nil {
+		t.Error("Expected the handshake to fail without a client certificate")
+	}
+
+	// A certificate from an untrusted CA is rejected
+	otherCert, otherKey := newTestCA(t, "other-ca").issue(t, "intruder", 4, x509.ExtKeyUsageClientAuth)
+	if _, err := tlsClient(t, serverCA, otherCert, otherKey).Get(server.URL + "/whoami"); err == nil {
+		t.Error("Expected the handshake to fail with an untrusted client certificate")
+	}
+}
+
+func TestTLS_MinimumVersion(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "ca")
+	cert, key := ca.issue(t, "hub", 2, x509.ExtKeyUsageServerAuth)
+
+	server, _ := tlsTestServer(t, TLSConfig{
+		CertFile:     writeFile(t, dir, "server.pem", cert),
+		KeyFile:      writeFile(t, dir, "server-key.pem", key),
+		MinVersion:   "1.3",
+		CipherPolicy: "modern",
+		ClientAuth:   "none",
+	})
+
+	client := tlsClient(t, ca, nil, nil)
+	client.Transport.(*http.Transport).TLSClientConfig.MaxVersion = tls.VersionTLS12
+	if _, err := client.Get(server.URL + "/whoami"); err == nil {
+		t.Error("Expected a TLS 1.2 client to be rejected")
+	}
+
+	resp, err := tlsClient(t, ca, nil, nil).Get(server.URL + "/whoami")
+	if err != nil {
+		t.Fatalf("Error making request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.TLS.Version != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3, got %x", resp.TLS.Version)
+	}
+}
+
+func TestTLS_ReloadCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "ca")
+	cert, key := ca.issue(t, "hub", 10, x509.ExtKeyUsageServerAuth)
+	certFile := writeFile(t, dir, "server.pem", cert)
+	keyFile := writeFile(t, dir, "server-key.pem", key)
+
+	server, manager := tlsTestServer(t, TLSConfig{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		MinVersion:   "1.2",
+		CipherPolicy: "modern",
+		ClientAuth:   "none",
+	})
+
+	serial := func() int64 {
+		t.Helper()
+		client := tlsClient(t, ca, nil, nil)
+		defer client.CloseIdleConnections()
+		resp, err := client.Get(server.URL + "/whoami")
+		if err != nil {
+			t.Fatalf("Error making request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if got := serial(); got != 10 {
+		t.Fatalf("Expected serial 10, got %d", got)
+	}
+
+	// Rotate the certificate on disk and reload
+	cert, key = ca.issue(t, "hub", 11, x509.ExtKeyUsageServerAuth)
+	writeFile(t, dir, "server.pem", cert)
+	writeFile(t, dir, "server-key.pem", key)
+	if err := manager.reload(); err != nil {
+		t.Fatalf("Error reloading certificates: %v", err)
+	}
+	if got := serial(); got != 11 {
+		t.Errorf("Expected serial 11 after reload, got %d", got)
+	}
+
+	// A broken file keeps the current certificate
+	writeFile(t, dir, "server.pem", []byte("not a certificate"))
+	if err := manager.reload(); err == nil {
+		t.Error("Expected reloading a broken certificate to fail")
+	}
+	if got := serial(); got != 11 {
+		t.Errorf("Expected serial 11 to stay active, got %d", got)
+	}
+}
+
+func TestTLSConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	config.TLS = TLSConfig{
+		CertFile:     "server.pem",
+		MinVersion:   "1.0",
+		CipherPolicy: "legacy",
+		ClientAuth:   "require",
+	}
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"cert_file and key_file", "tls.min_version", "tls.cipher_policy", "client_ca_file"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+}
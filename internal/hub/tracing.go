@@ -0,0 +1,59 @@
+package hub
+
+import (
+	"context"
+	"net/http"
+
+	"trading/internal/trace"
+)
+
+// SetTracer sets the tracer used to create spans for REST requests and SSE events.
+// Without a tracer no spans are recorded, but incoming trace context is still propagated
+// to endpoints so that their outgoing calls stay in the caller's trace.
+func (p *Hub) SetTracer(tracer *trace.Tracer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tracer = tracer
+}
+
+// getTracer returns the current tracer, which may be nil
+func (p *Hub) getTracer() *trace.Tracer {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.tracer
+}
+
+// startRequestSpan extracts the W3C trace context from the request headers and starts
+// a server span for it. The returned request carries the span in its context.
+func (p *Hub) startRequestSpan(r *http.Request, name, endpointName string, maxCount int) (*http.Request, *trace.Span) {
+	ctx := r.Context()
+	if sc, ok := trace.Extract(r.Header); ok {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, sc)
+	}
+
+	ctx, span := p.getTracer().Start(ctx, name, trace.SpanKindServer)
+	span.SetAttribute("hub.endpoint", endpointName)
+	span.SetAttribute("hub.max_count", maxCount)
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.route", r.URL.Path)
+
+	return r.WithContext(ctx), span
+}
+
+// startEventSpan starts an internal span for the emission of a single SSE event
+func (p *Hub) startEventSpan(ctx context.Context, endpointName string, maxCount, index int) *trace.Span {
+	_, span := p.getTracer().Start(ctx, "sse.event", trace.SpanKindInternal)
+	span.SetAttribute("hub.endpoint", endpointName)
+	span.SetAttribute("hub.max_count", maxCount)
+	span.SetAttribute("hub.event_index", index)
+	return span
+}
+
+// endSpanWithStatus records the HTTP status on a span and ends it
+func endSpanWithStatus(span *trace.Span, status int) {
+	span.SetAttribute("http.status_code", status)
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(trace.StatusError, http.StatusText(status))
+	}
+	span.End()
+}
@@ -0,0 +1,140 @@
+package hub
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"trading/internal/trace"
+)
+
+// spanRecorder is a trace.Exporter that keeps exported spans in memory
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []trace.SpanData
+}
+
+// ExportSpans implements trace.Exporter
+func (s *spanRecorder) ExportSpans(ctx context.Context, spans []trace.SpanData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spans = append(s.spans, spans...)
+	return nil
+}
+
+// Shutdown implements trace.Exporter
+func (s *spanRecorder) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// traceEndpoint records the span context it sees while handling a request
+type traceEndpoint struct {
+	events int
+	mu     sync.Mutex
+	seen   trace.SpanContext
+}
+
+// HandleSSE implements the Endpoint interface
+func (e *traceEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	e.seen = trace.SpanContextFromContext(r.Context())
+	e.mu.Unlock()
+
+	for i := 0; i < e.events; i++ {
+		w.Write([]byte(`{"n":1}`))
+	}
+}
+
+func TestTracing_RESTSpanContinuesCallerTrace(t *testing.T) {
+	recorder := &spanRecorder{}
+	tracer := trace.NewTracer(recorder, trace.Config{})
+
+	platform := New(DefaultConfig())
+	platform.SetTracer(tracer)
+	endpoint := &traceEndpoint{events: 1}
+	platform.RegisterEndpoint("test", endpoint)
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("tracestate", "rojo=00f067aa0ba902b7")
+	rr := httptest.NewRecorder()
+	platform.Handler().ServeHTTP(rr, req)
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Error shutting down tracer: %v", err)
+	}
+
+	if endpoint.seen.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected endpoint to see the caller's trace, got %s", endpoint.seen.TraceID)
+	}
+	if endpoint.seen.TraceState != "rojo=00f067aa0ba902b7" {
+		t.Errorf("Expected endpoint to see tracestate, got %q", endpoint.seen.TraceState)
+	}
+
+	if len(recorder.spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(recorder.spans))
+	}
+	span := recorder.spans[0]
+	if span.Name != "GET /test" || span.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Unexpected span: %+v", span)
+	}
+	if span.Attributes["hub.endpoint"] != "test" || span.Attributes["hub.max_count"] != 1 {
+		t.Errorf("Unexpected attributes: %v", span.Attributes)
+	}
+	if span.Attributes["http.status_code"] != http.StatusOK {
+		t.Errorf("Expected status code attribute, got %v", span.Attributes["http.status_code"])
+	}
+}
+
+func TestTracing_SSEEventSpans(t *testing.T) {
+	recorder := &spanRecorder{}
+	tracer := trace.NewTracer(recorder, trace.Config{})
+
+	platform := New(DefaultConfig())
+	platform.SetTracer(tracer)
+	platform.RegisterEndpoint("test", &traceEndpoint{events: 3})
+
+	server := httptest.NewServer(platform.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/test/stream?max_count=3")
+	if err != nil {
+		t.Fatalf("Error making request: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	// The stream span ends after the handler returns, which may be after the body is read
+	server.Close()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Error shutting down tracer: %v", err)
+	}
+
+	var stream trace.SpanData
+	var events []trace.SpanData
+	for _, span := range recorder.spans {
+		if span.Name == "sse.event" {
+			events = append(events, span)
+		} else {
+			stream = span
+		}
+	}
+
+	if stream.Name != "GET /test/stream" || stream.Attributes["hub.max_count"] != 3 {
+		t.Fatalf("Unexpected stream span: %+v", stream)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 event spans, got %d", len(events))
+	}
+	for i, event := range events {
+		if event.ParentSpanID != stream.SpanID || event.TraceID != stream.TraceID {
+			t.Errorf("Event span %d is not a child of the stream span", i)
+		}
+		if event.Attributes["hub.event_index"] != i || event.Attributes["hub.endpoint"] != "test" {
+			t.Errorf("Unexpected event attributes: %v", event.Attributes)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package loadgen
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EndpointConfig represents the events of the synthetic endpoint
+type EndpointConfig struct {
+	Interval time.Duration // Default: 100ms; time between events
+	Size     int           // Default: 128; approximate size of an event in bytes
+}
+
+// Endpoint implements the hub.Endpoint interface with events carrying the time they were
+// sent, from which Run measures the latency of the hub
+type Endpoint struct {
+	config EndpointConfig
+	pad    string
+}
+
+// NewEndpoint creates a synthetic Endpoint
+func NewEndpoint(config EndpointConfig) *Endpoint {
+	if config.Interval <= 0 {
+		config.Interval = 100 * time.Millisecond
+	}
+	if config.Size <= 0 {
+		config.Size = 128
+	}
+	// The fields other than the padding take about 50 bytes
+	return &Endpoint{config: config, pad: strings.Repeat("x", max(config.Size-50, 0))}
+}
+
+// HandleSSE sends an event at once, then one per interval until max_count events are sent
+// or the request ends
+func (e *Endpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	maxCount := -1
+	if n, err := strconv.Atoi(r.URL.Query().Get("max_count")); err == nil && n > 0 {
+		maxCount = n
+	}
+
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+	buf := make([]byte, 0, 64+len(e.pad))
+	for seq := 1; ; seq++ {
+		buf = e.appendEvent(buf[:0], seq, time.Now())
+		if _, err := w.Write(buf); err != nil || seq == maxCount {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// appendEvent appends the JSON of an event to buf
+func (e *Endpoint) appendEvent(buf []byte, seq int, sent time.Time) []byte {
+	buf = append(buf, `{"seq":`...)
+	buf = strconv.AppendInt(buf, int64(seq), 10)
+	buf = append(buf, `,"sent":`...)
+	buf = strconv.AppendInt(buf, sent.UnixNano(), 10)
+	buf = append(buf, `,"pad":"`...)
+	buf = append(buf, e.pad...)
+	return append(buf, `"}`...)
+}
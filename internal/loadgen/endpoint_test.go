@@ -0,0 +1,39 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEndpoint_HandleSSE(t *testing.T) {
+	e := NewEndpoint(EndpointConfig{Interval: time.Millisecond, Size: 200})
+
+	rr := httptest.NewRecorder()
+	e.HandleSSE(rr, httptest.NewRequest(http.MethodGet, "/bench/stream?max_count=3", nil))
+
+	// Events are concatenated by the recorder; split them on the closing brace
+	events := strings.SplitAfter(rr.Body.String(), "}")
+	events = events[:len(events)-1]
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d: %s", len(events), rr.Body.String())
+	}
+	for i, event := range events {
+		var decoded struct {
+			Seq  int   `json:"seq"`
+			Sent int64 `json:"sent"`
+		}
+		if err := json.Unmarshal([]byte(event), &decoded); err != nil {
+			t.Fatalf("Event %d is not JSON: %v", i, err)
+		}
+		if decoded.Seq != i+1 || time.Since(time.Unix(0, decoded.Sent)) > time.Minute {
+			t.Errorf("Unexpected event %+v", decoded)
+		}
+		if len(event) < 180 || len(event) > 220 {
+			t.Errorf("Expected about 200 bytes, got %d", len(event))
+		}
+	}
+}
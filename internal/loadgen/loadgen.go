@@ -0,0 +1,347 @@
+// Package loadgen puts a hub under load with concurrent stream and REST clients and
+// measures its throughput and event latency. It is used by cmd/hubbench and the hub's
+// benchmarks, and only depends on the standard library.
+package loadgen
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// openConcurrency bounds the streams being opened at once, so that thousands of clients
+// do not overflow the listen backlog
+const openConcurrency = 64
+
+// sentField precedes the send time of an event, in Unix nanoseconds
+var sentField = []byte(`"sent":`)
+
+// Config represents a load run
+type Config struct {
+	URL         string            // Required; base URL of the hub
+	Endpoint    string            // Required; endpoint under load
+	Query       url.Values        // Parameters of every request
+	Headers     map[string]string // Headers of every request, such as credentials
+	Streams     int               // Stream clients, opened before the measurement starts
+	RESTClients int               // REST clients, each calling the endpoint in a loop
+	Duration    time.Duration     // Required; length of the measurement
+	OnOpen      func()            // Called once the streams are open, before the measurement
+}
+
+// Percentiles represents a latency distribution
+type Percentiles struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// String formats the distribution on one line
+func (p Percentiles) String() string {
+	if p.Count == 0 {
+		return "n=0"
+	}
+	return fmt.Sprintf("n=%d p50=%s p90=%s p99=%s max=%s", p.Count, p.P50, p.P90, p.P99, p.Max)
+}
+
+// newPercentiles computes the distribution of samples, which it sorts
+func newPercentiles(samples []time.Duration) Percentiles {
+	if len(samples) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	at := func(p float64) time.Duration {
+		return samples[int(math.Ceil(p*float64(len(samples))))-1]
+	}
+	return Percentiles{Count: len(samples), P50: at(0.50), P90: at(0.90), P99: at(0.99), Max: samples[len(samples)-1]}
+}
+
+// Report represents the outcome of a run. Counts cover the measurement only.
+type Report struct {
+	Streams        int           `json:"streams"`        // Streams open when the measurement started
+	StreamErrors   int           `json:"stream_errors"`  // Streams that failed to open or ended early
+	Events         int           `json:"events"`         // Events received on all streams
+	Requests       int           `json:"requests"`       // Successful REST calls
+	RequestErrors  int           `json:"request_errors"` // Failed REST calls
+	Elapsed        time.Duration `json:"elapsed"`        // Length of the measurement
+	EventLatency   Percentiles   `json:"event_latency"`  // From the send time in the event to its receipt
+	RequestLatency Percentiles   `json:"request_latency"`
+}
+
+// EventRate returns the events received per second
+func (r *Report) EventRate() float64 {
+	return rate(r.Events, r.Elapsed)
+}
+
+// RequestRate returns the successful REST calls per second
+func (r *Report) RequestRate() float64 {
+	return rate(r.Requests, r.Elapsed)
+}
+
+// rate divides n by a duration in seconds
+func rate(n int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(n) / elapsed.Seconds()
+}
+
+// Usage represents the memory and goroutines of the process. It only describes the hub
+// when the hub runs in the same process, and then includes the clients of a run.
+type Usage struct {
+	Memory     uint64 `json:"memory"` // Bytes of heap and goroutine stacks in use
+	Goroutines int    `json:"goroutines"`
+}
+
+// ReadUsage collects garbage and reads the usage of the process
+func ReadUsage() Usage {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return Usage{Memory: stats.HeapInuse + stats.StackInuse, Goroutines: runtime.NumGoroutine()}
+}
+
+// PerStream divides the growth from before to u among n streams
+func (u Usage) PerStream(before Usage, n int) (memory float64, goroutines float64) {
+	if n <= 0 {
+		return 0, 0
+	}
+	return (float64(u.Memory) - float64(before.Memory)) / float64(n), float64(u.Goroutines-before.Goroutines) / float64(n)
+}
+
+// run holds the state shared by the clients of a run
+type run struct {
+	config    Config
+	client    *http.Client
+	measuring atomic.Bool
+
+	mu             sync.Mutex
+	report         Report
+	eventLatency   []time.Duration
+	requestLatency []time.Duration
+}
+
+// Run opens the streams, calls config.OnOpen, then measures for config.Duration while
+// the REST clients call the endpoint. It returns early with what was measured if ctx ends.
+func Run(ctx context.Context, config Config) (*Report, error) {
+	switch {
+	case config.URL == "":
+		return nil, errors.New("loadgen: url is required")
+	case config.Endpoint == "":
+		return nil, errors.New("loadgen: endpoint is required")
+	case config.Streams < 0 || config.RESTClients < 0 || config.Streams+config.RESTClients == 0:
+		return nil, errors.New("loadgen: at least one stream or REST client is required")
+	case config.Duration <= 0:
+		return nil, errors.New("loadgen: duration must be positive")
+	}
+
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConnsPerHost: config.RESTClients,
+	}
+	defer transport.CloseIdleConnections()
+	r := &run{config: config, client: &http.Client{Transport: transport}}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+
+	// Open the streams a few at a time; each reads until the run ends
+	opening := make(chan struct{}, openConcurrency)
+	var opened sync.WaitGroup
+	for i := 0; i < config.Streams; i++ {
+		wg.Add(1)
+		opened.Add(1)
+		opening <- struct{}{}
+		go func() {
+			defer wg.Done()
+			body, err := r.openStream(ctx)
+			<-opening
+			opened.Done()
+			if err != nil {
+				r.count(func(report *Report) { report.StreamErrors++ })
+				return
+			}
+			defer body.Close()
+			r.count(func(report *Report) { report.Streams++ })
+			r.readStream(ctx, body)
+		}()
+	}
+	opened.Wait()
+	if config.OnOpen != nil {
+		config.OnOpen()
+	}
+
+	// Measure
+	started := time.Now()
+	r.measuring.Store(true)
+	for i := 0; i < config.RESTClients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.callREST(ctx)
+		}()
+	}
+	timer := time.NewTimer(config.Duration)
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+	timer.Stop()
+	r.measuring.Store(false)
+	elapsed := time.Since(started)
+	cancel()
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := r.report
+	report.Elapsed = elapsed
+	report.EventLatency = newPercentiles(r.eventLatency)
+	report.RequestLatency = newPercentiles(r.requestLatency)
+	return &report, nil
+}
+
+// count updates the report
+func (r *run) count(update func(*Report)) {
+	r.mu.Lock()
+	update(&r.report)
+	r.mu.Unlock()
+}
+
+// newRequest creates a request to path with the configured parameters and headers
+func (r *run) newRequest(ctx context.Context, path string) (*http.Request, error) {
+	target := strings.TrimSuffix(r.config.URL, "/") + "/" + path
+	if len(r.config.Query) > 0 {
+		target += "?" + r.config.Query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range r.config.Headers {
+		req.Header.Set(key, value)
+	}
+	return req, nil
+}
+
+// openStream connects a stream client. The hub answers with the first event.
+func (r *run) openStream(ctx context.Context) (io.ReadCloser, error) {
+	req, err := r.newRequest(ctx, r.config.Endpoint+"/stream")
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// readStream counts the events of a stream until it ends, measuring their latency while
+// the run is measuring
+func (r *run) readStream(ctx context.Context, body io.Reader) {
+	var events int
+	var latencies []time.Duration
+	defer func() {
+		r.mu.Lock()
+		r.report.Events += events
+		r.eventLatency = append(r.eventLatency, latencies...)
+		// A stream ending on its own during the measurement was dropped
+		if ctx.Err() == nil && r.measuring.Load() {
+			r.report.StreamErrors++
+		}
+		r.mu.Unlock()
+	}()
+
+	reader := bufio.NewReaderSize(body, 32<<10)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return
+		}
+		if !bytes.HasPrefix(line, []byte("data:")) || !r.measuring.Load() {
+			continue
+		}
+		received := time.Now()
+		events++
+		if sent, ok := sentTime(line); ok {
+			latencies = append(latencies, received.Sub(sent))
+		}
+	}
+}
+
+// sentTime reads the send time from the data of an event
+func sentTime(data []byte) (time.Time, bool) {
+	i := bytes.Index(data, sentField)
+	if i < 0 {
+		return time.Time{}, false
+	}
+	digits := data[i+len(sentField):]
+	end := 0
+	for end < len(digits) && digits[end] >= '0' && digits[end] <= '9' {
+		end++
+	}
+	nanos, err := strconv.ParseInt(string(digits[:end]), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
+
+// callREST calls the endpoint in a loop until the run ends
+func (r *run) callREST(ctx context.Context) {
+	var requests, failures int
+	var latencies []time.Duration
+	defer func() {
+		r.mu.Lock()
+		r.report.Requests += requests
+		r.report.RequestErrors += failures
+		r.requestLatency = append(r.requestLatency, latencies...)
+		r.mu.Unlock()
+	}()
+
+	for ctx.Err() == nil {
+		req, err := r.newRequest(ctx, r.config.Endpoint)
+		if err != nil {
+			failures++
+			return
+		}
+		started := time.Now()
+		resp, err := r.client.Do(req)
+		if err == nil {
+			// Drain the body so that the connection is reused
+			_, err = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		latency := time.Since(started)
+		if !r.measuring.Load() {
+			return
+		}
+		if err != nil || resp.StatusCode >= 400 {
+			failures++
+			continue
+		}
+		requests++
+		latencies = append(latencies, latency)
+	}
+}
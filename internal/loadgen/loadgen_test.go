@@ -0,0 +1,123 @@
+package loadgen
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"trading/internal/hub"
+	"trading/internal/hub/hubtest"
+)
+
+// newHub serves the synthetic endpoint as "bench" without stream quotas
+func newHub(t *testing.T) *hubtest.Server {
+	config := hub.DefaultConfig()
+	config.LogLevel = "error"
+	config.Limits.StreamsPerClient = 0
+	return hubtest.NewServer(t,
+		hubtest.WithConfig(config),
+		hubtest.WithEndpoint("bench", NewEndpoint(EndpointConfig{Interval: 10 * time.Millisecond})),
+	)
+}
+
+func TestRun(t *testing.T) {
+	server := newHub(t)
+
+	opened := false
+	report, err := Run(context.Background(), Config{
+		URL:         server.URL,
+		Endpoint:    "bench",
+		Streams:     20,
+		RESTClients: 2,
+		Duration:    300 * time.Millisecond,
+		OnOpen:      func() { opened = true },
+	})
+	if err != nil {
+		t.Fatalf("Error running: %v", err)
+	}
+	if !opened {
+		t.Error("Expected OnOpen to be called")
+	}
+	if report.Streams != 20 || report.StreamErrors != 0 || report.RequestErrors != 0 {
+		t.Errorf("Expected 20 healthy streams and no failed calls, got %+v", report)
+	}
+	// Each stream gets an event every 10ms
+	if report.Events < 20*10 || report.EventLatency.Count != report.Events {
+		t.Errorf("Expected every event to be measured, got %d events and %v", report.Events, report.EventLatency)
+	}
+	if report.Requests == 0 || report.RequestLatency.Count != report.Requests {
+		t.Errorf("Expected every call to be measured, got %d calls and %v", report.Requests, report.RequestLatency)
+	}
+	if report.EventRate() <= 0 || report.RequestRate() <= 0 {
+		t.Errorf("Expected positive rates, got %v and %v", report.EventRate(), report.RequestRate())
+	}
+}
+
+func TestRun_Failures(t *testing.T) {
+	server := newHub(t)
+
+	report, err := Run(context.Background(), Config{
+		URL:         server.URL,
+		Endpoint:    "missing",
+		Streams:     3,
+		RESTClients: 1,
+		Duration:    50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Error running: %v", err)
+	}
+	if report.Streams != 0 || report.StreamErrors != 3 || report.Requests != 0 || report.RequestErrors == 0 {
+		t.Errorf("Expected every client to fail, got %+v", report)
+	}
+}
+
+func TestRun_Config(t *testing.T) {
+	for _, config := range []Config{
+		{Endpoint: "bench", Streams: 1, Duration: time.Second},
+		{URL: "http://hub", Streams: 1, Duration: time.Second},
+		{URL: "http://hub", Endpoint: "bench", Duration: time.Second},
+		{URL: "http://hub", Endpoint: "bench", Streams: 1},
+	} {
+		if _, err := Run(context.Background(), config); err == nil {
+			t.Errorf("Expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestNewPercentiles(t *testing.T) {
+	var samples []time.Duration
+	for i := 100; i >= 1; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	got := newPercentiles(samples)
+	want := Percentiles{Count: 100, P50: 50 * time.Millisecond, P90: 90 * time.Millisecond, P99: 99 * time.Millisecond, Max: 100 * time.Millisecond}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	if (newPercentiles(nil) != Percentiles{}) {
+		t.Error("Expected no samples to give an empty distribution")
+	}
+}
+
+func TestSentTime(t *testing.T) {
+	if got, ok := sentTime([]byte(`data: {"data":{"seq":1,"sent":1700000000123456789,"pad":""}}`)); !ok || got.UnixNano() != 1700000000123456789 {
+		t.Errorf("Expected the send time, got %v, %v", got, ok)
+	}
+	if _, ok := sentTime([]byte(`data: {"data":{"seq":1}}`)); ok {
+		t.Error("Expected no send time")
+	}
+}
+
+func TestUsage_PerStream(t *testing.T) {
+	before := Usage{Memory: 1000, Goroutines: 10}
+	after := Usage{Memory: 5000, Goroutines: 30}
+	if memory, goroutines := after.PerStream(before, 10); memory != 400 || goroutines != 2 {
+		t.Errorf("Expected 400 bytes and 2 goroutines per stream, got %v and %v", memory, goroutines)
+	}
+	if memory, goroutines := after.PerStream(before, 0); memory != 0 || goroutines != 0 {
+		t.Errorf("Expected nothing without streams, got %v and %v", memory, goroutines)
+	}
+	if usage := ReadUsage(); usage.Memory == 0 || usage.Goroutines == 0 {
+		t.Errorf("Expected the usage of the process, got %+v", usage)
+	}
+}
@@ -0,0 +1,167 @@
+// Package recording stores the events of a stream in a JSON Lines file, so that a stream
+// can be replayed later. The first line is a Header describing the request; every other
+// line is an Event with the time it was sent.
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Version is the format version written in every header
+const Version = 1
+
+// maxLine bounds the length of one line of a recording
+const maxLine = 4 << 20
+
+// fileTimeFormat is the timestamp in the name of a recording; it sorts chronologically
+const fileTimeFormat = "20060102T150405.000000000Z"
+
+// Header represents the first line of a recording
+type Header struct {
+	Version  int                 `json:"version"`
+	Endpoint string              `json:"endpoint"`
+	Query    map[string][]string `json:"query,omitempty"` // The request parameters
+	Started  time.Time           `json:"started"`
+}
+
+// Event represents one event of a recording. Data holds JSON payloads as they were
+// written; other payloads are kept in Text.
+type Event struct {
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data,omitempty"`
+	Text string          `json:"text,omitempty"`
+}
+
+// NewEvent creates the event of a payload written at t
+func NewEvent(t time.Time, payload []byte) Event {
+	if json.Valid(payload) {
+		return Event{Time: t, Data: append(json.RawMessage{}, payload...)}
+	}
+	return Event{Time: t, Text: string(payload)}
+}
+
+// Payload returns the payload as the endpoint wrote it
+func (e Event) Payload() []byte {
+	if e.Data != nil {
+		return e.Data
+	}
+	return []byte(e.Text)
+}
+
+// Writer appends the events of one stream to a recording. It is safe for concurrent use.
+type Writer struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// Create starts a recording in dir, named after the endpoint and the start of the stream,
+// and writes its header
+func Create(dir string, header Header) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("creating recording directory: %w", err)
+	}
+	header.Version = Version
+
+	// Streams starting at the same instant get a numbered name
+	base := filepath.Join(dir, header.Endpoint+"-"+header.Started.UTC().Format(fileTimeFormat))
+	path := base + ".jsonl"
+	var file *os.File
+	var err error
+	for n := 2; ; n++ {
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+		if !errors.Is(err, os.ErrExist) {
+			break
+		}
+		path = fmt.Sprintf("%s-%d.jsonl", base, n)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating recording: %w", err)
+	}
+
+	w := &Writer{file: file, encoder: json.NewEncoder(file)}
+	if err := w.encoder.Encode(header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("writing recording header: %w", err)
+	}
+	return w, nil
+}
+
+// Name returns the path of the recording
+func (w *Writer) Name() string {
+	return w.file.Name()
+}
+
+// Write appends an event. Each event is written at once, so that a recording survives a crash.
+func (w *Writer) Write(event Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.encoder.Encode(event); err != nil {
+		return fmt.Errorf("writing recording: %w", err)
+	}
+	return nil
+}
+
+// Close closes the recording
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// Recording represents a recording read back from a file
+type Recording struct {
+	Header Header
+	Events []Event
+}
+
+// Read loads the recording at path. A last line cut short, as left by a crash, is ignored.
+func Read(path string) (*Recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), maxLine)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return nil, fmt.Errorf("%s: empty recording", path)
+	}
+
+	var recording Recording
+	if err := json.Unmarshal(scanner.Bytes(), &recording.Header); err != nil {
+		return nil, fmt.Errorf("%s: invalid header: %w", path, err)
+	}
+	if recording.Header.Version != Version {
+		return nil, fmt.Errorf("%s: unsupported version %d", path, recording.Header.Version)
+	}
+
+	var pending error
+	for line := 2; scanner.Scan(); line++ {
+		if pending != nil {
+			return nil, pending
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// Only the last line may be incomplete
+			pending = fmt.Errorf("%s:%d: invalid event: %w", path, line, err)
+			continue
+		}
+		recording.Events = append(recording.Events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &recording, nil
+}
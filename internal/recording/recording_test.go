@@ -0,0 +1,104 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// started is the start of the recorded streams
+var started = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// record writes a recording of payloads one second apart and returns its path
+func record(t *testing.T, dir string, payloads ...string) string {
+	t.Helper()
+	w, err := Create(dir, Header{Endpoint: "quotes", Query: map[string][]string{"symbol": {"EURUSD"}}, Started: started})
+	if err != nil {
+		t.Fatalf("Error creating recording: %v", err)
+	}
+	for i, payload := range payloads {
+		if err := w.Write(NewEvent(started.Add(time.Duration(i+1)*time.Second), []byte(payload))); err != nil {
+			t.Fatalf("Error writing event: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Error closing recording: %v", err)
+	}
+	return w.Name()
+}
+
+func TestRecording_RoundTrip(t *testing.T) {
+	path := record(t, t.TempDir(), `{"bid":1.1}`, `plain text`)
+	if filepath.Base(path) != "quotes-20240301T120000.000000000Z.jsonl" {
+		t.Errorf("Unexpected file name %s", filepath.Base(path))
+	}
+
+	rec, err := Read(path)
+	if err != nil {
+		t.Fatalf("Error reading recording: %v", err)
+	}
+	want := Header{Version: Version, Endpoint: "quotes", Query: map[string][]string{"symbol": {"EURUSD"}}, Started: started}
+	if !reflect.DeepEqual(rec.Header, want) {
+		t.Errorf("Expected header %+v, got %+v", want, rec.Header)
+	}
+	if len(rec.Events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(rec.Events))
+	}
+	if string(rec.Events[0].Payload()) != `{"bid":1.1}` || rec.Events[0].Text != "" {
+		t.Errorf("Expected the JSON payload as data, got %+v", rec.Events[0])
+	}
+	if string(rec.Events[1].Payload()) != "plain text" || rec.Events[1].Data != nil {
+		t.Errorf("Expected the text payload as text, got %+v", rec.Events[1])
+	}
+	if !rec.Events[1].Time.Equal(started.Add(2 * time.Second)) {
+		t.Errorf("Unexpected event time %v", rec.Events[1].Time)
+	}
+}
+
+func TestCreate_SameInstant(t *testing.T) {
+	dir := t.TempDir()
+	first := record(t, dir)
+	second := record(t, dir)
+	if first == second || !strings.HasSuffix(second, "Z-2.jsonl") {
+		t.Errorf("Expected a numbered second recording, got %s and %s", first, second)
+	}
+}
+
+func TestRead_Damaged(t *testing.T) {
+	path := record(t, t.TempDir(), `1`, `2`)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A last line cut short by a crash is dropped
+	write(string(data[:len(data)-5]))
+	if rec, err := Read(path); err != nil || len(rec.Events) != 1 {
+		t.Errorf("Expected the complete events, got %v", err)
+	}
+
+	// A damaged line in the middle is an error
+	lines := strings.SplitAfter(string(data), "\n")
+	write(lines[0] + "{broken\n" + lines[2])
+	if _, err := Read(path); err == nil || !strings.Contains(err.Error(), ":2: invalid event") {
+		t.Errorf("Expected an invalid event error, got %v", err)
+	}
+
+	write(`{"version":99}` + "\n")
+	if _, err := Read(path); err == nil || !strings.Contains(err.Error(), "unsupported version") {
+		t.Errorf("Expected an unsupported version error, got %v", err)
+	}
+
+	write("")
+	if _, err := Read(path); err == nil {
+		t.Error("Expected an empty recording to be rejected")
+	}
+}
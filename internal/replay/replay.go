@@ -0,0 +1,132 @@
+// Package replay implements an endpoint serving a stream recorded by the hub, so that a
+// recorded session can be played back into a UI or a test.
+package replay
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"trading/internal/clock"
+	"trading/internal/hub"
+	"trading/internal/recording"
+)
+
+// defaultMaxCount is the number of events sent without max_count, as for other endpoints
+const defaultMaxCount = 3600
+
+// Bounds of the playback speed, which keep the gaps between events finite and non-zero
+const (
+	minSpeed = 0.01
+	maxSpeed = 1000
+)
+
+// Config represents the configuration for the replay endpoint
+type Config struct {
+	File    string      `json:"file"`    // Required; a recording written by the hub
+	Speed   float64     `json:"speed"`   // Default: 1 (original timing); 2 plays twice as fast; 0.01 to 1000
+	Instant bool        `json:"instant"` // Send every event at once, ignoring the timing
+	Loop    bool        `json:"loop"`    // Start over at the end of the recording
+	Clock   clock.Clock `json:"-"`       // Default: the hub's clock; set by tests
+}
+
+// Endpoint implements the hub.Endpoint interface for a recording
+type Endpoint struct {
+	config    Config
+	recording *recording.Recording
+}
+
+// New loads the recording of config.File and creates an Endpoint serving it
+func New(config Config) (*Endpoint, error) {
+	if config.File == "" {
+		return nil, fmt.Errorf("replay: file is required")
+	}
+	if config.Speed == 0 {
+		config.Speed = 1
+	}
+	if !validSpeed(config.Speed) {
+		return nil, fmt.Errorf("replay: speed %v must be between %v and %v", config.Speed, minSpeed, maxSpeed)
+	}
+
+	rec, err := recording.Read(config.File)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	return &Endpoint{config: config, recording: rec}, nil
+}
+
+// Header returns the header of the recording being served
+func (e *Endpoint) Header() recording.Header {
+	return e.recording.Header
+}
+
+// validSpeed reports whether speed is within the bounds
+func validSpeed(speed float64) bool {
+	return speed >= minSpeed && speed <= maxSpeed
+}
+
+// HandleSSE plays the recording. The first event is sent at once and every other one after
+// the time that separated it from the previous event, divided by the speed. The speed
+// query parameter overrides the configured speed for one request. Like other endpoints, it
+// sends max_count events, 3600 by default, also when looping.
+func (e *Endpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	speed := e.config.Speed
+	if value := query.Get("speed"); value != "" {
+		s, err := strconv.ParseFloat(value, 64)
+		if err != nil || !validSpeed(s) {
+			hub.WriteError(w, http.StatusBadRequest, "Bad Request",
+				fmt.Sprintf("speed %q must be a number between %v and %v", value, minSpeed, maxSpeed))
+			return
+		}
+		speed = s
+	}
+
+	events := e.recording.Events
+	if len(events) == 0 {
+		return
+	}
+	maxCount := defaultMaxCount
+	if n, err := strconv.Atoi(query.Get("max_count")); err == nil && n > 0 {
+		maxCount = n
+	}
+	clk := e.config.Clock
+	if clk == nil {
+		clk = clock.FromContext(r.Context())
+	}
+
+	ctx := r.Context()
+	flusher, _ := w.(http.Flusher)
+	for count, i := 0, 0; count != maxCount; count, i = count+1, i+1 {
+		if i == len(events) {
+			if !e.config.Loop {
+				return
+			}
+			i = 0
+		}
+
+		// Wait for the gap before this event; the first event of each pass has none
+		if i > 0 && !e.config.Instant {
+			if gap := time.Duration(float64(events[i].Time.Sub(events[i-1].Time)) / speed); gap > 0 {
+				timer := clk.NewTimer(gap)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C():
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		if _, err := w.Write(events[i].Payload()); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package replay
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"trading/internal/client"
+	"trading/internal/clock"
+	"trading/internal/hub"
+	"trading/internal/hub/hubtest"
+	"trading/internal/recording"
+)
+
+// start is the time of the recordings and of the fake clocks
+var start = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// streamWriter hands every write to the test as soon as the endpoint makes it
+type streamWriter struct {
+	header http.Header
+	writes chan string
+}
+
+// newStreamWriter creates a streamWriter
+func newStreamWriter() *streamWriter {
+	return &streamWriter{header: make(http.Header), writes: make(chan string)}
+}
+
+// Header implements http.ResponseWriter
+func (w *streamWriter) Header() http.Header { return w.header }
+
+// WriteHeader implements http.ResponseWriter
+func (w *streamWriter) WriteHeader(int) {}
+
+// Write implements http.ResponseWriter
+func (w *streamWriter) Write(b []byte) (int, error) {
+	w.writes <- string(b)
+	return len(b), nil
+}
+
+// writeRecording writes events 1, 2 and 3 at 0s, 1s and 3s past start and returns the path
+func writeRecording(t *testing.T) string {
+	t.Helper()
+	w, err := recording.Create(t.TempDir(), recording.Header{Endpoint: "quotes", Started: start})
+	if err != nil {
+		t.Fatalf("Error creating recording: %v", err)
+	}
+	for i, offset := range []time.Duration{0, time.Second, 3 * time.Second} {
+		if err := w.Write(recording.NewEvent(start.Add(offset), []byte{byte('1' + i)})); err != nil {
+			t.Fatalf("Error writing recording: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Error closing recording: %v", err)
+	}
+	return w.Name()
+}
+
+// play runs the endpoint on target in a goroutine and returns its writer and a channel
+// closed when it returns
+func play(e *Endpoint, target string) (*streamWriter, chan struct{}) {
+	w := newStreamWriter()
+	done := make(chan struct{})
+	go func() {
+		e.HandleSSE(w, httptest.NewRequest(http.MethodGet, target, nil))
+		close(done)
+	}()
+	return w, done
+}
+
+// expectNone fails if the endpoint has written something
+func expectNone(t *testing.T, w *streamWriter) {
+	t.Helper()
+	select {
+	case got := <-w.writes:
+		t.Fatalf("Expected no event yet, got %s", got)
+	default:
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Error("Expected a missing file to be rejected")
+	}
+	if _, err := New(Config{File: writeRecording(t), Speed: -1}); err == nil {
+		t.Error("Expected a negative speed to be rejected")
+	}
+	if _, err := New(Config{File: writeRecording(t), Speed: 5000}); err == nil {
+		t.Error("Expected a speed above the bound to be rejected")
+	}
+	if _, err := New(Config{File: filepath.Join(t.TempDir(), "missing.jsonl")}); err == nil {
+		t.Error("Expected an unreadable file to be rejected")
+	}
+
+	e, err := New(Config{File: writeRecording(t)})
+	if err != nil {
+		t.Fatalf("Error creating endpoint: %v", err)
+	}
+	if e.Header().Endpoint != "quotes" || e.config.Speed != 1 {
+		t.Errorf("Unexpected header %+v or speed %v", e.Header(), e.config.Speed)
+	}
+}
+
+func TestEndpoint_Timing(t *testing.T) {
+	fake := clock.NewFake(start)
+	e, _ := New(Config{File: writeRecording(t), Clock: fake})
+	w, done := play(e, "/quotes/stream")
+
+	// The first event is sent at once, the others after their original gaps
+	if got := <-w.writes; got != "1" {
+		t.Fatalf("Expected 1, got %s", got)
+	}
+	for _, step := range []struct {
+		gap  time.Duration
+		want string
+	}{{time.Second, "2"}, {2 * time.Second, "3"}} {
+		fake.BlockUntil(1)
+		fake.Advance(step.gap - time.Millisecond)
+		expectNone(t, w)
+		fake.Advance(time.Millisecond)
+		if got := <-w.writes; got != step.want {
+			t.Errorf("Expected %s, got %s", step.want, got)
+		}
+	}
+	<-done
+}
+
+func TestEndpoint_Speed(t *testing.T) {
+	fake := clock.NewFake(start)
+	e, _ := New(Config{File: writeRecording(t), Speed: 2, Clock: fake})
+
+	// The configured speed halves the gaps, and the query parameter overrides it
+	for _, tc := range []struct {
+		target string
+		gap    time.Duration
+	}{
+		{"/quotes/stream?max_count=2", 500 * time.Millisecond},
+		{"/quotes/stream?max_count=2&speed=4", 250 * time.Millisecond},
+	} {
+		w, done := play(e, tc.target)
+		<-w.writes
+		fake.BlockUntil(1)
+		fake.Advance(tc.gap - time.Millisecond)
+		expectNone(t, w)
+		fake.Advance(time.Millisecond)
+		<-w.writes
+		<-done
+	}
+}
+
+func TestEndpoint_InvalidSpeed(t *testing.T) {
+	e, _ := New(Config{File: writeRecording(t), Clock: clock.NewFake(start)})
+
+	// An override outside the bounds is rejected rather than ignored
+	for _, speed := range []string{"0", "-1", "1e-300", "1e9", "NaN", "Inf", "fast"} {
+		rr := httptest.NewRecorder()
+		e.HandleSSE(rr, httptest.NewRequest(http.MethodGet, "/quotes/stream?speed="+speed, nil))
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "must be a number between 0.01 and 1000") {
+			t.Errorf("Expected speed %s to be rejected, got %d %s", speed, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestEndpoint_Instant(t *testing.T) {
+	e, _ := New(Config{File: writeRecording(t), Instant: true, Clock: clock.NewFake(start)})
+
+	rr := httptest.NewRecorder()
+	e.HandleSSE(rr, httptest.NewRequest(http.MethodGet, "/quotes/stream", nil))
+	if rr.Body.String() != "123" {
+		t.Errorf("Expected every event at once, got %s", rr.Body.String())
+	}
+}
+
+func TestEndpoint_MaxCountAndLoop(t *testing.T) {
+	for _, tc := range []struct {
+		loop   bool
+		target string
+		want   string
+	}{
+		{false, "/quotes?max_count=1", "1"},
+		{false, "/quotes/stream?max_count=5", "123"},
+		{false, "/quotes/stream?max_count=0", "123"},
+		{true, "/quotes/stream?max_count=7", "1231231"},
+		// Invalid or missing counts fall back to 3600, so an instant loop still ends
+		{true, "/quotes/stream?max_count=-1", strings.Repeat("123", 1200)},
+		{true, "/quotes/stream?max_count=x", strings.Repeat("123", 1200)},
+		{true, "/quotes/stream", strings.Repeat("123", 1200)},
+	} {
+		e, _ := New(Config{File: writeRecording(t), Instant: true, Loop: tc.loop})
+		rr := httptest.NewRecorder()
+		e.HandleSSE(rr, httptest.NewRequest(http.MethodGet, tc.target, nil))
+		if rr.Body.String() != tc.want {
+			t.Errorf("%s with loop %t: expected %d events, got %d", tc.target, tc.loop, len(tc.want), rr.Body.Len())
+		}
+	}
+}
+
+func TestEndpoint_Canceled(t *testing.T) {
+	fake := clock.NewFake(start)
+	e, _ := New(Config{File: writeRecording(t), Clock: fake})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newStreamWriter()
+	done := make(chan struct{})
+	go func() {
+		e.HandleSSE(w, httptest.NewRequest(http.MethodGet, "/quotes/stream", nil).WithContext(ctx))
+		close(done)
+	}()
+	<-w.writes
+	fake.BlockUntil(1)
+
+	cancel()
+	<-done
+	if fake.Waiters() != 0 {
+		t.Error("Expected the timer to be stopped")
+	}
+}
+
+// quoteEndpoint streams two quotes
+type quoteEndpoint struct{}
+
+// HandleSSE implements the hub.Endpoint interface
+func (quoteEndpoint) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"bid":1.1}`))
+	w.Write([]byte(`{"bid":1.2}`))
+}
+
+func TestEndpoint_RoundTrip(t *testing.T) {
+	// Record a stream of the hub
+	dir := t.TempDir()
+	config := hub.DefaultConfig()
+	config.Recording.Dir = dir
+	recorder := hubtest.NewServer(t, hubtest.WithConfig(config), hubtest.WithEndpoint("quotes", quoteEndpoint{}))
+	c, err := client.New(client.Config{BaseURL: recorder.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := c.Stream(context.Background(), "quotes", nil)
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+	for {
+		if _, err := stream.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Error reading stream: %v", err)
+		}
+	}
+	stream.Close()
+
+	// Replay it through another hub
+	files, _ := filepath.Glob(filepath.Join(dir, "quotes-*.jsonl"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v", files)
+	}
+	e, err := New(Config{File: files[0], Instant: true})
+	if err != nil {
+		t.Fatalf("Error creating endpoint: %v", err)
+	}
+	player := hubtest.NewServer(t, hubtest.WithEndpoint("replay", e))
+	c, _ = client.New(client.Config{BaseURL: player.URL})
+	stream, err = c.Stream(context.Background(), "replay", nil)
+	if err != nil {
+		t.Fatalf("Error opening replay: %v", err)
+	}
+	defer stream.Close()
+
+	for _, want := range []float64{1.1, 1.2} {
+		got, err := client.NextData[struct{ Bid float64 }](stream)
+		if err != nil || got.Bid != want {
+			t.Errorf("Expected bid %v, got %+v, %v", want, got, err)
+		}
+	}
+	if _, err := stream.Next(); err != io.EOF {
+		t.Errorf("Expected the replay to end with the recording, got %v", err)
+	}
+}
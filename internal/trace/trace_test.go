@@ -0,0 +1,281 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "valid", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "future version with extra field", value: "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what"},
+		{name: "empty", value: "", wantErr: true},
+		{name: "version ff", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "version 00 with extra field", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-x", wantErr: true},
+		{name: "uppercase hex", value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "zero trace id", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{name: "zero span id", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", wantErr: true},
+		{name: "bad separator", value: "00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("Unexpected trace id %s", sc.TraceID)
+			}
+			if sc.SpanID.String() != "00f067aa0ba902b7" {
+				t.Errorf("Unexpected span id %s", sc.SpanID)
+			}
+			if !sc.IsSampled() {
+				t.Error("Expected span context to be sampled")
+			}
+		})
+	}
+}
+
+func TestExtractInject(t *testing.T) {
+	in := http.Header{}
+	in.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	in.Set(TracestateHeader, "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7")
+
+	sc, ok := Extract(in)
+	if !ok {
+		t.Fatal("Expected trace context to be extracted")
+	}
+
+	tracer := NewTracer(&memoryExporter{}, Config{})
+	defer tracer.Shutdown(context.Background())
+
+	ctx := ContextWithRemoteSpanContext(context.Background(), sc)
+	ctx, span := tracer.Start(ctx, "child", SpanKindClient)
+	defer span.End()
+
+	out := http.Header{}
+	Inject(ctx, out)
+
+	parsed, err := ParseTraceparent(out.Get(TraceparentHeader))
+	if err != nil {
+		t.Fatalf("Error parsing injected traceparent: %v", err)
+	}
+	if parsed.TraceID != sc.TraceID {
+		t.Errorf("Expected trace id %s, got %s", sc.TraceID, parsed.TraceID)
+	}
+	if parsed.SpanID == sc.SpanID {
+		t.Error("Expected a new span id for the child span")
+	}
+	if out.Get(TracestateHeader) != "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7" {
+		t.Errorf("Expected tracestate to be propagated, got %q", out.Get(TracestateHeader))
+	}
+}
+
+func TestExtract_DropsInvalidTracestate(t *testing.T) {
+	h := http.Header{}
+	h.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.Set(TracestateHeader, "not a list member")
+
+	sc, ok := Extract(h)
+	if !ok {
+		t.Fatal("Expected trace context to be extracted")
+	}
+	if sc.TraceState != "" {
+		t.Errorf("Expected invalid tracestate to be dropped, got %q", sc.TraceState)
+	}
+}
+
+func TestTracer_NilIsNoop(t *testing.T) {
+	var tracer *Tracer
+	ctx, span := tracer.Start(context.Background(), "noop", SpanKindServer)
+	span.SetAttribute("key", "value")
+	span.End()
+
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Error("Expected a nil tracer to create no spans")
+	}
+}
+
+func TestTracer_UnsampledParentIsNotExported(t *testing.T) {
+	exporter := &memoryExporter{}
+	tracer := NewTracer(exporter, Config{})
+
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, span := tracer.Start(ContextWithRemoteSpanContext(context.Background(), sc), "unsampled", SpanKindServer)
+	span.End()
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Error shutting down tracer: %v", err)
+	}
+	if got := exporter.all(); len(got) != 0 {
+		t.Errorf("Expected no exported spans, got %d", len(got))
+	}
+}
+
+func TestStdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer(NewStdoutExporter(&buf), Config{})
+
+	ctx, parent := tracer.Start(context.Background(), "parent", SpanKindServer)
+	_, child := tracer.Start(ctx, "child", SpanKindInternal)
+	child.SetAttribute("hub.endpoint", "date")
+	child.End()
+	parent.End()
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Error shutting down tracer: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 JSON lines, got %d: %s", len(lines), buf.String())
+	}
+
+	var first SpanData
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Error decoding span: %v", err)
+	}
+	if first.Name != "child" || first.ParentSpanID != parent.SpanContext().SpanID.String() {
+		t.Errorf("Unexpected child span: %+v", first)
+	}
+	if first.Attributes["hub.endpoint"] != "date" {
+		t.Errorf("Expected endpoint attribute, got %v", first.Attributes)
+	}
+}
+
+func TestOTLPExporter_FakeCollector(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []otlpRequest
+		headers  []http.Header
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			http.NotFound(w, r)
+			return
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		headers = append(headers, r.Header.Clone())
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	exporter, err := NewOTLPExporter(OTLPConfig{
+		Endpoint:    collector.URL,
+		ServiceName: "hub-test",
+		Headers:     map[string]string{"X-Collector-Token": "test"},
+	})
+	if err != nil {
+		t.Fatalf("Error creating exporter: %v", err)
+	}
+	tracer := NewTracer(exporter, Config{FlushInterval: time.Hour})
+
+	_, span := tracer.Start(context.Background(), "GET /date", SpanKindServer)
+	span.SetAttribute("hub.max_count", 1)
+	span.SetAttribute("hub.endpoint", "date")
+	span.SetStatus(StatusError, "boom")
+	span.End()
+
+	if err := tracer.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("Error flushing: %v", err)
+	}
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Error shutting down tracer: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 export request, got %d", len(requests))
+	}
+	if headers[0].Get("X-Collector-Token") != "test" {
+		t.Error("Expected collector header to be sent")
+	}
+
+	rs := requests[0].ResourceSpans[0]
+	if got := *rs.Resource.Attributes[0].Value.StringValue; got != "hub-test" {
+		t.Errorf("Expected service name hub-test, got %q", got)
+	}
+	got := rs.ScopeSpans[0].Spans[0]
+	if got.Name != "GET /date" || got.Kind != SpanKindServer {
+		t.Errorf("Unexpected span: %+v", got)
+	}
+	if got.TraceID != span.SpanContext().TraceID.String() {
+		t.Errorf("Expected hex trace id %s, got %s", span.SpanContext().TraceID, got.TraceID)
+	}
+	if got.Status.Code != StatusError || got.Status.Message != "boom" {
+		t.Errorf("Unexpected status: %+v", got.Status)
+	}
+	if got.Attributes[1].Key != "hub.max_count" || *got.Attributes[1].Value.IntValue != "1" {
+		t.Errorf("Unexpected attributes: %+v", got.Attributes)
+	}
+}
+
+func TestOTLPExporter_CollectorError(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	exporter, err := NewOTLPExporter(OTLPConfig{Endpoint: collector.URL})
+	if err != nil {
+		t.Fatalf("Error creating exporter: %v", err)
+	}
+	if err := exporter.ExportSpans(context.Background(), []SpanData{{Name: "x"}}); err == nil {
+		t.Error("Expected an error for a failing collector")
+	}
+}
+
+// memoryExporter collects exported spans in memory
+type memoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// ExportSpans implements Exporter
+func (e *memoryExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Shutdown implements Exporter
+func (e *memoryExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// all returns a copy of the exported spans
+func (e *memoryExporter) all() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
@@ -0,0 +1,238 @@
+package trace
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Exporter sends ended spans to a tracing backend
+type Exporter interface {
+	// ExportSpans exports a batch of spans
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	// Shutdown flushes and releases any resources held by the exporter
+	Shutdown(ctx context.Context) error
+}
+
+// Config represents the configuration for a Tracer
+type Config struct {
+	BatchSize     int           // Default: 128
+	QueueSize     int           // Default: 2048
+	FlushInterval time.Duration // Default: 5s
+	ExportTimeout time.Duration // Default: 10s
+}
+
+// DefaultConfig returns a Config with default values
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:     128,
+		QueueSize:     2048,
+		FlushInterval: 5 * time.Second,
+		ExportTimeout: 10 * time.Second,
+	}
+}
+
+// Tracer creates spans and exports them in batches from a background goroutine.
+// A nil *Tracer is valid: it creates no spans but still propagates remote span contexts.
+type Tracer struct {
+	exporter Exporter
+	config   Config
+	queue    chan SpanData
+	flush    chan chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+	closed   atomic.Bool
+	dropped  atomic.Uint64
+	stopOnce sync.Once
+}
+
+// NewTracer creates a Tracer exporting to exporter. Zero config values are replaced by defaults.
+func NewTracer(exporter Exporter, config Config) *Tracer {
+	defaults := DefaultConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+	if config.ExportTimeout <= 0 {
+		config.ExportTimeout = defaults.ExportTimeout
+	}
+
+	t := &Tracer{
+		exporter: exporter,
+		config:   config,
+		queue:    make(chan SpanData, config.QueueSize),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Start creates a span as a child of the span (or remote span context) found in ctx
+// and returns a context carrying the new span
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	parent := SpanContextFromContext(ctx)
+	sc := SpanContext{Flags: flagSampled}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Flags = parent.Flags
+		sc.TraceState = parent.TraceState
+	} else {
+		traceID, err := newTraceID()
+		if err != nil {
+			slog.Error("Error creating span", "error", err)
+			return ctx, nil
+		}
+		sc.TraceID = traceID
+	}
+
+	spanID, err := newSpanID()
+	if err != nil {
+		slog.Error("Error creating span", "error", err)
+		return ctx, nil
+	}
+	sc.SpanID = spanID
+
+	span := &Span{
+		tracer:     t,
+		sc:         sc,
+		parent:     parent.SpanID,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]any),
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Dropped returns the number of spans discarded because the queue was full
+func (t *Tracer) Dropped() uint64 {
+	if t == nil {
+		return 0
+	}
+	return t.dropped.Load()
+}
+
+// ForceFlush exports all queued spans and waits until the export completes or ctx expires
+func (t *Tracer) ForceFlush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	ack := make(chan struct{})
+	select {
+	case t.flush <- ack:
+	case <-t.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the remaining spans, stops the background goroutine and shuts down the exporter
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.stopOnce.Do(func() {
+		t.closed.Store(true)
+		close(t.done)
+	})
+
+	select {
+	case <-t.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+// enqueue hands an ended span to the background goroutine without blocking
+func (t *Tracer) enqueue(data SpanData) {
+	if t == nil || t.closed.Load() {
+		return
+	}
+	if data.TraceID == "" {
+		return
+	}
+
+	select {
+	case t.queue <- data:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// run batches spans and exports them when the batch is full, the interval elapses or a flush is requested
+func (t *Tracer) run() {
+	defer close(t.stopped)
+
+	ticker := time.NewTicker(t.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, t.config.BatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.config.ExportTimeout)
+		defer cancel()
+		if err := t.exporter.ExportSpans(ctx, batch); err != nil {
+			slog.Error("Error exporting spans", "count", len(batch), "error", err)
+		}
+		batch = make([]SpanData, 0, t.config.BatchSize)
+	}
+	drain := func() {
+		for {
+			select {
+			case data := <-t.queue:
+				batch = append(batch, data)
+				if len(batch) >= t.config.BatchSize {
+					export()
+				}
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) >= t.config.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-t.flush:
+			drain()
+			export()
+			close(ack)
+		case <-t.done:
+			drain()
+			export()
+			return
+		}
+	}
+}